@@ -4,10 +4,28 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"x/core/internal/audit"
+	"x/core/internal/catalog"
 	"x/core/internal/config"
+	"x/core/internal/flags"
+	"x/core/internal/listings"
+	"x/core/internal/messages"
+	"x/core/internal/offers"
+	"x/core/internal/orders"
+	"x/core/internal/persist"
+	"x/core/internal/reviews"
+	"x/core/internal/search"
+	"x/core/internal/service"
+	"x/core/internal/shipping"
+	"x/core/internal/watchlists"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
 type Driver string
@@ -16,36 +34,218 @@ const (
 	PGXDriver Driver = "pgx"
 )
 
+const (
+	defaultDBPort         = "5432"
+	defaultConnectBackoff = time.Second
+	maxConnectBackoff     = time.Second * 30
+)
+
 // Application models/domains to be migrated as db tables
-var models []interface{}
+var models = []interface{}{
+	&flags.Flag{},
+	&audit.Entry{},
+	&catalog.Brand{},
+	&catalog.Note{},
+	&catalog.Fragrance{},
+	&listings.Listing{},
+	&listings.Transition{},
+	&listings.Decant{},
+	&offers.Offer{},
+	&orders.Order{},
+	&orders.PayoutAccount{},
+	&orders.PaymentEvent{},
+	&shipping.Address{},
+	&shipping.Shipment{},
+	&reviews.Review{},
+	&messages.Conversation{},
+	&messages.Message{},
+	&messages.Block{},
+	&watchlists.Watch{},
+	&watchlists.SavedSearch{},
+	&watchlists.Alert{},
+}
 
-// Connect to postgres database
-func ConnectToDB(ctx context.Context, cfg config.Database) (*gorm.DB, error) {
-	dbDSN := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+// Models whose mutations are recorded in the audit log
+var auditedModels = []interface{}{
+	&flags.Flag{},
+	&catalog.Brand{},
+	&catalog.Note{},
+	&catalog.Fragrance{},
+	&listings.Listing{},
+	&offers.Offer{},
+	&orders.Order{},
+	&orders.PayoutAccount{},
+	&shipping.Address{},
+	&shipping.Shipment{},
+	&reviews.Review{},
+	&messages.Block{},
+	&watchlists.Watch{},
+	&watchlists.SavedSearch{},
+}
+
+// Full text search indexes migrated after the models
+var searchIndexes = []search.Index{
+	service.ListingsIndex,
+}
+
+// Build a postgres connection url for the host, escaping credentials and
+// encoding the TLS and session settings as connection parameters
+func buildDSN(cfg config.Database, host string) string {
+	port := cfg.Port
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host, port = h, p
+	}
+	if port == "" {
+		port = defaultDBPort
+	}
+
+	params := url.Values{}
+	if cfg.SSLMode != "" {
+		params.Set("sslmode", cfg.SSLMode)
+	}
+	if cfg.SSLRootCert != "" {
+		params.Set("sslrootcert", cfg.SSLRootCert)
+	}
+	if cfg.SSLCert != "" {
+		params.Set("sslcert", cfg.SSLCert)
+	}
+	if cfg.SSLKey != "" {
+		params.Set("sslkey", cfg.SSLKey)
+	}
+	if cfg.ApplicationName != "" {
+		params.Set("application_name", cfg.ApplicationName)
+	}
+	if cfg.SearchPath != "" {
+		params.Set("search_path", cfg.SearchPath)
+	}
+	if cfg.StatementTimeout > 0 {
+		params.Set("statement_timeout", fmt.Sprintf("%d", cfg.StatementTimeout.Milliseconds()))
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(host, port),
+		Path:     "/" + cfg.Name,
+		RawQuery: params.Encode(),
+	}
+	return dsn.String()
+}
 
-	sqlDB, err := sql.Open(string(PGXDriver), dbDSN)
+// Open a sql connection pool, retrying with exponential backoff until the
+// database is reachable or the retries are exhausted
+func openSQL(ctx context.Context, cfg config.Database, host string) (*sql.DB, error) {
+	sqlDB, err := sql.Open(string(PGXDriver), buildDSN(cfg, host))
 	if err != nil {
-		return nil, fmt.Errorf("error opening sql connection")
+		return nil, fmt.Errorf("error opening sql connection: %v", err)
+	}
+
+	backoff := cfg.ConnectBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
 	}
 
 	// Check the sql connection
-	if err := sqlDB.Ping(); err != nil {
-		return nil, fmt.Errorf("error pinging sql connection: %v", err)
+	for attempt := int64(0); ; attempt++ {
+		err = sqlDB.PingContext(ctx)
+		if err == nil {
+			break
+		}
+		if attempt >= cfg.ConnectRetries {
+			sqlDB.Close()
+			return nil, fmt.Errorf("error pinging sql connection to %s: %v", host, err)
+		}
+
+		z.Warn().Err(err).Msgf("database %s not reachable, retrying in %s (%d/%d)", host, backoff, attempt+1, cfg.ConnectRetries)
+		select {
+		case <-ctx.Done():
+			sqlDB.Close()
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxConnectBackoff)
 	}
 
-	// Set max connections
+	// Pool tuning
 	if cfg.MaxConnections > 0 {
 		sqlDB.SetMaxOpenConns(int(cfg.MaxConnections))
 	}
+	if cfg.MaxIdleConnections > 0 {
+		sqlDB.SetMaxIdleConns(int(cfg.MaxIdleConnections))
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+
+	return sqlDB, nil
+}
+
+// Connect to postgres database
+func ConnectToDB(ctx context.Context, cfg config.Database) (*gorm.DB, error) {
+	sqlDB, err := openSQL(ctx, cfg, cfg.Host)
+	if err != nil {
+		return nil, err
+	}
 
+	// Close every connection opened so far when a later one fails
+	opened := []*sql.DB{sqlDB}
+	fail := func(err error) (*gorm.DB, error) {
+		for _, db := range opened {
+			db.Close()
+		}
+		return nil, err
+	}
+
+	// Transactions on the primary run on a dedicated connection, see persist.Pool
 	gormDB, err := gorm.Open(postgres.New(postgres.Config{
-		Conn: sqlDB,
+		Conn: persist.NewPool(sqlDB),
 	}), &gorm.Config{})
 	if err != nil {
-		return nil, fmt.Errorf("error opening gorm connection")
+		return fail(fmt.Errorf("error opening gorm connection: %v", err))
+	}
+
+	// Route read-only queries to the replicas
+	if len(cfg.ReplicaHosts) > 0 {
+		var replicas []gorm.Dialector
+		for _, host := range cfg.ReplicaHosts {
+			host = strings.TrimSpace(host)
+			if host == "" {
+				continue
+			}
+
+			replicaDB, err := openSQL(ctx, cfg, host)
+			if err != nil {
+				return fail(err)
+			}
+			opened = append(opened, replicaDB)
+			replicas = append(replicas, postgres.New(postgres.Config{Conn: replicaDB}))
+		}
+
+		if err := gormDB.Use(dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   dbresolver.RandomPolicy{},
+		})); err != nil {
+			return fail(fmt.Errorf("error registering read replicas: %v", err))
+		}
+		z.Info().Msgf("%d read replicas registered", len(replicas))
 	}
 
 	handleMigrations(gormDB, models)
+
+	for _, ix := range searchIndexes {
+		if err := ix.Migrate(gormDB); err != nil {
+			z.Error().Err(err).Msgf("error migrating search index for %s", ix.Table)
+		}
+	}
+
+	if err := audit.Register(gormDB, &z, auditedModels...); err != nil {
+		return nil, fmt.Errorf("error registering audit callbacks: %v", err)
+	}
+
 	return gormDB, nil
 }
 
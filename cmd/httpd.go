@@ -8,29 +8,30 @@ import (
 	"os"
 	"os/signal"
 	"time"
+	"x/core/internal/cache"
+	"x/core/internal/config"
+	"x/core/internal/events"
+	"x/core/internal/flags"
 	"x/core/internal/handlers"
+	"x/core/internal/jobs"
+	"x/core/internal/listings"
+	"x/core/internal/metrics"
+	"x/core/internal/orders"
+	"x/core/internal/payments"
 	"x/core/internal/persist"
+	"x/core/internal/realtime"
 	"x/core/internal/service"
+	"x/core/internal/shipping"
+	"x/core/internal/watchlists"
 
 	"github.com/clerkinc/clerk-sdk-go/clerk"
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/getsentry/sentry-go"
 	sentryhttp "github.com/getsentry/sentry-go/http"
-	"github.com/rs/cors"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
-type AllowedMethods string
-
-var (
-	AllowedMethodsGet    AllowedMethods = "GET"
-	AllowedMethodsPost   AllowedMethods = "POST"
-	AllowedMethodsPatch  AllowedMethods = "PATCH"
-	AllowedMethodsPut    AllowedMethods = "PUT"
-	AllowedMethodsDelete AllowedMethods = "DELETE"
-)
-
 var httpCmd = &cobra.Command{
 	Use:   "httpd",
 	Short: "http service",
@@ -53,11 +54,18 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 	if conf.Cloudinary.APIKey == "" {
 		z.Fatal().Msg("Cloudinary API key is not configured")
 	}
+	if err := conf.Validate(); err != nil {
+		z.Fatal().Err(err).Msg("invalid configuration")
+	}
 
 	// Parent context
 	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt)
 	defer cancel()
 
+	// Live config, reloaded on SIGHUP or config file changes
+	live := config.NewLive(conf)
+	watchConfig(ctx, live)
+
 	// Database connection
 	db, err := ConnectToDB(
 		ctx,
@@ -82,10 +90,13 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 
 	// Sentry monitoring
 	if err := sentry.Init(sentry.ClientOptions{
-		EnableTracing:      true,
-		Dsn:                conf.Sentry.DSN,
-		TracesSampleRate:   conf.Sentry.SampleRate,
-		ProfilesSampleRate: conf.Sentry.SampleRate,
+		EnableTracing: true,
+		Dsn:           conf.Sentry.DSN,
+		// Sample rate is read from the live config so it can be reloaded
+		TracesSampler: func(sentry.SamplingContext) float64 {
+			return live.Load().Sentry.SampleRate
+		},
+		ProfilesSampleRate: conf.Sentry.ProfilesSampleRate,
 		Environment:        conf.Env,
 	}); err != nil {
 		z.Error().Err(err).Msgf("error initializing sentry monitoring")
@@ -118,44 +129,144 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 	cld.Config.URL.Secure = true
 	z.Info().Msg("cloudinary client initialized")
 
+	// Query cache, invalidated on writes
+	var queryCache *cache.Cache
+	switch conf.Cache.Backend {
+	case config.CacheBackendMemory:
+		queryCache = cache.New(cache.NewLRU(conf.Cache.Size), conf.Cache.TTL, &z)
+	case config.CacheBackendRedis:
+		backend, err := cache.NewRedis(ctx, conf.Cache.RedisAddr, conf.Cache.RedisPassword, conf.Cache.RedisDB)
+		if err != nil {
+			z.Fatal().Err(err).Msgf("error connecting to redis cache: %s", err)
+		}
+		queryCache = cache.New(backend, conf.Cache.TTL, &z)
+	}
+	if queryCache != nil {
+		if err := cache.RegisterInvalidation(db, queryCache); err != nil {
+			z.Fatal().Err(err).Msg("error registering cache invalidation")
+		}
+		defer queryCache.Close()
+		z.Info().Msgf("%s query cache initialized", conf.Cache.Backend)
+	}
+
 	// Initialize store
 	store := persist.NewPGStore(
 		db,
+		queryCache,
 		&log.Logger,
 	)
 	z.Info().Msg("core database initialized")
 
+	// Background jobs
+	scheduler := jobs.NewScheduler(&z)
+	scheduler.Add(jobs.Job{
+		Name:     "purge_soft_deleted",
+		Interval: conf.Retention.PurgeInterval,
+		Run: func(ctx context.Context) error {
+			return store.PurgeDeleted(ctx, models, conf.Retention.SoftDeleteRetention)
+		},
+	})
+
+	// Initialize feature flags, seeded from config
+	flagRegistry := flags.NewRegistry(db, &z, conf.Env, flagCacheTTL)
+	if err := flagRegistry.Seed(ctx, conf.Features.Flags); err != nil {
+		z.Error().Err(err).Msg("error seeding feature flags")
+	}
+	live.OnChange(func(prev, next config.Config) {
+		if err := flagRegistry.Seed(ctx, next.Features.Flags); err != nil {
+			z.Error().Err(err).Msg("error seeding feature flags")
+		}
+	})
+	z.Info().Msg("feature flags initialized")
+
+	// Domain events, published once changes are committed
+	bus := events.NewBus(&z)
+
+	// Realtime gateway pushing events to connected users, and listing
+	// updates to anyone following the listing
+	hub := realtime.NewHub(&z, conf.Realtime.BufferSize, listings.EventPriceChanged)
+	bus.Subscribe(hub.Publish)
+
+	// Payment provider holding order funds until delivery
+	var provider payments.Provider = payments.NewFake()
+	if conf.Payments.Provider == config.PaymentProviderStripe {
+		provider = payments.NewStripe(conf.Payments.StripeURL, conf.Payments.StripeSecretKey, conf.Payments.StripeWebhookSecret)
+	}
+	z.Info().Msgf("%s payment provider initialized", provider.Name())
+
+	// Shipping provider quoting rates and tracking parcels
+	var carrier shipping.Provider = shipping.NewFake()
+	if conf.Shipping.Provider == config.ShippingProviderCarrier {
+		carrier = shipping.NewCarrier(conf.Shipping.CarrierURL, conf.Shipping.CarrierAPIKey)
+	}
+	z.Info().Msgf("%s shipping provider initialized", carrier.Name())
+
 	// Initalize service
 	service := service.NewService(
 		store,
 		&z,
 		cld,
+		flagRegistry,
+		bus,
+		provider,
+		orders.FeePolicy{RateBPS: conf.Marketplace.CommissionBPS, Fixed: conf.Marketplace.CommissionFixed},
+		carrier,
 	)
 	z.Info().Msg("core service initialized")
 
-	// Initialize cors
-	crossOrigin := cors.New(cors.Options{
-		AllowedOrigins: []string{conf.HTTPServer.ServerAllowedOriginLocal, conf.HTTPServer.ServerAllowedOriginProd},
-		AllowedMethods: []string{
-			string(AllowedMethodsGet),
-			string(AllowedMethodsPost),
-			string(AllowedMethodsPatch),
-			string(AllowedMethodsPut),
-			string(AllowedMethodsDelete)},
-		AllowCredentials: true,
+	scheduler.Add(jobs.Job{
+		Name:     "expire_offers",
+		Interval: conf.Marketplace.OfferExpiryInterval,
+		Run: func(ctx context.Context) error {
+			_, err := service.ExpireOffers(ctx)
+			return err
+		},
+	})
+
+	scheduler.Add(jobs.Job{
+		Name:     "track_shipments",
+		Interval: conf.Marketplace.TrackingInterval,
+		Run: func(ctx context.Context) error {
+			_, err := service.TrackShipments(ctx, conf.Marketplace.TrackingInterval)
+			return err
+		},
+	})
+
+	// Expire listings past their ttl, read on every run so it can be reloaded
+	scheduler.Add(jobs.Job{
+		Name:     "expire_listings",
+		Interval: conf.Marketplace.ListingExpiryInterval,
+		Run: func(ctx context.Context) error {
+			_, err := service.ExpireListings(ctx, live.Load().Marketplace.ListingTTL)
+			return err
+		},
+	})
+
+	// Match listing updates against watches and saved searches, from where
+	// the previous run got to. Alerts are deduplicated, so looking back over
+	// updates already matched before a restart is safe.
+	alertCursor := watchlists.CursorAt(time.Now().Add(-alertLookback))
+	scheduler.Add(jobs.Job{
+		Name:     "match_alerts",
+		Interval: conf.Marketplace.AlertMatchInterval,
+		Run: func(ctx context.Context) error {
+			m := live.Load().Marketplace
+			next, _, err := service.MatchListings(ctx, alertCursor, watchlists.RateLimit{Max: m.AlertRateLimit, Window: m.AlertRateWindow})
+			alertCursor = next
+			return err
+		},
 	})
-	z.Info().Msg("cors policy initialized")
 
 	// Initialize handler
 	h := handlers.NewHandler(
 		&z,
 		service,
 		clerkClient,
-		conf,
+		live,
 		sentryHandler,
+		hub,
 	)
 	router := h.RegisterRoutes()
-	handler := crossOrigin.Handler(router)
 	z.Info().Msg("core handler initialized")
 
 	// Configure server
@@ -164,15 +275,33 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 		WriteTimeout: writeTimeout,
 		ReadTimeout:  readTimeout,
 		IdleTimeout:  idleTimeout,
-		Handler:      handler,
+		Handler:      router,
 	}
+	// Realtime connections are told to close as soon as shutdown starts, so
+	// streams don't hold it up until the deadline
+	server.RegisterOnShutdown(hub.Close)
 	z.Info().Msg("server configuration successful")
 
+	// Metrics are served on their own port, kept off the public API
+	var metricsServer *http.Server
+	if conf.HTTPServer.MetricsPort != "" {
+		metricsServer = &http.Server{
+			Addr:         fmt.Sprintf(":%s", conf.HTTPServer.MetricsPort),
+			WriteTimeout: writeTimeout,
+			ReadTimeout:  readTimeout,
+			IdleTimeout:  idleTimeout,
+			Handler:      metrics.Handler(),
+		}
+	}
+
 	// Initialize web server
 	var wait time.Duration
 	flag.DurationVar(&wait, "graceful-timeout", shutdownGracePeriod, "duration for which the server gracefully waits for existing connecitosn to finish")
 	flag.Parse()
 
+	// start background jobs, stopped by the parent context
+	scheduler.Start(ctx)
+
 	// run server in goroutine to prevent blocking
 	go func() {
 		z.Info().Msgf("core service running on port :%s", conf.HTTPServer.ServerPort)
@@ -181,12 +310,22 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 		}
 	}()
 
+	if metricsServer != nil {
+		go func() {
+			z.Info().Msgf("metrics served on port :%s", conf.HTTPServer.MetricsPort)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				z.Error().Err(err).Msgf("unexpected metrics server error")
+			}
+		}()
+	}
+
 	// Block until we recieve our signal
 	<-ctx.Done()
 	z.Info().Msg("received shutdown signal, shutting down core service gracefully")
 
-	// Create a deadline to wait for
-	cx, cancel := context.WithTimeout(ctx, wait)
+	// Create a deadline to wait for, not derived from the signal context
+	// which is already canceled
+	cx, cancel := context.WithTimeout(context.Background(), wait)
 	defer cancel()
 
 	// Doesn't block if no connections, but will otherwise wait
@@ -194,6 +333,18 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 	if err := server.Shutdown(cx); err != nil {
 		z.Error().Err(err).Msg("error during server shutdown")
 	}
+	// WebSocket connections are hijacked, Shutdown doesn't wait for them
+	if err := hub.Wait(cx); err != nil {
+		z.Error().Err(err).Msg("error closing realtime connections")
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(cx); err != nil {
+			z.Error().Err(err).Msg("error during metrics server shutdown")
+		}
+	}
+
+	// Wait for running jobs to finish
+	scheduler.Wait()
 
 	z.Info().Msg("core service successfully shutdown")
 	os.Exit(0)
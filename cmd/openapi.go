@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"x/core/internal/config"
+	"x/core/internal/handlers"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	openapiOutput  string
+	openapiVersion string
+)
+
+var openapiCmd = &cobra.Command{
+	Use:   "openapi",
+	Short: "openapi contract",
+	Long:  "tools for the generated OpenAPI document",
+}
+
+var openapiExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export the OpenAPI document",
+	Long:  "writes the OpenAPI document generated from the registered routes",
+	RunE:  exportOpenAPI,
+}
+
+func init() {
+	openapiExportCmd.Flags().StringVar(&openapiVersion, "api-version", handlers.LatestAPIVersion(), "API version to export")
+	openapiExportCmd.Flags().StringVarP(&openapiOutput, "output", "o", "", "output file, defaults to stdout")
+	openapiCmd.AddCommand(openapiExportCmd)
+	rootCmd.AddCommand(openapiCmd)
+}
+
+func exportOpenAPI(cmd *cobra.Command, args []string) error {
+	// Routes only need to be registered, no dependencies are connected. Logs
+	// go to stderr to keep stdout for the document.
+	logger := z.Output(os.Stderr)
+	h := handlers.NewHandler(&logger, nil, nil, config.NewLive(conf), nil, nil)
+	h.RegisterRoutes()
+
+	spec, ok := h.OpenAPI(openapiVersion)
+	if !ok {
+		return fmt.Errorf("unknown api version: %s", openapiVersion)
+	}
+
+	b, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding openapi document: %v", err)
+	}
+
+	if openapiOutput == "" {
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), string(b))
+		return err
+	}
+
+	if err := os.WriteFile(openapiOutput, b, 0o644); err != nil {
+		return fmt.Errorf("error writing openapi document: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "openapi document written to %s\n", openapiOutput)
+	return nil
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"x/core/internal/config"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// Watch for SIGHUP and config file changes and reload the safe-to-change
+// settings into the live config. Both go through a single loop since viper is
+// not safe for concurrent use.
+func watchConfig(ctx context.Context, live *config.Live) {
+	reload := func(trigger string) {
+		if env.ConfigFileUsed() != "" {
+			if err := env.ReadInConfig(); err != nil {
+				z.Warn().Err(err).Msgf("error reading config file: %s", env.ConfigFileUsed())
+			}
+		}
+
+		var next config.Config
+		if err := env.Unmarshal(&next); err != nil {
+			z.Error().Err(err).Str("trigger", trigger).Msg("config reload rejected: error unmarshalling config")
+			return
+		}
+		next.ApplyDefaults(env.IsSet)
+
+		changed, err := live.Reload(next)
+		if err != nil {
+			z.Error().Err(err).Str("trigger", trigger).Msg("config reload rejected")
+			return
+		}
+
+		z.Info().
+			Str("event", "config_reload").
+			Str("trigger", trigger).
+			Strs("changed", changed).
+			Msg("config reloaded")
+	}
+
+	// Apply log level changes to the global logger
+	live.OnChange(func(prev, next config.Config) {
+		level, _ := next.LogLevel()
+		zerolog.SetGlobalLevel(level)
+	})
+
+	file := watchConfigFile(ctx)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				reload("sighup")
+			case <-file:
+				reload("file")
+			}
+		}
+	}()
+
+	z.Info().Msg("config reload watcher initialized")
+}
+
+// Notify of writes to the config file. The directory is watched so editors
+// replacing the file are seen too. Never notifies without a config file.
+func watchConfigFile(ctx context.Context) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+
+	name := env.ConfigFileUsed()
+	if name == "" {
+		return changes
+	}
+	name = filepath.Clean(name)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		z.Error().Err(err).Msg("error watching config file")
+		return changes
+	}
+	if err := watcher.Add(filepath.Dir(name)); err != nil {
+		z.Error().Err(err).Msgf("error watching config file: %s", name)
+		watcher.Close()
+		return changes
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(e.Name) != name || !e.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+				// Coalesce bursts of writes into a single reload
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				z.Warn().Err(err).Msg("error watching config file")
+			}
+		}
+	}()
+
+	return changes
+}
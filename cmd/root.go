@@ -24,11 +24,14 @@ const (
 	idleTimeout         = time.Second * 60
 	envConfigPrefix     = "core"
 	dbDriver            = "postgres"
+	flagCacheTTL        = time.Second * 30
+	alertLookback       = time.Hour
 )
 
 var (
 	cfgFile string
 	conf    config.Config
+	env     *enviper.Enviper
 	z       zerolog.Logger
 )
 
@@ -52,23 +55,26 @@ func Execute() {
 	}
 }
 
+// Config messages go to stderr so command output on stdout, like an exported
+// OpenAPI document, can be piped
 func init() {
-	fmt.Println("running init...")
+	fmt.Fprintln(os.Stderr, "running init...")
 	cobra.OnInitialize(initConfig, initLogger)
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file")
 }
 
 func initConfig() {
-	e := enviper.New(viper.New())
+	env = enviper.New(viper.New())
+	e := env
 
 	if cfgFile != "" {
 		e.SetConfigFile(cfgFile)
-		fmt.Printf("Using config file: %s\n", cfgFile)
+		fmt.Fprintf(os.Stderr, "Using config file: %s\n", cfgFile)
 	} else {
 		home, err := os.Getwd()
 		if err != nil {
-			fmt.Printf("%v", err.Error())
+			fmt.Fprintf(os.Stderr, "%v", err.Error())
 			os.Exit(1)
 		}
 
@@ -83,29 +89,31 @@ func initConfig() {
 
 	// If a config file is found, read it in
 	if err := e.ReadInConfig(); err != nil {
-		fmt.Printf("\nError reading config file: %s\n", err)
-		fmt.Printf("\nError reading config file: %s\n", e.ConfigFileUsed())
+		fmt.Fprintf(os.Stderr, "\nError reading config file: %s\n", err)
+		fmt.Fprintf(os.Stderr, "\nError reading config file: %s\n", e.ConfigFileUsed())
 	} else {
-		fmt.Printf("Config file loaded: %s\n", e.ConfigFileUsed())
+		fmt.Fprintf(os.Stderr, "Config file loaded: %s\n", e.ConfigFileUsed())
 	}
 
-	fmt.Printf("config: %s", e.ConfigFileUsed())
+	fmt.Fprintf(os.Stderr, "config: %s", e.ConfigFileUsed())
 
 	if err := e.Unmarshal(&conf); err != nil {
-		fmt.Printf("\nError unmarshalling config file: %s\n", err.Error())
-		fmt.Printf("\nError unmarshalling config file: %s\n", e.ConfigFileUsed())
+		fmt.Fprintf(os.Stderr, "\nError unmarshalling config file: %s\n", err.Error())
+		fmt.Fprintf(os.Stderr, "\nError unmarshalling config file: %s\n", e.ConfigFileUsed())
 	} else {
-		fmt.Println("Config unmarshalled successfully!")
+		fmt.Fprintln(os.Stderr, "Config unmarshalled successfully!")
 	}
 
+	conf.ApplyDefaults(env.IsSet)
+
 	if conf.Env == "local" {
-		defer fmt.Printf("parsed local config\n")
+		defer fmt.Fprintf(os.Stderr, "parsed local config\n")
 		bytes, _ := json.MarshalIndent(conf, "", " ")
-		fmt.Printf("\n%s\n", string(bytes))
+		fmt.Fprintf(os.Stderr, "\n%s\n", string(bytes))
 	}
 
 	if conf.Env == "development" {
-		fmt.Printf("parsed development config\n")
+		fmt.Fprintf(os.Stderr, "parsed development config\n")
 	}
 }
 
@@ -114,6 +122,13 @@ func initLogger() {
 	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
 	zerolog.ErrorFieldName = "error.message"
 
+	level, err := conf.LogLevel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v, defaulting to info\n", err)
+		level = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(level)
+
 	z = zerolog.New(os.Stdout).
 		With().
 		Str("service", serviceName).
@@ -0,0 +1,366 @@
+package audit
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+	"x/core/internal/auth"
+	"x/core/internal/reqctx"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+const beforeRowsKey = "audit:before_rows"
+
+// Value recorded in place of columns tagged audit:"redact", such as personal
+// data and payout details
+const redacted = "[REDACTED]"
+
+// Entry is an append-only record of a single mutation
+type Entry struct {
+	ID           uint64                 `gorm:"primaryKey" json:"id"`
+	Action       Action                 `gorm:"not null;index" json:"action"`
+	ResourceType string                 `gorm:"not null;index:idx_audit_resource" json:"resource_type"`
+	ResourceID   string                 `gorm:"not null;index:idx_audit_resource" json:"resource_id"`
+	ActorID      string                 `gorm:"index" json:"actor_id,omitempty"`
+	ActorType    auth.ActorType         `json:"actor_type,omitempty"`
+	Before       map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"before,omitempty"`
+	After        map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"after,omitempty"`
+	Diff         map[string]Change      `gorm:"type:jsonb;serializer:json" json:"diff,omitempty"`
+	RequestID    string                 `gorm:"index" json:"request_id,omitempty"`
+	ClientIP     string                 `json:"client_ip,omitempty"`
+	CreatedAt    time.Time              `gorm:"index" json:"created_at"`
+}
+
+func (Entry) TableName() string {
+	return "audit_entries"
+}
+
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type auditor struct {
+	z *zerolog.Logger
+	// Redacted columns of every audited table
+	tables map[string]map[string]bool
+}
+
+// Register gorm callbacks recording every create, update and delete on the
+// given models. Entries are written in the same transaction as the mutation.
+func Register(db *gorm.DB, logger *zerolog.Logger, models ...interface{}) error {
+	a := &auditor{
+		z:      logger,
+		tables: make(map[string]map[string]bool),
+	}
+
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("error parsing audited model %T: %v", model, err)
+		}
+		redact := make(map[string]bool)
+		for _, f := range stmt.Schema.Fields {
+			if f.DBName != "" && f.Tag.Get("audit") == "redact" {
+				redact[f.DBName] = true
+			}
+		}
+		a.tables[stmt.Schema.Table] = redact
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("audit:after_create", a.afterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("audit:before_update", a.beforeMutation); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("audit:after_update", a.afterUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("audit:before_delete", a.beforeMutation); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("audit:after_delete", a.afterDelete); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *auditor) audited(tx *gorm.DB) bool {
+	if tx.Statement.Schema == nil {
+		return false
+	}
+	_, ok := a.tables[tx.Statement.Schema.Table]
+	return ok
+}
+
+func isEntry(tx *gorm.DB) bool {
+	return tx.Statement.Schema != nil && tx.Statement.Schema.Table == Entry{}.TableName()
+}
+
+// Column keyed values of a model struct
+func rowFromStruct(tx *gorm.DB, rv reflect.Value) map[string]interface{} {
+	row := make(map[string]interface{})
+	for _, f := range tx.Statement.Schema.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		v, _ := f.ValueOf(tx.Statement.Context, rv)
+		row[f.DBName] = v
+	}
+	return row
+}
+
+func primaryKey(s *schema.Schema) string {
+	if s.PrioritizedPrimaryField != nil {
+		return s.PrioritizedPrimaryField.DBName
+	}
+	return "id"
+}
+
+// Load the rows a mutation is about to touch, using its WHERE clause and the
+// primary key of the model value
+func (a *auditor) loadRows(tx *gorm.DB) ([]map[string]interface{}, error) {
+	stmt := tx.Statement
+	query := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Unscoped().Table(stmt.Table)
+
+	conditions := 0
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+			query = query.Clauses(where)
+			conditions++
+		}
+	}
+	if stmt.ReflectValue.Kind() == reflect.Struct {
+		for _, f := range stmt.Schema.PrimaryFields {
+			if v, zero := f.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+				query = query.Where(clause.Eq{Column: clause.Column{Name: f.DBName}, Value: v})
+				conditions++
+			}
+		}
+	}
+
+	// Never load an unbounded table
+	if conditions == 0 {
+		return nil, nil
+	}
+
+	var rows []map[string]interface{}
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	// Scoped mutations skip soft deleted rows
+	if !stmt.Unscoped && stmt.Schema.LookUpField("deleted_at") != nil {
+		live := rows[:0]
+		for _, row := range rows {
+			if row["deleted_at"] == nil {
+				live = append(live, row)
+			}
+		}
+		rows = live
+	}
+	return rows, nil
+}
+
+func (a *auditor) beforeMutation(tx *gorm.DB) {
+	if isEntry(tx) {
+		tx.AddError(fmt.Errorf("audit entries are append-only"))
+		return
+	}
+	if tx.Error != nil || !a.audited(tx) {
+		return
+	}
+
+	rows, err := a.loadRows(tx)
+	if err != nil {
+		tx.AddError(fmt.Errorf("error loading rows for audit: %v", err))
+		return
+	}
+	tx.InstanceSet(beforeRowsKey, rows)
+}
+
+func (a *auditor) afterCreate(tx *gorm.DB) {
+	if tx.Error != nil || !a.audited(tx) || tx.Statement.RowsAffected == 0 {
+		return
+	}
+
+	var entries []Entry
+	switch rv := tx.Statement.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			entries = append(entries, a.newEntry(tx, ActionCreate, nil, rowFromStruct(tx, reflect.Indirect(rv.Index(i)))))
+		}
+	case reflect.Struct:
+		entries = append(entries, a.newEntry(tx, ActionCreate, nil, rowFromStruct(tx, rv)))
+	}
+
+	a.write(tx, entries)
+}
+
+func (a *auditor) afterUpdate(tx *gorm.DB) {
+	if tx.Error != nil || !a.audited(tx) || tx.Statement.RowsAffected == 0 {
+		return
+	}
+
+	before := a.beforeRows(tx)
+	if len(before) == 0 {
+		return
+	}
+
+	pk := primaryKey(tx.Statement.Schema)
+	ids := make([]interface{}, 0, len(before))
+	for _, row := range before {
+		ids = append(ids, row[pk])
+	}
+
+	var after []map[string]interface{}
+	if err := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Unscoped().
+		Table(tx.Statement.Table).
+		Where(clause.IN{Column: clause.Column{Name: pk}, Values: ids}).
+		Find(&after).Error; err != nil {
+		tx.AddError(fmt.Errorf("error loading rows for audit: %v", err))
+		return
+	}
+
+	afterByID := make(map[string]map[string]interface{}, len(after))
+	for _, row := range after {
+		afterByID[fmt.Sprint(row[pk])] = row
+	}
+
+	var entries []Entry
+	for _, row := range before {
+		next, ok := afterByID[fmt.Sprint(row[pk])]
+		if !ok {
+			continue
+		}
+
+		entry := a.newEntry(tx, ActionUpdate, row, next)
+		// Rows matched by the WHERE clause but left untouched
+		if len(entry.Diff) == 0 {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	a.write(tx, entries)
+}
+
+func (a *auditor) afterDelete(tx *gorm.DB) {
+	if tx.Error != nil || !a.audited(tx) || tx.Statement.RowsAffected == 0 {
+		return
+	}
+
+	var entries []Entry
+	for _, row := range a.beforeRows(tx) {
+		entries = append(entries, a.newEntry(tx, ActionDelete, row, nil))
+	}
+
+	a.write(tx, entries)
+}
+
+func (a *auditor) beforeRows(tx *gorm.DB) []map[string]interface{} {
+	v, ok := tx.InstanceGet(beforeRowsKey)
+	if !ok {
+		return nil
+	}
+	rows, _ := v.([]map[string]interface{})
+	return rows
+}
+
+func (a *auditor) newEntry(tx *gorm.DB, action Action, before, after map[string]interface{}) Entry {
+	pk := primaryKey(tx.Statement.Schema)
+
+	entry := Entry{
+		Action:       action,
+		ResourceType: tx.Statement.Schema.Table,
+		Before:       before,
+		After:        after,
+	}
+	if before != nil && after != nil {
+		entry.Diff = diff(before, after)
+	}
+
+	if after != nil {
+		entry.ResourceID = fmt.Sprint(after[pk])
+	} else {
+		entry.ResourceID = fmt.Sprint(before[pk])
+	}
+
+	// Changes to redacted columns are recorded without their values
+	for column := range a.tables[entry.ResourceType] {
+		entry.Before = redact(entry.Before, column)
+		entry.After = redact(entry.After, column)
+		if _, ok := entry.Diff[column]; ok {
+			entry.Diff[column] = Change{Before: redacted, After: redacted}
+		}
+	}
+
+	ctx := tx.Statement.Context
+	if p, ok := auth.FromContext(ctx); ok {
+		entry.ActorID = p.ActorID()
+		entry.ActorType = p.ActorType()
+	}
+	if m, ok := reqctx.FromContext(ctx); ok {
+		entry.RequestID = m.RequestID
+		entry.ClientIP = m.ClientIP
+	}
+
+	return entry
+}
+
+func (a *auditor) write(tx *gorm.DB, entries []Entry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	// Same connection as the mutation so both commit or roll back together
+	if err := tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Create(&entries).Error; err != nil {
+		a.z.Error().Err(err).Str("resource_type", tx.Statement.Table).Msg("error writing audit entries")
+		tx.AddError(fmt.Errorf("error writing audit entries: %v", err))
+	}
+}
+
+// Copy of the row with the column's value replaced, rows are shared between
+// entries so they aren't changed in place
+func redact(row map[string]interface{}, column string) map[string]interface{} {
+	if _, ok := row[column]; !ok {
+		return row
+	}
+	out := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		out[k] = v
+	}
+	out[column] = redacted
+	return out
+}
+
+// Changed columns between two versions of a row
+func diff(before, after map[string]interface{}) map[string]Change {
+	changes := make(map[string]Change)
+	for k, v := range after {
+		if prev, ok := before[k]; !ok || !reflect.DeepEqual(prev, v) {
+			changes[k] = Change{Before: before[k], After: v}
+		}
+	}
+	for k, v := range before {
+		if _, ok := after[k]; !ok {
+			changes[k] = Change{Before: v}
+		}
+	}
+	return changes
+}
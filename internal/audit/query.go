@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Filter struct {
+	ResourceType string
+	ResourceID   string
+	ActorID      string
+	Action       Action
+	RequestID    string
+	From         time.Time
+	To           time.Time
+}
+
+// Query audit entries matching the filter, newest first
+func Query(db *gorm.DB, filter Filter, page, pageSize int) ([]Entry, int, error) {
+	var entries []Entry
+	var totalRecords int64
+
+	query := db.Model(&Entry{})
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	// Count total number of records after applying conditions
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Calculate total pages
+	totalPages := int((totalRecords + int64(pageSize) - 1) / int64(pageSize))
+
+	// Apply pagination
+	offset := (page - 1) * pageSize
+	result := query.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&entries)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return entries, totalPages, nil
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/clerkinc/clerk-sdk-go/clerk"
+)
+
+var ErrUnauthenticated = errors.New("authentication required")
+
+type ActorType string
+
+const (
+	ActorTypeUser   ActorType = "user"
+	ActorTypeSystem ActorType = "system"
+)
+
+// Principal is the authenticated clerk user making a request
+type Principal struct {
+	UserID string `json:"user_id,omitempty"`
+	OrgID  string `json:"org_id,omitempty"`
+	// Role in the active organization, for targeting only. It is managed by
+	// the organization's own admins so it never grants access.
+	Role      string `json:"role,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	// Granted by the server's admin allowlist, never by session claims
+	Admin bool `json:"admin,omitempty"`
+}
+
+type principalKey struct{}
+
+// Build a principal from verified clerk session claims. Only users in the
+// admins allowlist are admins.
+func FromClerkSession(session *clerk.SessionClaims, admins []string) Principal {
+	return Principal{
+		UserID:    session.Subject,
+		OrgID:     session.ActiveOrganizationID,
+		Role:      session.ActiveOrganizationRole,
+		SessionID: session.SessionID,
+		Admin:     session.Subject != "" && slices.Contains(admins, session.Subject),
+	}
+}
+
+// Identifier of the actor performing the request
+func (p Principal) ActorID() string {
+	return p.UserID
+}
+
+func (p Principal) ActorType() ActorType {
+	return ActorTypeUser
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Admin
+}
+
+// Add the principal to the context
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// Get the principal from the context, if the request was authenticated
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Principal of the context, or ErrUnauthenticated
+func Require(ctx context.Context) (Principal, error) {
+	p, ok := FromContext(ctx)
+	if !ok || p.ActorID() == "" {
+		return Principal{}, ErrUnauthenticated
+	}
+	return p, nil
+}
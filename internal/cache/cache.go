@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/singleflight"
+)
+
+// Backend stores cached values. Generation counters must not be evicted
+// with cached entries, stale entries would otherwise become reachable again.
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Generation(ctx context.Context, model string) (int64, error)
+	Bump(ctx context.Context, model string) error
+	Close() error
+}
+
+// Cache wraps persist reads. Keys embed a per-model generation which is
+// bumped on every write, so invalidating a model is a single increment.
+type Cache struct {
+	backend Backend
+	ttl     time.Duration
+	z       *zerolog.Logger
+	group   singleflight.Group
+}
+
+func New(backend Backend, ttl time.Duration, logger *zerolog.Logger) *Cache {
+	return &Cache{
+		backend: backend,
+		ttl:     ttl,
+		z:       logger,
+	}
+}
+
+// Key for a query on a model at its current generation
+func (c *Cache) Key(ctx context.Context, model string, query any) (string, error) {
+	gen, err := c.backend.Generation(ctx, model)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(query)
+	if err != nil {
+		return "", fmt.Errorf("error deriving cache key: %v", err)
+	}
+	sum := sha256.Sum256(b)
+
+	return fmt.Sprintf("%s:%d:%s", model, gen, hex.EncodeToString(sum[:16])), nil
+}
+
+// Invalidate every cached query on the model
+func (c *Cache) Invalidate(ctx context.Context, model string) error {
+	return c.backend.Bump(ctx, model)
+}
+
+func (c *Cache) Close() error {
+	return c.backend.Close()
+}
+
+// Fetch a cached value or load it. Concurrent misses for the same key share
+// a single load. A nil cache always loads, and cache errors fall back to
+// loading so the cache is never a point of failure.
+func Fetch[T any](ctx context.Context, c *Cache, model string, query any, load func() (T, error)) (T, error) {
+	if c == nil {
+		return load()
+	}
+
+	key, err := c.Key(ctx, model, query)
+	if err != nil {
+		c.z.Warn().Err(err).Str("model", model).Msg("cache unavailable, loading from database")
+		return load()
+	}
+
+	var value T
+	if b, ok, err := c.backend.Get(ctx, key); err != nil {
+		c.z.Warn().Err(err).Str("key", key).Msg("error reading cache")
+	} else if ok {
+		if err := json.Unmarshal(b, &value); err == nil {
+			return value, nil
+		}
+	}
+
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		loaded, err := load()
+		if err != nil {
+			return loaded, err
+		}
+
+		b, err := json.Marshal(loaded)
+		if err != nil {
+			c.z.Warn().Err(err).Str("key", key).Msg("error encoding cache value")
+			return loaded, nil
+		}
+		if err := c.backend.Set(ctx, key, b, c.ttl); err != nil {
+			c.z.Warn().Err(err).Str("key", key).Msg("error writing cache")
+		}
+		return loaded, nil
+	})
+	if err != nil {
+		return value, err
+	}
+
+	return v.(T), nil
+}
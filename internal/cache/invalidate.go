@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Table written by a raw INSERT, UPDATE or DELETE statement
+var rawWrite = regexp.MustCompile(`(?i)^\s*(?:insert\s+into|update|delete\s+from)\s+(?:only\s+)?"?([a-z_][a-z0-9_]*)"?`)
+
+// Transactions that can run a function once committed, see persist.Tx
+type afterCommitter interface {
+	AfterCommit(fn func())
+}
+
+// Table written by the statement, empty if it can't be told
+func writtenTable(tx *gorm.DB) string {
+	if tx.Statement.Schema != nil {
+		return tx.Statement.Schema.Table
+	}
+	if tx.Statement.Table != "" {
+		return tx.Statement.Table
+	}
+	if m := rawWrite.FindStringSubmatch(tx.Statement.SQL.String()); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+// Register gorm callbacks invalidating a table's cached queries after every
+// successful create, update or delete, including writes through Table() and
+// raw Exec statements. Writes inside a transaction invalidate once it
+// commits so readers can't repopulate the cache with uncommitted state.
+func RegisterInvalidation(db *gorm.DB, c *Cache) error {
+	invalidate := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.RowsAffected == 0 {
+			return
+		}
+		table := writtenTable(tx)
+		if table == "" {
+			return
+		}
+
+		ctx := tx.Statement.Context
+		bump := func() {
+			if err := c.Invalidate(ctx, table); err != nil {
+				c.z.Error().Err(err).Str("model", table).Msg("error invalidating cache")
+			}
+		}
+		if t, ok := tx.Statement.ConnPool.(afterCommitter); ok {
+			t.AfterCommit(bump)
+			return
+		}
+		bump()
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:commit_or_rollback_transaction").Register("cache:invalidate_create", invalidate); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:commit_or_rollback_transaction").Register("cache:invalidate_update", invalidate); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:commit_or_rollback_transaction").Register("cache:invalidate_delete", invalidate); err != nil {
+		return err
+	}
+	if err := cb.Raw().After("gorm:raw").Register("cache:invalidate_raw", invalidate); err != nil {
+		return err
+	}
+
+	return nil
+}
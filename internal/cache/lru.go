@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU is an in-process backend bounded by entry count
+type LRU struct {
+	mu          sync.Mutex
+	size        int
+	order       *list.List
+	entries     map[string]*list.Element
+	generations map[string]int64
+}
+
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:        size,
+		order:       list.New(),
+		entries:     make(map[string]*list.Element),
+		generations: make(map[string]int64),
+	}
+}
+
+func (l *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := el.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		l.order.Remove(el)
+		delete(l.entries, key)
+		return nil, false, nil
+	}
+
+	l.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+func (l *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = time.Now().Add(ttl)
+		l.order.MoveToFront(el)
+		return nil
+	}
+
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+
+	// Evict the least recently used entries
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+
+	return nil
+}
+
+func (l *LRU) Generation(ctx context.Context, model string) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.generations[model], nil
+}
+
+func (l *LRU) Bump(ctx context.Context, model string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.generations[model]++
+	return nil
+}
+
+func (l *LRU) Close() error {
+	return nil
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const generationPrefix = "cache:gen:"
+
+// Redis backend, shared by every instance so invalidations apply everywhere
+type Redis struct {
+	client *redis.Client
+}
+
+func NewRedis(ctx context.Context, addr, password string, db int) (*Redis, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &Redis{client: client}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *Redis) Generation(ctx context.Context, model string) (int64, error) {
+	gen, err := r.client.Get(ctx, generationPrefix+model).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return gen, err
+}
+
+func (r *Redis) Bump(ctx context.Context, model string) error {
+	return r.client.Incr(ctx, generationPrefix+model).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,156 @@
+package catalog
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"x/core/internal/persist"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+var ErrDuplicate = errors.New("duplicate catalog entry")
+
+type Concentration string
+
+const (
+	ConcentrationEDT     Concentration = "edt"
+	ConcentrationEDP     Concentration = "edp"
+	ConcentrationParfum  Concentration = "parfum"
+	ConcentrationExtrait Concentration = "extrait"
+)
+
+var Concentrations = []Concentration{ConcentrationEDT, ConcentrationEDP, ConcentrationParfum, ConcentrationExtrait}
+
+type Gender string
+
+const (
+	GenderMasculine Gender = "masculine"
+	GenderFeminine  Gender = "feminine"
+	GenderUnisex    Gender = "unisex"
+)
+
+type Brand struct {
+	persist.Base
+	Name        string `gorm:"not null" json:"name"`
+	Slug        string `gorm:"not null;uniqueIndex:idx_brand_slug,where:deleted_at IS NULL" json:"slug"`
+	Country     string `json:"country,omitempty"`
+	Website     string `json:"website,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type BrandInput struct {
+	Name        string `json:"name" validate:"required,max=120"`
+	Country     string `json:"country,omitempty" validate:"max=80"`
+	Website     string `json:"website,omitempty" validate:"url"`
+	Description string `json:"description,omitempty" validate:"max=4000"`
+}
+
+func (b *Brand) BeforeSave(tx *gorm.DB) error {
+	b.Slug = Slugify(b.Name)
+	return nil
+}
+
+type Note struct {
+	persist.Base
+	Name string `gorm:"not null" json:"name"`
+	Slug string `gorm:"not null;uniqueIndex:idx_note_slug,where:deleted_at IS NULL" json:"slug"`
+	// Olfactive family, e.g. citrus, floral or woody
+	Family string `gorm:"index" json:"family,omitempty"`
+}
+
+type NoteInput struct {
+	Name   string `json:"name" validate:"required,max=80"`
+	Family string `json:"family,omitempty" validate:"max=40"`
+}
+
+func (n *Note) BeforeSave(tx *gorm.DB) error {
+	n.Slug = Slugify(n.Name)
+	return nil
+}
+
+// Fragrance is the canonical catalog entry listings refer to. Entries are
+// identified by brand, normalized name and concentration, so the same
+// fragrance can't be entered twice under slightly different spellings.
+type Fragrance struct {
+	persist.Base
+	BrandID        string        `gorm:"type:uuid;not null;index;uniqueIndex:idx_fragrance_identity,where:deleted_at IS NULL" json:"brand_id"`
+	Brand          *Brand        `json:"brand,omitempty"`
+	Name           string        `gorm:"not null" json:"name"`
+	NormalizedName string        `gorm:"not null;uniqueIndex:idx_fragrance_identity" json:"-"`
+	Slug           string        `gorm:"not null;index" json:"slug"`
+	ReleaseYear    int           `json:"release_year,omitempty"`
+	Perfumer       string        `json:"perfumer,omitempty"`
+	Concentration  Concentration `gorm:"not null;uniqueIndex:idx_fragrance_identity" json:"concentration"`
+	Gender         Gender        `json:"gender,omitempty"`
+	TopNotes       []Note        `gorm:"many2many:fragrance_top_notes" json:"top_notes"`
+	HeartNotes     []Note        `gorm:"many2many:fragrance_heart_notes" json:"heart_notes"`
+	BaseNotes      []Note        `gorm:"many2many:fragrance_base_notes" json:"base_notes"`
+	Accords        []string      `gorm:"serializer:json" json:"accords"`
+	Description    string        `json:"description,omitempty"`
+
+	// Entry this one was merged into as a duplicate
+	CanonicalID *string `gorm:"type:uuid" json:"canonical_id,omitempty"`
+}
+
+func (f *Fragrance) BeforeSave(tx *gorm.DB) error {
+	f.NormalizedName = NormalizeName(f.Name)
+	f.Slug = Slugify(f.Name + " " + string(f.Concentration))
+	return nil
+}
+
+// Note layers of a fragrance by their join tables
+var noteLayers = []struct {
+	Association string
+	JoinTable   string
+}{
+	{"TopNotes", "fragrance_top_notes"},
+	{"HeartNotes", "fragrance_heart_notes"},
+	{"BaseNotes", "fragrance_base_notes"},
+}
+
+var (
+	nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+	// Concentration words in names, the concentration is its own field
+	concentrationWords = regexp.MustCompile(`\b(eau de parfum|eau de toilette|extrait de parfum|edp|edt|extrait|parfum)\b`)
+)
+
+// Lowercase ascii form of a name without accents or punctuation
+func fold(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(s)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return strings.TrimSpace(nonAlphanumeric.ReplaceAllString(b.String(), " "))
+}
+
+// Name used to identify duplicates, e.g. "Sauvage Eau de Parfum" and
+// "sauvage" are the same entry when both are EDPs
+func NormalizeName(name string) string {
+	folded := fold(name)
+	stripped := strings.Join(strings.Fields(concentrationWords.ReplaceAllString(folded, " ")), " ")
+	if stripped == "" {
+		return folded
+	}
+	return stripped
+}
+
+func Slugify(s string) string {
+	return strings.ReplaceAll(fold(s), " ", "-")
+}
+
+// Unique violations are reported as duplicates
+func DuplicateError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
@@ -0,0 +1,173 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"x/core/internal/persist"
+
+	"gorm.io/gorm"
+)
+
+const DefaultDuplicateSimilarity = 0.6
+
+// DuplicateCandidate pairs fragrances of the same brand and concentration
+// whose names are near identical
+type DuplicateCandidate struct {
+	FragranceID   string  `json:"fragrance_id"`
+	FragranceName string  `json:"fragrance_name"`
+	DuplicateID   string  `json:"duplicate_id"`
+	DuplicateName string  `json:"duplicate_name"`
+	Similarity    float64 `json:"similarity"`
+}
+
+// Pairs of live fragrances with trigram similar normalized names, most similar
+// first. Entries with different known release years are never paired.
+func FindDuplicates(db *gorm.DB, threshold float64, limit int) ([]DuplicateCandidate, error) {
+	var candidates []DuplicateCandidate
+	err := db.Raw(`
+		SELECT a.id AS fragrance_id, a.name AS fragrance_name,
+			b.id AS duplicate_id, b.name AS duplicate_name,
+			similarity(a.normalized_name, b.normalized_name) AS similarity
+		FROM fragrances a
+		JOIN fragrances b ON b.brand_id = a.brand_id
+			AND b.concentration = a.concentration
+			AND b.id > a.id
+		WHERE a.deleted_at IS NULL AND b.deleted_at IS NULL
+			AND (a.release_year = 0 OR b.release_year = 0 OR a.release_year = b.release_year)
+			AND similarity(a.normalized_name, b.normalized_name) >= ?
+		ORDER BY similarity DESC, a.id
+		LIMIT ?`, threshold, limit).Scan(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("error finding duplicate fragrances: %v", err)
+	}
+	return candidates, nil
+}
+
+// Reference is a model referencing fragrances through its fragrance_id
+// column. Rows are written through the model so they get a new version and
+// are audited.
+type Reference struct {
+	Model interface{}
+
+	// Columns copied from the fragrance, refreshed on the rows of both
+	// fragrances after a merge
+	Describe func(f *Fragrance) map[string]interface{}
+
+	// Column unique together with fragrance_id among live rows, e.g. the user
+	// of a watch. Live rows of the duplicate conflicting with one of the
+	// canonical entry are soft deleted rather than moved.
+	UniqueWith string
+}
+
+// Merge a duplicate into its canonical fragrance. Notes and accords are
+// combined, details missing on the canonical entry are taken from the
+// duplicate, rows of the referencing tables are moved over and the duplicate
+// is soft deleted. The canonical entry is updated at the version read, a
+// concurrent change fails the merge with persist.ErrConflict.
+func Merge(db *gorm.DB, duplicateID, canonicalID string, references []Reference) (*Fragrance, error) {
+	if duplicateID == canonicalID {
+		return nil, fmt.Errorf("cannot merge fragrance %s into itself", canonicalID)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		duplicate, err := GetFragrance(tx, duplicateID)
+		if err != nil {
+			return fmt.Errorf("fragrance %s: %w", duplicateID, err)
+		}
+		canonical, err := GetFragrance(tx, canonicalID)
+		if err != nil {
+			return fmt.Errorf("fragrance %s: %w", canonicalID, err)
+		}
+
+		// Map updates as struct updates of the optimistically locked model pass
+		// the accords unserialized. Done before the note appends, which save
+		// the canonical entry themselves.
+		updates := map[string]interface{}{}
+		accords := slices.Clone(canonical.Accords)
+		for _, accord := range duplicate.Accords {
+			if !slices.Contains(accords, accord) {
+				accords = append(accords, accord)
+			}
+		}
+		if len(accords) != len(canonical.Accords) {
+			encoded, err := json.Marshal(accords)
+			if err != nil {
+				return err
+			}
+			updates["accords"] = string(encoded)
+		}
+		if canonical.ReleaseYear == 0 && duplicate.ReleaseYear != 0 {
+			updates["release_year"] = duplicate.ReleaseYear
+		}
+		if canonical.Perfumer == "" && duplicate.Perfumer != "" {
+			updates["perfumer"] = duplicate.Perfumer
+		}
+		if canonical.Gender == "" && duplicate.Gender != "" {
+			updates["gender"] = duplicate.Gender
+		}
+		if canonical.Description == "" && duplicate.Description != "" {
+			updates["description"] = duplicate.Description
+		}
+		// Always updated so the version and updated_at move with the merge
+		if err := persist.UpdateRecordByIDIfVersion[Fragrance](tx, canonicalID, canonical.GetVersion(), updates); err != nil {
+			return err
+		}
+
+		target := &Fragrance{Base: persist.Base{ID: canonicalID}}
+		for i, notes := range [][]Note{duplicate.TopNotes, duplicate.HeartNotes, duplicate.BaseNotes} {
+			if len(notes) == 0 {
+				continue
+			}
+			if err := tx.Model(target).Association(noteLayers[i].Association).Append(notes); err != nil {
+				return err
+			}
+		}
+
+		merged, err := GetFragrance(tx, canonicalID)
+		if err != nil {
+			return err
+		}
+		for _, ref := range references {
+			name := reflect.TypeOf(ref.Model).Elem().Name()
+			if ref.UniqueWith != "" {
+				err := tx.Where("fragrance_id = ?", duplicateID).
+					Where(fmt.Sprintf("%s IN (?)", ref.UniqueWith), tx.Model(ref.Model).
+						Select(ref.UniqueWith).
+						Where("fragrance_id = ?", canonicalID)).
+					Delete(ref.Model).Error
+				if err != nil {
+					return fmt.Errorf("error dropping %s conflicting with fragrance %s: %v", name, canonicalID, err)
+				}
+			}
+
+			// Soft deleted rows are moved too, the duplicate is going away.
+			// The version of the model is bumped by the optimistic lock.
+			updates := map[string]interface{}{"fragrance_id": canonicalID}
+			if err := tx.Unscoped().Model(ref.Model).Where("fragrance_id = ?", duplicateID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("error moving %s to fragrance %s: %v", name, canonicalID, err)
+			}
+			if ref.Describe == nil {
+				continue
+			}
+			if err := tx.Unscoped().Model(ref.Model).Where("fragrance_id = ?", canonicalID).Updates(ref.Describe(merged)).Error; err != nil {
+				return fmt.Errorf("error refreshing %s of fragrance %s: %v", name, canonicalID, err)
+			}
+		}
+
+		result := tx.Model(duplicate).Update("canonical_id", canonicalID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return persist.ErrConflict
+		}
+		return tx.Delete(duplicate).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetFragrance(db, canonicalID)
+}
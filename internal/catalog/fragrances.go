@@ -0,0 +1,201 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"x/core/internal/persist"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FragranceInput creates or replaces a fragrance, notes are referenced by ID
+type FragranceInput struct {
+	BrandID       string        `json:"brand_id" validate:"required"`
+	Name          string        `json:"name" validate:"required,max=200"`
+	ReleaseYear   int           `json:"release_year,omitempty" validate:"min=1700,max=2100"`
+	Perfumer      string        `json:"perfumer,omitempty" validate:"max=200"`
+	Concentration Concentration `json:"concentration" validate:"required,oneof=edt edp parfum extrait"`
+	Gender        Gender        `json:"gender,omitempty" validate:"oneof=masculine feminine unisex"`
+	TopNoteIDs    []string      `json:"top_note_ids,omitempty"`
+	HeartNoteIDs  []string      `json:"heart_note_ids,omitempty"`
+	BaseNoteIDs   []string      `json:"base_note_ids,omitempty"`
+	Accords       []string      `json:"accords,omitempty"`
+	Description   string        `json:"description,omitempty" validate:"max=4000"`
+}
+
+func (in FragranceInput) accords() []string {
+	if in.Accords == nil {
+		return []string{}
+	}
+	return in.Accords
+}
+
+type FragranceFilter struct {
+	BrandID       string
+	Concentration Concentration
+	Gender        Gender
+	NoteID        string
+	ReleaseYear   int
+}
+
+func preloadFragrance(db *gorm.DB) *gorm.DB {
+	return db.Preload("Brand").Preload("TopNotes").Preload("HeartNotes").Preload("BaseNotes")
+}
+
+func GetFragrance(db *gorm.DB, id string) (*Fragrance, error) {
+	var f Fragrance
+	if err := preloadFragrance(db).Where("id = ?", id).First(&f).Error; err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Page of fragrances matching the filter, ordered by brand and name
+func ListFragrances(db *gorm.DB, filter FragranceFilter, page, pageSize int) ([]Fragrance, int, error) {
+	query := db.Model(&Fragrance{})
+	if filter.BrandID != "" {
+		query = query.Where("brand_id = ?", filter.BrandID)
+	}
+	if filter.Concentration != "" {
+		query = query.Where("concentration = ?", filter.Concentration)
+	}
+	if filter.Gender != "" {
+		query = query.Where("gender = ?", filter.Gender)
+	}
+	if filter.ReleaseYear != 0 {
+		query = query.Where("release_year = ?", filter.ReleaseYear)
+	}
+	if filter.NoteID != "" {
+		var layers []string
+		var vars []interface{}
+		for _, layer := range noteLayers {
+			layers = append(layers, fmt.Sprintf("SELECT fragrance_id FROM %s WHERE note_id = ?", layer.JoinTable))
+			vars = append(vars, filter.NoteID)
+		}
+		query = query.Where("id IN ("+strings.Join(layers, " UNION ")+")", vars...)
+	}
+
+	var totalRecords int64
+	if err := query.Session(&gorm.Session{}).Count(&totalRecords).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var records []Fragrance
+	offset := (page - 1) * pageSize
+	if err := preloadFragrance(query).Order("brand_id, normalized_name").Offset(offset).Limit(pageSize).Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+
+	totalPages := int((totalRecords + int64(pageSize) - 1) / int64(pageSize))
+	return records, totalPages, nil
+}
+
+// Notes with the given IDs, all of which must exist
+func notesByID(tx *gorm.DB, ids []string) ([]Note, error) {
+	if len(ids) == 0 {
+		return []Note{}, nil
+	}
+
+	var notes []Note
+	if err := tx.Where("id IN ?", ids).Find(&notes).Error; err != nil {
+		return nil, err
+	}
+	if len(notes) != len(ids) {
+		return nil, fmt.Errorf("unknown note in %v: %w", ids, gorm.ErrRecordNotFound)
+	}
+	return notes, nil
+}
+
+// Replace the notes of every layer
+func setNotes(tx *gorm.DB, f *Fragrance, in FragranceInput) error {
+	for i, ids := range [][]string{in.TopNoteIDs, in.HeartNoteIDs, in.BaseNoteIDs} {
+		notes, err := notesByID(tx, ids)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(f).Association(noteLayers[i].Association).Replace(notes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func CreateFragrance(db *gorm.DB, in FragranceInput) (*Fragrance, error) {
+	f := &Fragrance{
+		BrandID:       in.BrandID,
+		Name:          in.Name,
+		ReleaseYear:   in.ReleaseYear,
+		Perfumer:      in.Perfumer,
+		Concentration: in.Concentration,
+		Gender:        in.Gender,
+		Accords:       in.accords(),
+		Description:   in.Description,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := persist.GetRecordByID[Brand](tx, in.BrandID); err != nil {
+			return fmt.Errorf("brand %s: %w", in.BrandID, err)
+		}
+
+		// Report the existing entry rather than a bare constraint violation
+		var existing Fragrance
+		err := tx.Where("brand_id = ? AND normalized_name = ? AND concentration = ?", in.BrandID, NormalizeName(in.Name), in.Concentration).
+			Take(&existing).Error
+		if err == nil {
+			return fmt.Errorf("%w: matches fragrance %s", ErrDuplicate, existing.ID)
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		if err := tx.Omit(clause.Associations).Create(f).Error; err != nil {
+			return DuplicateError(err)
+		}
+		return setNotes(tx, f, in)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetFragrance(db, f.ID)
+}
+
+// Replace a fragrance if it is still at the expected version
+func UpdateFragrance(db *gorm.DB, id string, version int64, in FragranceInput) (*Fragrance, error) {
+	accords, err := json.Marshal(in.accords())
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if _, err := persist.GetRecordByID[Brand](tx, in.BrandID); err != nil {
+			return fmt.Errorf("brand %s: %w", in.BrandID, err)
+		}
+
+		// Map updates so cleared fields are written too
+		err := persist.UpdateRecordByIDIfVersion[Fragrance](tx, id, version, map[string]interface{}{
+			"brand_id":        in.BrandID,
+			"name":            in.Name,
+			"normalized_name": NormalizeName(in.Name),
+			"slug":            Slugify(in.Name + " " + string(in.Concentration)),
+			"release_year":    in.ReleaseYear,
+			"perfumer":        in.Perfumer,
+			"concentration":   in.Concentration,
+			"gender":          in.Gender,
+			"accords":         string(accords),
+			"description":     in.Description,
+		})
+		if err != nil {
+			return DuplicateError(err)
+		}
+
+		return setNotes(tx, &Fragrance{Base: persist.Base{ID: id}}, in)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetFragrance(db, id)
+}
@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type Database struct {
 	Name           string `mapstructure:"CORE_DB_NAME"`
 	User           string `mapstructure:"CORE_DB_USER"`
@@ -7,17 +9,48 @@ type Database struct {
 	Port           string `mapstructure:"CORE_DB_PORT"`
 	Host           string `mapstructure:"CORE_DB_HOST"`
 	MaxConnections int64  `mapstructure:"CORE_DB_MAX_CONN"`
+
+	// TLS
+	SSLMode     string `mapstructure:"CORE_DB_SSLMODE"`
+	SSLRootCert string `mapstructure:"CORE_DB_SSLROOTCERT"`
+	SSLCert     string `mapstructure:"CORE_DB_SSLCERT"`
+	SSLKey      string `mapstructure:"CORE_DB_SSLKEY"`
+
+	// Session settings
+	ApplicationName  string        `mapstructure:"CORE_DB_APPLICATION_NAME"`
+	SearchPath       string        `mapstructure:"CORE_DB_SEARCH_PATH"`
+	StatementTimeout time.Duration `mapstructure:"CORE_DB_STATEMENT_TIMEOUT"`
+
+	// Pool tuning
+	MaxIdleConnections int64         `mapstructure:"CORE_DB_MAX_IDLE_CONN"`
+	ConnMaxLifetime    time.Duration `mapstructure:"CORE_DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime    time.Duration `mapstructure:"CORE_DB_CONN_MAX_IDLE_TIME"`
+
+	// Startup connection retries
+	ConnectRetries int64         `mapstructure:"CORE_DB_CONNECT_RETRIES"`
+	ConnectBackoff time.Duration `mapstructure:"CORE_DB_CONNECT_BACKOFF"`
+
+	// Read replicas as host or host:port, used for read-only queries
+	ReplicaHosts []string `mapstructure:"CORE_DB_REPLICA_HOSTS"`
 }
 
 type HTTPServer struct {
 	ServerHost               string `mapstructure:"CORE_SERVER_HOST"`
 	ServerPort               string `mapstructure:"CORE_SERVER_PORT"`
-	ServerAllowedOriginLocal string `mapstructure:"CORE_ALLOWED_ORIGIN_LOCAL"`
-	ServerAllowedOriginProd  string `mapstructure:"CORE_ALLOWED_ORIGIN_PROD"`
+	ServerAllowedOriginLocal string `mapstructure:"CORE_ALLOWED_ORIGIN_LOCAL" reload:"mutable"`
+	ServerAllowedOriginProd  string `mapstructure:"CORE_ALLOWED_ORIGIN_PROD" reload:"mutable"`
+
+	// Port serving prometheus metrics, disabled when empty
+	MetricsPort string `mapstructure:"CORE_METRICS_PORT"`
+
+	// Largest request body accepted, in bytes
+	MaxBodyBytes int64 `mapstructure:"CORE_MAX_BODY_BYTES" reload:"mutable"`
 }
 
 type ClerkConfig struct {
 	APIKey string `mapstructure:"CORE_CLERK_KEY"`
+	// Clerk user IDs allowed to use the admin API
+	AdminUserIDs []string `mapstructure:"CORE_ADMIN_USER_IDS" reload:"mutable"`
 }
 
 type GoogleConfig struct {
@@ -31,7 +64,105 @@ type Cloudinary struct {
 
 type Sentry struct {
 	DSN        string  `mapstructure:"CORE_SENTRY_DSN"`
-	SampleRate float64 `mapstructure:"CORE_SENTRY_SAMPLE_RATE"`
+	SampleRate float64 `mapstructure:"CORE_SENTRY_SAMPLE_RATE" reload:"mutable"`
+	// Share of sampled transactions profiled, fixed at startup as the client
+	// reads it once. Defaults to the sample rate.
+	ProfilesSampleRate float64 `mapstructure:"CORE_SENTRY_PROFILES_SAMPLE_RATE"`
+}
+
+type RateLimit struct {
+	RequestsPerSecond float64 `mapstructure:"CORE_RATE_LIMIT_RPS" reload:"mutable"`
+	Burst             int     `mapstructure:"CORE_RATE_LIMIT_BURST" reload:"mutable"`
+}
+
+type Logging struct {
+	Level string `mapstructure:"CORE_LOG_LEVEL" reload:"mutable"`
+}
+
+type Features struct {
+	// Comma separated list of enabled feature flags
+	Flags string `mapstructure:"CORE_FEATURE_FLAGS" reload:"mutable"`
+}
+
+type Retention struct {
+	// How long soft deleted rows are kept before being purged, for models
+	// without a retention of their own. Defaults to 90 days, 0 keeps them.
+	SoftDeleteRetention time.Duration `mapstructure:"CORE_SOFT_DELETE_RETENTION"`
+	PurgeInterval       time.Duration `mapstructure:"CORE_PURGE_INTERVAL"`
+}
+
+type Marketplace struct {
+	// How long listings stay active after being published
+	ListingTTL            time.Duration `mapstructure:"CORE_LISTING_TTL" reload:"mutable"`
+	ListingExpiryInterval time.Duration `mapstructure:"CORE_LISTING_EXPIRY_INTERVAL"`
+	OfferExpiryInterval   time.Duration `mapstructure:"CORE_OFFER_EXPIRY_INTERVAL"`
+	// How often shipments in progress are checked with the carrier
+	TrackingInterval time.Duration `mapstructure:"CORE_TRACKING_INTERVAL"`
+	// Commission kept from every order, in basis points of the subtotal plus
+	// a fixed amount in minor currency units
+	CommissionBPS   int64 `mapstructure:"CORE_COMMISSION_BPS"`
+	CommissionFixed int64 `mapstructure:"CORE_COMMISSION_FIXED"`
+	// How long after completion orders can be reviewed, and how long reviews
+	// stay editable
+	ReviewWindow     time.Duration `mapstructure:"CORE_REVIEW_WINDOW" reload:"mutable"`
+	ReviewEditWindow time.Duration `mapstructure:"CORE_REVIEW_EDIT_WINDOW" reload:"mutable"`
+	// Age at which a review counts half as much towards reputation
+	ReputationHalfLife time.Duration `mapstructure:"CORE_REPUTATION_HALF_LIFE" reload:"mutable"`
+	// How often listings are matched against watches and saved searches,
+	// and how many alerts a user is notified of per window
+	AlertMatchInterval time.Duration `mapstructure:"CORE_ALERT_MATCH_INTERVAL"`
+	AlertRateLimit     int           `mapstructure:"CORE_ALERT_RATE_LIMIT" reload:"mutable"`
+	AlertRateWindow    time.Duration `mapstructure:"CORE_ALERT_RATE_WINDOW" reload:"mutable"`
+}
+
+type Payments struct {
+	// fake or stripe
+	Provider            string `mapstructure:"CORE_PAYMENT_PROVIDER"`
+	StripeURL           string `mapstructure:"CORE_STRIPE_API_URL"`
+	StripeSecretKey     string `mapstructure:"CORE_STRIPE_SECRET_KEY"`
+	StripeWebhookSecret string `mapstructure:"CORE_STRIPE_WEBHOOK_SECRET"`
+}
+
+type Shipping struct {
+	// fake or carrier
+	Provider      string `mapstructure:"CORE_SHIPPING_PROVIDER"`
+	CarrierURL    string `mapstructure:"CORE_CARRIER_API_URL"`
+	CarrierAPIKey string `mapstructure:"CORE_CARRIER_API_KEY"`
+}
+
+type Realtime struct {
+	// How often idle realtime connections are sent a heartbeat
+	HeartbeatInterval time.Duration `mapstructure:"CORE_REALTIME_HEARTBEAT" reload:"mutable"`
+	// Events buffered per connection before a slow client is disconnected
+	BufferSize int `mapstructure:"CORE_REALTIME_BUFFER"`
+}
+
+type Cache struct {
+	// memory, redis or empty to disable caching
+	Backend       string        `mapstructure:"CORE_CACHE_BACKEND"`
+	TTL           time.Duration `mapstructure:"CORE_CACHE_TTL"`
+	Size          int           `mapstructure:"CORE_CACHE_SIZE"`
+	RedisAddr     string        `mapstructure:"CORE_REDIS_ADDR"`
+	RedisPassword string        `mapstructure:"CORE_REDIS_PASSWORD"`
+	RedisDB       int           `mapstructure:"CORE_REDIS_DB"`
+}
+
+type CORS struct {
+	// Exact origins or patterns with a single wildcard, e.g. https://*.example.com
+	AllowedOrigins   []string      `mapstructure:"CORE_CORS_ALLOWED_ORIGINS" reload:"mutable"`
+	AllowedMethods   []string      `mapstructure:"CORE_CORS_ALLOWED_METHODS" reload:"mutable"`
+	AllowedHeaders   []string      `mapstructure:"CORE_CORS_ALLOWED_HEADERS" reload:"mutable"`
+	ExposedHeaders   []string      `mapstructure:"CORE_CORS_EXPOSED_HEADERS" reload:"mutable"`
+	MaxAge           time.Duration `mapstructure:"CORE_CORS_MAX_AGE" reload:"mutable"`
+	AllowCredentials *bool         `mapstructure:"CORE_CORS_ALLOW_CREDENTIALS" reload:"mutable"`
+}
+
+type Security struct {
+	// Strict-Transport-Security max age, sent over https outside local
+	HSTSMaxAge            time.Duration `mapstructure:"CORE_HSTS_MAX_AGE" reload:"mutable"`
+	ContentSecurityPolicy string        `mapstructure:"CORE_CONTENT_SECURITY_POLICY" reload:"mutable"`
+	FrameOptions          string        `mapstructure:"CORE_FRAME_OPTIONS" reload:"mutable"`
+	ReferrerPolicy        string        `mapstructure:"CORE_REFERRER_POLICY" reload:"mutable"`
 }
 
 type Config struct {
@@ -50,4 +181,37 @@ type Config struct {
 
 	// Sentry monitoring
 	Sentry Sentry `mapstructure:",squash"`
+
+	// Rate limiting policy
+	RateLimit RateLimit `mapstructure:",squash"`
+
+	// Logging
+	Logging Logging `mapstructure:",squash"`
+
+	// Feature flags
+	Features Features `mapstructure:",squash"`
+
+	// Soft delete retention
+	Retention Retention `mapstructure:",squash"`
+
+	// Marketplace policies
+	Marketplace Marketplace `mapstructure:",squash"`
+
+	// Payment provider
+	Payments Payments `mapstructure:",squash"`
+
+	// Shipping provider
+	Shipping Shipping `mapstructure:",squash"`
+
+	// Realtime gateway
+	Realtime Realtime `mapstructure:",squash"`
+
+	// Query result caching
+	Cache Cache `mapstructure:",squash"`
+
+	// Cross origin requests
+	CORS CORS `mapstructure:",squash"`
+
+	// Security response headers
+	Security Security `mapstructure:",squash"`
 }
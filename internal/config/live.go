@@ -0,0 +1,381 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultRateLimitRPS   = 3
+	defaultRateLimitBurst = 6
+	defaultPurgeInterval  = time.Hour
+	defaultRetention      = 90 * 24 * time.Hour
+	defaultListingTTL     = 90 * 24 * time.Hour
+	defaultListingExpiry  = 15 * time.Minute
+	defaultOfferExpiry    = time.Minute
+	defaultCommissionBPS  = 1000
+	defaultTracking       = 30 * time.Minute
+	defaultReviewWindow   = 60 * 24 * time.Hour
+	defaultReviewEdit     = 7 * 24 * time.Hour
+	defaultHalfLife       = 180 * 24 * time.Hour
+	defaultAlertMatch     = time.Minute
+	defaultAlertRateLimit = 10
+	defaultAlertWindow    = time.Hour
+	defaultHeartbeat      = 25 * time.Second
+	defaultRealtimeBuffer = 64
+	defaultCacheTTL       = time.Minute
+	defaultCacheSize      = 10000
+	defaultMaxBodyBytes   = 1 << 20
+	defaultCORSMaxAge     = 10 * time.Minute
+	defaultHSTSMaxAge     = 365 * 24 * time.Hour
+	defaultCSP            = "default-src 'none'; frame-ancestors 'none'"
+	defaultFrameOptions   = "DENY"
+	defaultReferrerPolicy = "strict-origin-when-cross-origin"
+)
+
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+)
+
+const (
+	PaymentProviderFake   = "fake"
+	PaymentProviderStripe = "stripe"
+)
+
+const (
+	ShippingProviderFake    = "fake"
+	ShippingProviderCarrier = "carrier"
+)
+
+// setting is a single field of the config compared during a reload.
+// Settings are immutable unless tagged reload:"mutable".
+type setting struct {
+	name    string
+	index   []int
+	mutable bool
+}
+
+// Every setting of the config, derived from the mapstructure tags so none
+// can be left unclassified
+var settings = collectSettings(reflect.TypeOf(Config{}), nil)
+
+func collectSettings(t reflect.Type, index []int) []setting {
+	var out []setting
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		path := append(append([]int{}, index...), i)
+		if name == "" {
+			if f.Type.Kind() == reflect.Struct {
+				out = append(out, collectSettings(f.Type, path)...)
+			}
+			continue
+		}
+		out = append(out, setting{name: name, index: path, mutable: f.Tag.Get("reload") == "mutable"})
+	}
+	return out
+}
+
+func (s setting) value(c Config) any {
+	return reflect.ValueOf(c).FieldByIndex(s.index).Interface()
+}
+
+// Fill in defaults for settings that weren't configured. isSet reports
+// whether a setting was, so zero values like a 0 commission can be set
+// explicitly. Without it zero values are taken as unset.
+func (c *Config) ApplyDefaults(isSet func(key string) bool) {
+	unset := func(key string, zero bool) bool {
+		if isSet == nil {
+			return zero
+		}
+		return !isSet(key)
+	}
+
+	if unset("CORE_RATE_LIMIT_RPS", c.RateLimit.RequestsPerSecond == 0) {
+		c.RateLimit.RequestsPerSecond = defaultRateLimitRPS
+	}
+	if unset("CORE_RATE_LIMIT_BURST", c.RateLimit.Burst == 0) {
+		c.RateLimit.Burst = defaultRateLimitBurst
+	}
+	if unset("CORE_SENTRY_PROFILES_SAMPLE_RATE", c.Sentry.ProfilesSampleRate == 0) {
+		c.Sentry.ProfilesSampleRate = c.Sentry.SampleRate
+	}
+	if unset("CORE_SOFT_DELETE_RETENTION", c.Retention.SoftDeleteRetention == 0) {
+		c.Retention.SoftDeleteRetention = defaultRetention
+	}
+	if unset("CORE_PURGE_INTERVAL", c.Retention.PurgeInterval == 0) {
+		c.Retention.PurgeInterval = defaultPurgeInterval
+	}
+	if unset("CORE_LISTING_TTL", c.Marketplace.ListingTTL == 0) {
+		c.Marketplace.ListingTTL = defaultListingTTL
+	}
+	if unset("CORE_LISTING_EXPIRY_INTERVAL", c.Marketplace.ListingExpiryInterval == 0) {
+		c.Marketplace.ListingExpiryInterval = defaultListingExpiry
+	}
+	if unset("CORE_OFFER_EXPIRY_INTERVAL", c.Marketplace.OfferExpiryInterval == 0) {
+		c.Marketplace.OfferExpiryInterval = defaultOfferExpiry
+	}
+	if unset("CORE_COMMISSION_BPS", c.Marketplace.CommissionBPS == 0) {
+		c.Marketplace.CommissionBPS = defaultCommissionBPS
+	}
+	// Outside local the provider has to be chosen, see Validate
+	if c.Env == "local" && unset("CORE_PAYMENT_PROVIDER", c.Payments.Provider == "") {
+		c.Payments.Provider = PaymentProviderFake
+	}
+	if unset("CORE_TRACKING_INTERVAL", c.Marketplace.TrackingInterval == 0) {
+		c.Marketplace.TrackingInterval = defaultTracking
+	}
+	if unset("CORE_REVIEW_WINDOW", c.Marketplace.ReviewWindow == 0) {
+		c.Marketplace.ReviewWindow = defaultReviewWindow
+	}
+	if unset("CORE_REVIEW_EDIT_WINDOW", c.Marketplace.ReviewEditWindow == 0) {
+		c.Marketplace.ReviewEditWindow = defaultReviewEdit
+	}
+	if unset("CORE_REPUTATION_HALF_LIFE", c.Marketplace.ReputationHalfLife == 0) {
+		c.Marketplace.ReputationHalfLife = defaultHalfLife
+	}
+	if unset("CORE_ALERT_MATCH_INTERVAL", c.Marketplace.AlertMatchInterval == 0) {
+		c.Marketplace.AlertMatchInterval = defaultAlertMatch
+	}
+	if unset("CORE_ALERT_RATE_LIMIT", c.Marketplace.AlertRateLimit == 0) {
+		c.Marketplace.AlertRateLimit = defaultAlertRateLimit
+	}
+	if unset("CORE_ALERT_RATE_WINDOW", c.Marketplace.AlertRateWindow == 0) {
+		c.Marketplace.AlertRateWindow = defaultAlertWindow
+	}
+	if unset("CORE_REALTIME_HEARTBEAT", c.Realtime.HeartbeatInterval == 0) {
+		c.Realtime.HeartbeatInterval = defaultHeartbeat
+	}
+	if unset("CORE_REALTIME_BUFFER", c.Realtime.BufferSize == 0) {
+		c.Realtime.BufferSize = defaultRealtimeBuffer
+	}
+	if unset("CORE_SHIPPING_PROVIDER", c.Shipping.Provider == "") {
+		c.Shipping.Provider = ShippingProviderFake
+	}
+	if unset("CORE_CACHE_TTL", c.Cache.TTL == 0) {
+		c.Cache.TTL = defaultCacheTTL
+	}
+	if unset("CORE_CACHE_SIZE", c.Cache.Size == 0) {
+		c.Cache.Size = defaultCacheSize
+	}
+	if unset("CORE_MAX_BODY_BYTES", c.HTTPServer.MaxBodyBytes == 0) {
+		c.HTTPServer.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if unset("CORE_CORS_ALLOWED_METHODS", len(c.CORS.AllowedMethods) == 0) {
+		c.CORS.AllowedMethods = []string{"GET", "HEAD", "POST", "PATCH", "PUT", "DELETE"}
+	}
+	if unset("CORE_CORS_ALLOWED_HEADERS", len(c.CORS.AllowedHeaders) == 0) {
+		c.CORS.AllowedHeaders = []string{"Authorization", "Content-Type", "If-Match", "If-None-Match", "X-Request-ID", "X-CSRF-Token", "Accept-Version"}
+	}
+	if unset("CORE_CORS_EXPOSED_HEADERS", len(c.CORS.ExposedHeaders) == 0) {
+		c.CORS.ExposedHeaders = []string{"ETag", "X-Request-ID", "API-Version", "Deprecation", "Sunset", "Link"}
+	}
+	if unset("CORE_CORS_MAX_AGE", c.CORS.MaxAge == 0) {
+		c.CORS.MaxAge = defaultCORSMaxAge
+	}
+	if c.CORS.AllowCredentials == nil {
+		allow := true
+		c.CORS.AllowCredentials = &allow
+	}
+	if unset("CORE_HSTS_MAX_AGE", c.Security.HSTSMaxAge == 0) {
+		c.Security.HSTSMaxAge = defaultHSTSMaxAge
+	}
+	if unset("CORE_CONTENT_SECURITY_POLICY", c.Security.ContentSecurityPolicy == "") {
+		c.Security.ContentSecurityPolicy = defaultCSP
+	}
+	if unset("CORE_FRAME_OPTIONS", c.Security.FrameOptions == "") {
+		c.Security.FrameOptions = defaultFrameOptions
+	}
+	if unset("CORE_REFERRER_POLICY", c.Security.ReferrerPolicy == "") {
+		c.Security.ReferrerPolicy = defaultReferrerPolicy
+	}
+}
+
+// Validate the settings that can be changed at runtime
+func (c Config) Validate() error {
+	if c.RateLimit.RequestsPerSecond <= 0 {
+		return fmt.Errorf("invalid rate limit: %v requests per second", c.RateLimit.RequestsPerSecond)
+	}
+	if c.RateLimit.Burst < 0 {
+		return fmt.Errorf("invalid rate limit burst: %d", c.RateLimit.Burst)
+	}
+	if c.Retention.SoftDeleteRetention < 0 {
+		return fmt.Errorf("invalid soft delete retention: %v", c.Retention.SoftDeleteRetention)
+	}
+	if c.HTTPServer.MaxBodyBytes < 0 {
+		return fmt.Errorf("invalid max body size: %d bytes", c.HTTPServer.MaxBodyBytes)
+	}
+	if c.Marketplace.ListingTTL <= 0 {
+		return fmt.Errorf("invalid listing ttl: %v", c.Marketplace.ListingTTL)
+	}
+	if c.Marketplace.CommissionBPS < 0 || c.Marketplace.CommissionBPS > 10000 {
+		return fmt.Errorf("invalid commission: %d basis points", c.Marketplace.CommissionBPS)
+	}
+	if c.Marketplace.CommissionFixed < 0 {
+		return fmt.Errorf("invalid fixed commission: %d", c.Marketplace.CommissionFixed)
+	}
+	if c.Marketplace.ReviewWindow < 0 || c.Marketplace.ReviewEditWindow < 0 {
+		return fmt.Errorf("invalid review windows: %v, %v", c.Marketplace.ReviewWindow, c.Marketplace.ReviewEditWindow)
+	}
+	if c.Marketplace.ReputationHalfLife <= 0 {
+		return fmt.Errorf("invalid reputation half life: %v", c.Marketplace.ReputationHalfLife)
+	}
+	if c.Marketplace.AlertRateLimit < 0 || c.Marketplace.AlertRateWindow <= 0 {
+		return fmt.Errorf("invalid alert rate limit: %d per %v", c.Marketplace.AlertRateLimit, c.Marketplace.AlertRateWindow)
+	}
+	if c.Realtime.HeartbeatInterval <= 0 {
+		return fmt.Errorf("invalid realtime heartbeat: %v", c.Realtime.HeartbeatInterval)
+	}
+	if c.Realtime.BufferSize <= 0 {
+		return fmt.Errorf("invalid realtime buffer size: %d", c.Realtime.BufferSize)
+	}
+	if c.Sentry.SampleRate < 0 || c.Sentry.SampleRate > 1 {
+		return fmt.Errorf("invalid sentry sample rate: %v", c.Sentry.SampleRate)
+	}
+	if c.Sentry.ProfilesSampleRate < 0 || c.Sentry.ProfilesSampleRate > 1 {
+		return fmt.Errorf("invalid sentry profiles sample rate: %v", c.Sentry.ProfilesSampleRate)
+	}
+	if _, err := c.LogLevel(); err != nil {
+		return err
+	}
+	for _, origin := range c.AllowedOrigins() {
+		if strings.Count(origin, "*") > 1 {
+			return fmt.Errorf("invalid cors origin %s: only one wildcard is allowed", origin)
+		}
+		if origin == "*" && c.CORS.AllowCredentials != nil && *c.CORS.AllowCredentials {
+			return fmt.Errorf("invalid cors origin %s: credentials cannot be allowed for any origin", origin)
+		}
+	}
+	switch c.Cache.Backend {
+	case "", CacheBackendMemory, CacheBackendRedis:
+	default:
+		return fmt.Errorf("invalid cache backend: %s", c.Cache.Backend)
+	}
+	if c.Cache.Backend != "" && c.Cache.TTL <= 0 {
+		return fmt.Errorf("invalid cache ttl: %v", c.Cache.TTL)
+	}
+	if c.Cache.Backend == CacheBackendMemory && c.Cache.Size <= 0 {
+		return fmt.Errorf("invalid cache size: %d", c.Cache.Size)
+	}
+	switch c.Payments.Provider {
+	case "", PaymentProviderFake:
+		// Fake payments are never collected and their webhook events are unsigned
+		if c.Env != "local" {
+			return fmt.Errorf("fake payments are only allowed when CORE_ENV is local, set CORE_PAYMENT_PROVIDER")
+		}
+	case PaymentProviderStripe:
+		if c.Payments.StripeSecretKey == "" || c.Payments.StripeWebhookSecret == "" {
+			return fmt.Errorf("stripe payments require CORE_STRIPE_SECRET_KEY and CORE_STRIPE_WEBHOOK_SECRET")
+		}
+	default:
+		return fmt.Errorf("invalid payment provider: %s", c.Payments.Provider)
+	}
+	switch c.Shipping.Provider {
+	case "", ShippingProviderFake:
+	case ShippingProviderCarrier:
+		if c.Shipping.CarrierURL == "" || c.Shipping.CarrierAPIKey == "" {
+			return fmt.Errorf("carrier shipping requires CORE_CARRIER_API_URL and CORE_CARRIER_API_KEY")
+		}
+	default:
+		return fmt.Errorf("invalid shipping provider: %s", c.Shipping.Provider)
+	}
+
+	return nil
+}
+
+// Parsed log level, defaults to info
+func (c Config) LogLevel() (zerolog.Level, error) {
+	if c.Logging.Level == "" {
+		return zerolog.InfoLevel, nil
+	}
+
+	level, err := zerolog.ParseLevel(strings.ToLower(c.Logging.Level))
+	if err != nil {
+		return zerolog.NoLevel, fmt.Errorf("invalid log level: %s", c.Logging.Level)
+	}
+	return level, nil
+}
+
+// Allowed cross origin request origins, the configured list and patterns
+// plus the local and prod origins
+func (c Config) AllowedOrigins() []string {
+	var origins []string
+	for _, o := range append([]string{c.HTTPServer.ServerAllowedOriginLocal, c.HTTPServer.ServerAllowedOriginProd}, c.CORS.AllowedOrigins...) {
+		if o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// Live holds the running configuration. Safe-to-change settings are swapped
+// atomically on reload so running middleware always sees a consistent view.
+type Live struct {
+	mu        sync.Mutex
+	current   atomic.Pointer[Config]
+	listeners []func(prev, next Config)
+}
+
+func NewLive(c Config) *Live {
+	l := &Live{}
+	l.current.Store(&c)
+	return l
+}
+
+// Current configuration snapshot
+func (l *Live) Load() Config {
+	return *l.current.Load()
+}
+
+// Register a listener called after every successful reload
+func (l *Live) OnChange(fn func(prev, next Config)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.listeners = append(l.listeners, fn)
+}
+
+// Validate and swap in a new configuration with its defaults applied.
+// Changes to immutable settings are rejected and leave the running
+// configuration untouched. Returns the names of the settings that changed.
+func (l *Live) Reload(next Config) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	prev := l.Load()
+
+	var rejected, changed []string
+	for _, f := range settings {
+		if reflect.DeepEqual(f.value(prev), f.value(next)) {
+			continue
+		}
+		if f.mutable {
+			changed = append(changed, f.name)
+		} else {
+			rejected = append(rejected, f.name)
+		}
+	}
+	if len(rejected) > 0 {
+		return nil, fmt.Errorf("cannot change immutable settings at runtime: %s", strings.Join(rejected, ", "))
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	l.current.Store(&next)
+	for _, fn := range l.listeners {
+		fn(prev, next)
+	}
+
+	return changed, nil
+}
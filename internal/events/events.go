@@ -0,0 +1,118 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type Type string
+
+// Event is a domain change other parts of the system react to, e.g. to
+// notify the users involved
+type Event struct {
+	ID           string      `json:"id"`
+	Type         Type        `json:"type"`
+	ResourceType string      `json:"resource_type"`
+	ResourceID   string      `json:"resource_id"`
+	Data         interface{} `json:"data,omitempty"`
+	OccurredAt   time.Time   `json:"occurred_at"`
+
+	// Users the event concerns, e.g. the recipients of notifications
+	UserIDs []string `json:"user_ids,omitempty"`
+}
+
+func New(t Type, resourceType, resourceID string, data interface{}, userIDs ...string) Event {
+	return Event{
+		ID:           uuid.NewString(),
+		Type:         t,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		UserIDs:      userIDs,
+		Data:         data,
+		OccurredAt:   time.Now(),
+	}
+}
+
+// Concerns reports whether the event is addressed to the user
+func (e Event) Concerns(userID string) bool {
+	return slices.Contains(e.UserIDs, userID)
+}
+
+// Handler reacts to a published event. Handlers run on the publishing
+// goroutine and must hand off slow work.
+type Handler func(ctx context.Context, e Event)
+
+type subscription struct {
+	id      uint64
+	types   []Type
+	handler Handler
+}
+
+// Bus delivers published events to the subscribers of their type
+type Bus struct {
+	z      *zerolog.Logger
+	mu     sync.RWMutex
+	nextID uint64
+	subs   []subscription
+}
+
+func NewBus(logger *zerolog.Logger) *Bus {
+	return &Bus{
+		z: logger,
+	}
+}
+
+// Subscribe to events of the given types, or every event when none are
+// given. The returned function removes the subscription.
+func (b *Bus) Subscribe(handler Handler, types ...Type) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	b.subs = append(b.subs, subscription{id: id, types: types, handler: handler})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.subs = slices.DeleteFunc(b.subs, func(s subscription) bool { return s.id == id })
+	}
+}
+
+// Publish events to their subscribers. Events should only be published once
+// the changes they describe are committed.
+func (b *Bus) Publish(ctx context.Context, events ...Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	subs := slices.Clone(b.subs)
+	b.mu.RUnlock()
+
+	for _, e := range events {
+		b.z.Debug().Str("event", string(e.Type)).Str("resource_id", e.ResourceID).Msg("event published")
+		for _, s := range subs {
+			if len(s.types) > 0 && !slices.Contains(s.types, e.Type) {
+				continue
+			}
+			b.deliver(ctx, s, e)
+		}
+	}
+}
+
+// A failing subscriber must not affect the publisher or other subscribers
+func (b *Bus) deliver(ctx context.Context, s subscription, e Event) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			b.z.Error().Err(fmt.Errorf("%v", rec)).Str("event", string(e.Type)).Msg("event handler panicked")
+		}
+	}()
+	s.handler(ctx, e)
+}
@@ -0,0 +1,179 @@
+package flags
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindBoolean    Kind = "boolean"
+	KindPercentage Kind = "percentage"
+	KindVariant    Kind = "variant"
+)
+
+type Reason string
+
+const (
+	ReasonNotFound Reason = "NOT_FOUND"
+	ReasonDisabled Reason = "DISABLED"
+	ReasonRule     Reason = "RULE"
+	ReasonDefault  Reason = "DEFAULT"
+	ReasonRollout  Reason = "ROLLOUT"
+	ReasonVariant  Reason = "VARIANT"
+)
+
+// Targeting rule, matches when every non-empty criteria contains the subject
+type Rule struct {
+	UserIDs      []string `json:"user_ids,omitempty"`
+	OrgIDs       []string `json:"org_ids,omitempty"`
+	Roles        []string `json:"roles,omitempty"`
+	Environments []string `json:"environments,omitempty"`
+
+	// Served to subjects matching the rule
+	Enabled bool   `json:"enabled"`
+	Variant string `json:"variant,omitempty"`
+}
+
+type Variant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+type Flag struct {
+	Key         string    `gorm:"primaryKey" json:"key"`
+	Description string    `json:"description"`
+	Kind        Kind      `gorm:"not null;default:boolean" json:"kind"`
+	Enabled     bool      `json:"enabled"`
+	Percentage  int       `json:"percentage"`
+	Variants    []Variant `gorm:"serializer:json" json:"variants,omitempty"`
+	Rules       []Rule    `gorm:"serializer:json" json:"rules,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Attributes a flag is evaluated against
+type Subject struct {
+	UserID      string
+	OrgID       string
+	Role        string
+	Environment string
+}
+
+type Evaluation struct {
+	Key     string `json:"key"`
+	Enabled bool   `json:"enabled"`
+	Variant string `json:"variant,omitempty"`
+	Reason  Reason `json:"reason"`
+}
+
+func (f Flag) Validate() error {
+	if f.Key == "" {
+		return fmt.Errorf("flag key is required")
+	}
+
+	switch f.Kind {
+	case KindBoolean:
+	case KindPercentage:
+		if f.Percentage < 0 || f.Percentage > 100 {
+			return fmt.Errorf("flag percentage must be between 0 and 100")
+		}
+	case KindVariant:
+		total := 0
+		for _, v := range f.Variants {
+			if v.Name == "" || v.Weight < 0 {
+				return fmt.Errorf("invalid variant: %q (weight %d)", v.Name, v.Weight)
+			}
+			total += v.Weight
+		}
+		if total == 0 {
+			return fmt.Errorf("variant flag requires at least one weighted variant")
+		}
+	default:
+		return fmt.Errorf("invalid flag kind: %s", f.Kind)
+	}
+
+	return nil
+}
+
+func (r Rule) matches(s Subject) bool {
+	return matchesAny(r.UserIDs, s.UserID) &&
+		matchesAny(r.OrgIDs, s.OrgID) &&
+		matchesAny(r.Roles, s.Role) &&
+		matchesAny(r.Environments, s.Environment)
+}
+
+func matchesAny(values []string, v string) bool {
+	return len(values) == 0 || slices.Contains(values, v)
+}
+
+// Evaluate the flag for a subject. Rules are checked in order, the first
+// match wins, otherwise the flag kind decides.
+func (f Flag) Evaluate(s Subject) Evaluation {
+	eval := Evaluation{Key: f.Key}
+
+	if !f.Enabled {
+		eval.Reason = ReasonDisabled
+		return eval
+	}
+
+	for _, rule := range f.Rules {
+		if rule.matches(s) {
+			eval.Enabled = rule.Enabled
+			eval.Variant = rule.Variant
+			eval.Reason = ReasonRule
+			return eval
+		}
+	}
+
+	switch f.Kind {
+	case KindPercentage:
+		eval.Enabled = bucket(f.Key, s) < f.Percentage
+		eval.Reason = ReasonRollout
+	case KindVariant:
+		eval.Enabled = true
+		eval.Variant = f.pickVariant(s)
+		eval.Reason = ReasonVariant
+	default:
+		eval.Enabled = true
+		eval.Reason = ReasonDefault
+	}
+
+	return eval
+}
+
+func (f Flag) pickVariant(s Subject) string {
+	total := 0
+	for _, v := range f.Variants {
+		total += v.Weight
+	}
+	if total == 0 {
+		return ""
+	}
+
+	// Scale the bucket onto the total weight
+	point := bucket(f.Key, s) * total / 100
+	for _, v := range f.Variants {
+		if point < v.Weight {
+			return v.Name
+		}
+		point -= v.Weight
+	}
+	return f.Variants[len(f.Variants)-1].Name
+}
+
+// Deterministic bucket in [0, 100) so a subject always gets the same
+// result for a flag. Falls back to the org when there's no user.
+func bucket(key string, s Subject) int {
+	id := s.UserID
+	if id == "" {
+		id = s.OrgID
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key + ":" + id))
+	return int(h.Sum32() % 100)
+}
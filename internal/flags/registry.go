@@ -0,0 +1,219 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"x/core/internal/auth"
+
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultCacheTTL = 30 * time.Second
+
+// Registry loads flags from postgres and keeps an in-memory copy which is
+// refreshed after the TTL or invalidated on writes
+type Registry struct {
+	db  *gorm.DB
+	z   *zerolog.Logger
+	env string
+	ttl time.Duration
+
+	mu       sync.RWMutex
+	cache    map[string]Flag
+	loadedAt time.Time
+	// Bumped by every invalidation, loads started before one don't store
+	// what they read
+	generation uint64
+}
+
+func NewRegistry(db *gorm.DB, logger *zerolog.Logger, env string, ttl time.Duration) *Registry {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &Registry{
+		db:  db,
+		z:   logger,
+		env: env,
+		ttl: ttl,
+	}
+}
+
+// Create enabled boolean flags for the given keys, existing flags are left as-is
+func (r *Registry) Seed(ctx context.Context, keys string) error {
+	for _, key := range strings.Split(keys, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		flag := Flag{Key: key, Kind: KindBoolean, Enabled: true, Description: "seeded from config"}
+		if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&flag).Error; err != nil {
+			return fmt.Errorf("error seeding flag %s: %v", key, err)
+		}
+	}
+
+	r.Invalidate()
+	return nil
+}
+
+// Drop the cached flags so the next read hits the database
+func (r *Registry) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = nil
+	r.generation++
+}
+
+func (r *Registry) load(ctx context.Context) (map[string]Flag, error) {
+	r.mu.RLock()
+	if r.cache != nil && time.Since(r.loadedAt) < r.ttl {
+		defer r.mu.RUnlock()
+		return r.cache, nil
+	}
+	generation := r.generation
+	r.mu.RUnlock()
+
+	var records []Flag
+	if err := r.db.WithContext(ctx).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	cache := make(map[string]Flag, len(records))
+	for _, f := range records {
+		cache[f.Key] = f
+	}
+
+	r.mu.Lock()
+	if r.generation == generation {
+		r.cache = cache
+		r.loadedAt = time.Now()
+	}
+	r.mu.Unlock()
+
+	return cache, nil
+}
+
+func (r *Registry) All(ctx context.Context) ([]Flag, error) {
+	cache, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Flag, 0, len(cache))
+	for _, f := range cache {
+		records = append(records, f)
+	}
+	return records, nil
+}
+
+func (r *Registry) Get(ctx context.Context, key string) (*Flag, error) {
+	cache, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := cache[key]
+	if !ok {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+// Create or replace a flag
+func (r *Registry) Save(ctx context.Context, flag Flag) (*Flag, error) {
+	if flag.Kind == "" {
+		flag.Kind = KindBoolean
+	}
+	if err := flag.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Existing flags keep their creation time, the returned row has it
+	err := r.db.WithContext(ctx).Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description", "kind", "enabled", "percentage", "variants", "rules", "updated_at"}),
+		},
+		clause.Returning{},
+	).Create(&flag).Error
+	if err != nil {
+		return nil, err
+	}
+
+	r.Invalidate()
+	return &flag, nil
+}
+
+func (r *Registry) Toggle(ctx context.Context, key string, enabled bool) (*Flag, error) {
+	result := r.db.WithContext(ctx).Model(&Flag{}).Where("key = ?", key).Update("enabled", enabled)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("flag not found: %s", key)
+	}
+
+	r.Invalidate()
+	return r.Get(ctx, key)
+}
+
+func (r *Registry) Delete(ctx context.Context, key string) error {
+	if err := r.db.WithContext(ctx).Where("key = ?", key).Delete(&Flag{}).Error; err != nil {
+		return err
+	}
+
+	r.Invalidate()
+	return nil
+}
+
+// Subject for the principal in the context
+func (r *Registry) Subject(ctx context.Context) Subject {
+	s := Subject{Environment: r.env}
+	if p, ok := auth.FromContext(ctx); ok {
+		s.UserID = p.UserID
+		s.OrgID = p.OrgID
+		s.Role = p.Role
+	}
+	return s
+}
+
+// Evaluate a flag for the principal in the context. Unknown flags and load
+// errors evaluate to off.
+func (r *Registry) Evaluate(ctx context.Context, key string) Evaluation {
+	f, err := r.Get(ctx, key)
+	if err != nil {
+		r.z.Error().Err(err).Str("flag", key).Msg("error loading feature flag")
+	}
+	if f == nil {
+		return Evaluation{Key: key, Reason: ReasonNotFound}
+	}
+	return f.Evaluate(r.Subject(ctx))
+}
+
+// Evaluate all flags for the principal in the context
+func (r *Registry) EvaluateAll(ctx context.Context) ([]Evaluation, error) {
+	records, err := r.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := r.Subject(ctx)
+	evals := make([]Evaluation, 0, len(records))
+	for _, f := range records {
+		evals = append(evals, f.Evaluate(subject))
+	}
+	return evals, nil
+}
+
+func (r *Registry) Enabled(ctx context.Context, key string) bool {
+	return r.Evaluate(ctx, key).Enabled
+}
+
+func (r *Registry) Variant(ctx context.Context, key string) string {
+	return r.Evaluate(ctx, key).Variant
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+	"x/core/internal/audit"
+
+	"github.com/gorilla/mux"
+)
+
+type AuditParams struct {
+	PaginationParams
+	ResourceType string       `schema:"resource_type"`
+	ResourceID   string       `schema:"resource_id"`
+	ActorID      string       `schema:"actor_id"`
+	Action       audit.Action `schema:"action" validate:"oneof=create update delete"`
+	RequestID    string       `schema:"request_id"`
+	From         time.Time    `schema:"from" doc:"RFC 3339 timestamp, inclusive"`
+	To           time.Time    `schema:"to" doc:"RFC 3339 timestamp, exclusive"`
+}
+
+func (p AuditParams) filter(r *http.Request) audit.Filter {
+	filter := audit.Filter{
+		ResourceType: p.ResourceType,
+		ResourceID:   p.ResourceID,
+		ActorID:      p.ActorID,
+		Action:       p.Action,
+		RequestID:    p.RequestID,
+		From:         p.From,
+		To:           p.To,
+	}
+
+	// Path parameters take precedence over the query string
+	vars := mux.Vars(r)
+	if v, ok := vars["resource_type"]; ok {
+		filter.ResourceType = v
+	}
+	if v, ok := vars["resource_id"]; ok {
+		filter.ResourceID = v
+	}
+	if v, ok := vars["actor_id"]; ok {
+		filter.ActorID = v
+	}
+
+	return filter
+}
+
+// Audit history filtered by resource, actor, action and time range
+func (h *Handler) GetAuditHistory(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[AuditParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	entries, totalPages, err := h.s.AuditHistory(r.Context(), params.filter(r), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[audit.Entry]{
+		Data:       entries,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	})
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"net/http"
+	"x/core/internal/catalog"
+
+	"github.com/gorilla/mux"
+)
+
+type NoteParams struct {
+	PaginationParams
+	Family string `schema:"family" doc:"Olfactive family, e.g. citrus"`
+}
+
+type FragranceParams struct {
+	PaginationParams
+	BrandID       string `schema:"brand_id"`
+	Concentration string `schema:"concentration" validate:"oneof=edt edp parfum extrait"`
+	Gender        string `schema:"gender" validate:"oneof=masculine feminine unisex"`
+	NoteID        string `schema:"note_id" doc:"Note in any layer"`
+	ReleaseYear   int    `schema:"release_year"`
+}
+
+type DuplicateParams struct {
+	Threshold float64 `schema:"threshold" validate:"min=0,max=1" doc:"Minimum name similarity, defaults to 0.6"`
+	Limit     int     `schema:"limit" validate:"min=1,max=100"`
+}
+
+type MergeFragranceRequest struct {
+	DuplicateID string `json:"duplicate_id" validate:"required"`
+}
+
+// Version from the required If-Match header of an update
+func requiredVersion(r *http.Request, id string) (int64, error) {
+	version, ok, err := IfMatchVersion(r, id)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrPreconditionNeeded
+	}
+	return version, nil
+}
+
+func (h *Handler) ListBrands(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[PaginationParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	records, totalPages, err := h.s.ListBrands(r.Context(), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[catalog.Brand]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
+
+func (h *Handler) GetBrand(w http.ResponseWriter, r *http.Request) error {
+	b, err := h.s.GetBrand(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, b.ID, b.GetVersion(), b)
+}
+
+func (h *Handler) CreateBrand(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[catalog.BrandInput](r)
+	if err != nil {
+		return err
+	}
+
+	b, err := h.s.CreateBrand(r.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusCreated, b.ID, b.GetVersion(), b)
+}
+
+func (h *Handler) UpdateBrand(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+	version, err := requiredVersion(r, id)
+	if err != nil {
+		return err
+	}
+	in, err := DecodeJSON[catalog.BrandInput](r)
+	if err != nil {
+		return err
+	}
+
+	b, err := h.s.UpdateBrand(r.Context(), id, version, in)
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, b.ID, b.GetVersion(), b)
+}
+
+func (h *Handler) DeleteBrand(w http.ResponseWriter, r *http.Request) error {
+	if err := h.s.DeleteBrand(r.Context(), mux.Vars(r)["id"]); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) ListNotes(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[NoteParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	records, totalPages, err := h.s.ListNotes(r.Context(), params.Family, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[catalog.Note]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
+
+func (h *Handler) GetNote(w http.ResponseWriter, r *http.Request) error {
+	n, err := h.s.GetNote(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, n.ID, n.GetVersion(), n)
+}
+
+func (h *Handler) CreateNote(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[catalog.NoteInput](r)
+	if err != nil {
+		return err
+	}
+
+	n, err := h.s.CreateNote(r.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusCreated, n.ID, n.GetVersion(), n)
+}
+
+func (h *Handler) UpdateNote(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+	version, err := requiredVersion(r, id)
+	if err != nil {
+		return err
+	}
+	in, err := DecodeJSON[catalog.NoteInput](r)
+	if err != nil {
+		return err
+	}
+
+	n, err := h.s.UpdateNote(r.Context(), id, version, in)
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, n.ID, n.GetVersion(), n)
+}
+
+func (h *Handler) DeleteNote(w http.ResponseWriter, r *http.Request) error {
+	if err := h.s.DeleteNote(r.Context(), mux.Vars(r)["id"]); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) ListFragrances(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[FragranceParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	filter := catalog.FragranceFilter{
+		BrandID:       params.BrandID,
+		Concentration: catalog.Concentration(params.Concentration),
+		Gender:        catalog.Gender(params.Gender),
+		NoteID:        params.NoteID,
+		ReleaseYear:   params.ReleaseYear,
+	}
+	records, totalPages, err := h.s.ListFragrances(r.Context(), filter, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[catalog.Fragrance]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
+
+func (h *Handler) GetFragrance(w http.ResponseWriter, r *http.Request) error {
+	f, err := h.s.GetFragrance(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, f.ID, f.GetVersion(), f)
+}
+
+func (h *Handler) CreateFragrance(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[catalog.FragranceInput](r)
+	if err != nil {
+		return err
+	}
+
+	f, err := h.s.CreateFragrance(r.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusCreated, f.ID, f.GetVersion(), f)
+}
+
+func (h *Handler) UpdateFragrance(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+	version, err := requiredVersion(r, id)
+	if err != nil {
+		return err
+	}
+	in, err := DecodeJSON[catalog.FragranceInput](r)
+	if err != nil {
+		return err
+	}
+
+	f, err := h.s.UpdateFragrance(r.Context(), id, version, in)
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, f.ID, f.GetVersion(), f)
+}
+
+func (h *Handler) DeleteFragrance(w http.ResponseWriter, r *http.Request) error {
+	if err := h.s.DeleteFragrance(r.Context(), mux.Vars(r)["id"]); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Pairs of fragrances that are likely the same entry
+func (h *Handler) GetFragranceDuplicates(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[DuplicateParams](r)
+	if err != nil {
+		return err
+	}
+	if params.Limit < 1 {
+		params.Limit = defaultPageSize
+	}
+
+	candidates, err := h.s.FragranceDuplicates(r.Context(), params.Threshold, params.Limit)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, candidates)
+}
+
+// Merge the duplicate named in the body into the fragrance of the path
+func (h *Handler) MergeFragrance(w http.ResponseWriter, r *http.Request) error {
+	req, err := DecodeJSON[MergeFragranceRequest](r)
+	if err != nil {
+		return err
+	}
+
+	f, err := h.s.MergeFragrance(r.Context(), req.DuplicateID, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, f.ID, f.GetVersion(), f)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"x/core/internal/config"
+
+	"github.com/rs/cors"
+)
+
+// CORSPolicy overrides the configured CORS settings for a route
+type CORSPolicy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowCredentials bool
+}
+
+var (
+	// Public data readable from any origin, never with credentials
+	PublicCORS = CORSPolicy{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{http.MethodGet, http.MethodHead},
+	}
+)
+
+// Routes with their own CORS policy, by path below the version prefix
+var corsOverrides = map[string]CORSPolicy{
+	"/probe":        PublicCORS,
+	"/search":       PublicCORS,
+	"/openapi.json": PublicCORS,
+	"/docs":         PublicCORS,
+}
+
+// CORS handlers built from the live config, rebuilt when it is reloaded
+type corsHandlers struct {
+	base      *cors.Cors
+	overrides map[string]*cors.Cors
+}
+
+func corsOptions(c config.Config) cors.Options {
+	return cors.Options{
+		AllowedOrigins:   c.AllowedOrigins(),
+		AllowedMethods:   c.CORS.AllowedMethods,
+		AllowedHeaders:   c.CORS.AllowedHeaders,
+		ExposedHeaders:   c.CORS.ExposedHeaders,
+		MaxAge:           int(c.CORS.MaxAge.Seconds()),
+		AllowCredentials: c.CORS.AllowCredentials != nil && *c.CORS.AllowCredentials,
+	}
+}
+
+func newCORSHandlers(c config.Config) *corsHandlers {
+	base := corsOptions(c)
+	// Without any configured origin cross origin requests are refused
+	if len(base.AllowedOrigins) == 0 {
+		base.AllowOriginFunc = func(string) bool { return false }
+	}
+
+	handlers := &corsHandlers{
+		base:      cors.New(base),
+		overrides: make(map[string]*cors.Cors, len(corsOverrides)),
+	}
+	for path, policy := range corsOverrides {
+		opts := corsOptions(c)
+		opts.AllowedOrigins = policy.AllowedOrigins
+		opts.AllowedMethods = policy.AllowedMethods
+		opts.AllowCredentials = policy.AllowCredentials
+		handlers.overrides[path] = cors.New(opts)
+	}
+	return handlers
+}
+
+// Route path without the api and version prefix, e.g. /search for
+// /api/v2/search
+func routePath(path string) string {
+	if m := versionedPath.FindStringSubmatchIndex(path); m != nil {
+		return "/" + strings.TrimPrefix(path[m[1]:], "/")
+	}
+	return strings.TrimPrefix(path, apiPrefix)
+}
+
+// Answers preflight requests and sets the CORS headers of a request, using
+// the route's override when it has one
+func (h *Handler) CORSMiddleware(next http.Handler) http.Handler {
+	var current atomic.Pointer[corsHandlers]
+	current.Store(newCORSHandlers(h.conf.Load()))
+	h.conf.OnChange(func(prev, next config.Config) {
+		current.Store(newCORSHandlers(next))
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers := current.Load()
+
+		c := handlers.base
+		if override, ok := handlers.overrides[routePath(r.URL.Path)]; ok {
+			c = override
+		}
+		c.Handler(next).ServeHTTP(w, r)
+	})
+}
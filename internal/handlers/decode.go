@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+	"x/core/internal/openapi"
+
+	"github.com/gorilla/schema"
+)
+
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// Binds query strings and forms to structs by their schema tags
+var valuesDecoder = newValuesDecoder()
+
+func newValuesDecoder() *schema.Decoder {
+	d := schema.NewDecoder()
+	d.IgnoreUnknownKeys(true)
+	d.RegisterConverter(time.Time{}, func(s string) reflect.Value {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return reflect.Value{}
+		}
+		return reflect.ValueOf(t)
+	})
+	return d
+}
+
+// Limit request bodies to the configured size
+func (h *Handler) BodyLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limit := h.conf.Load().HTTPServer.MaxBodyBytes; limit > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Decode a JSON request body into T. Unknown fields, trailing data and
+// values breaking the validate tags of T are rejected with field errors.
+func DecodeJSON[T any](r *http.Request) (T, error) {
+	var v T
+	if r.Body == nil {
+		return v, &ValidationError{Fields: []openapi.FieldError{{Field: "body", Message: "is required"}}}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return v, fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, maxBytesErr.Limit)
+		}
+		return v, fmt.Errorf("error reading request body: %v", err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return v, &ValidationError{Fields: []openapi.FieldError{{Field: "body", Message: "is required"}}}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return v, decodeError(err)
+	}
+	if dec.More() {
+		return v, &ValidationError{Fields: []openapi.FieldError{{Field: "body", Message: "must contain a single JSON value"}}}
+	}
+
+	if fields := openapi.ValidateJSON(reflect.TypeOf(v), body); len(fields) > 0 {
+		return v, &ValidationError{Fields: fields}
+	}
+
+	return v, nil
+}
+
+// Field level error for a failed JSON decode
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return &ValidationError{Fields: []openapi.FieldError{{Field: "body", Message: "must be valid JSON"}}}
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return &ValidationError{Fields: []openapi.FieldError{{Field: field, Message: "must be of type " + jsonType(typeErr.Type)}}}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &ValidationError{Fields: []openapi.FieldError{{Field: field, Message: "is not allowed"}}}
+	default:
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+}
+
+// JSON name of a Go type for error messages
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "string"
+	}
+}
+
+// Bind the query string to T by its schema tags and validate it
+func DecodeQuery[T any](r *http.Request) (T, error) {
+	return decodeValues[T](r.URL.Query())
+}
+
+// Bind a url encoded or multipart form to T by its schema tags and
+// validate it
+func DecodeForm[T any](r *http.Request) (T, error) {
+	if err := r.ParseForm(); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return *new(T), fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, maxBytesErr.Limit)
+		}
+		return *new(T), fmt.Errorf("invalid form: %v", err)
+	}
+	return decodeValues[T](r.PostForm)
+}
+
+func decodeValues[T any](values url.Values) (T, error) {
+	var v T
+
+	// Validate the raw values first, they carry the input the client sent
+	if fields := openapi.ValidateValues(reflect.TypeOf(v), values); len(fields) > 0 {
+		return v, &ValidationError{Fields: fields}
+	}
+
+	if err := valuesDecoder.Decode(&v, values); err != nil {
+		var multi schema.MultiError
+		if !errors.As(err, &multi) {
+			return v, err
+		}
+
+		fields := make([]openapi.FieldError, 0, len(multi))
+		for key, e := range multi {
+			var conversionErr schema.ConversionError
+			var emptyErr schema.EmptyFieldError
+			switch {
+			case errors.As(e, &conversionErr):
+				fields = append(fields, openapi.FieldError{Field: key, Message: "must be of type " + valueType(conversionErr.Type)})
+			case errors.As(e, &emptyErr):
+				fields = append(fields, openapi.FieldError{Field: key, Message: "is required"})
+			default:
+				fields = append(fields, openapi.FieldError{Field: key, Message: e.Error()})
+			}
+		}
+		return v, &ValidationError{Fields: fields}
+	}
+
+	return v, nil
+}
+
+// Name of a query value type for error messages
+func valueType(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "RFC 3339 timestamp"
+	}
+	return jsonType(t)
+}
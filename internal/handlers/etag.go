@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"x/core/internal/persist"
+)
+
+var (
+	ErrPreconditionFailed = errors.New("precondition failed: resource has been modified")
+	ErrPreconditionNeeded = errors.New("If-Match header is required")
+)
+
+// Strong entity tag for a versioned record
+func ETag(id string, version int64) string {
+	return fmt.Sprintf(`"%s.%d"`, id, version)
+}
+
+// Parse the version out of an entity tag produced by ETag
+func versionFromETag(id, etag string) (int64, bool) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	etag = strings.Trim(etag, `"`)
+
+	prefix := id + "."
+	if !strings.HasPrefix(etag, prefix) {
+		return 0, false
+	}
+
+	version, err := strconv.ParseInt(strings.TrimPrefix(etag, prefix), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// Version the client expects to modify, taken from the If-Match header.
+// Returns ok=false when the header is absent or "*", a tag for another
+// resource fails the precondition.
+func IfMatchVersion(r *http.Request, id string) (version int64, ok bool, err error) {
+	header := r.Header.Get("If-Match")
+	if header == "" || strings.TrimSpace(header) == "*" {
+		return 0, false, nil
+	}
+
+	for _, etag := range strings.Split(header, ",") {
+		if v, found := versionFromETag(id, etag); found {
+			return v, true, nil
+		}
+	}
+	return 0, false, ErrPreconditionFailed
+}
+
+// Check If-None-Match against the current entity tag
+func notModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	// Weak comparison as per RFC 9110
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// Write a versioned record with its ETag, responding 304 Not Modified when
+// the client already has the current version
+func (h *Handler) WriteVersioned(w http.ResponseWriter, r *http.Request, status int, id string, version int64, v any) error {
+	etag := ETag(id, version)
+	w.Header().Set("ETag", etag)
+
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	return h.WriteJSON(w, status, v)
+}
+
+// Conflicts on updates guarded by If-Match are reported as failed preconditions
+func preconditionError(err error) error {
+	if errors.Is(err, persist.ErrConflict) {
+		return ErrPreconditionFailed
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"x/core/internal/flags"
+
+	"github.com/gorilla/mux"
+)
+
+type ToggleFlagRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Check whether a feature flag is enabled for the caller of the request
+func (h *Handler) FeatureEnabled(r *http.Request, key string) bool {
+	return h.s.FeatureEnabled(r.Context(), key)
+}
+
+// Flags evaluated for the authenticated user
+func (h *Handler) GetFlags(w http.ResponseWriter, r *http.Request) error {
+	evals, err := h.s.EvaluateFlags(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, evals)
+}
+
+func (h *Handler) ListFlags(w http.ResponseWriter, r *http.Request) error {
+	records, err := h.s.ListFlags(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, records)
+}
+
+func (h *Handler) SaveFlag(w http.ResponseWriter, r *http.Request) error {
+	flag, err := DecodeJSON[flags.Flag](r)
+	if err != nil {
+		return err
+	}
+	flag.Key = mux.Vars(r)["key"]
+
+	f, err := h.s.SaveFlag(r.Context(), flag)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, f)
+}
+
+func (h *Handler) ToggleFlag(w http.ResponseWriter, r *http.Request) error {
+	req, err := DecodeJSON[ToggleFlagRequest](r)
+	if err != nil {
+		return err
+	}
+
+	f, err := h.s.ToggleFlag(r.Context(), mux.Vars(r)["key"], req.Enabled)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, f)
+}
+
+func (h *Handler) DeleteFlag(w http.ResponseWriter, r *http.Request) error {
+	if err := h.s.DeleteFlag(r.Context(), mux.Vars(r)["key"]); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
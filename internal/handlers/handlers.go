@@ -2,37 +2,66 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"x/core/internal/audit"
+	"x/core/internal/auth"
+	"x/core/internal/catalog"
 	"x/core/internal/config"
+	"x/core/internal/events"
+	"x/core/internal/flags"
+	"x/core/internal/listings"
+	"x/core/internal/messages"
+	"x/core/internal/offers"
+	"x/core/internal/openapi"
+	"x/core/internal/orders"
+	"x/core/internal/persist"
+	"x/core/internal/realtime"
+	"x/core/internal/reviews"
+	"x/core/internal/search"
 	"x/core/internal/service"
+	"x/core/internal/shipping"
+	"x/core/internal/watchlists"
 
 	"github.com/clerkinc/clerk-sdk-go/clerk"
 	sentryhttp "github.com/getsentry/sentry-go/http"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
+	"gorm.io/gorm"
 )
 
 type Handler struct {
 	z       *zerolog.Logger
 	s       *service.Service
 	c       clerk.Client
-	conf    config.Config
+	conf    *config.Live
 	monitor *sentryhttp.Handler
+	specs   map[string]*openapi.Document
+	hub     *realtime.Hub
 }
 
 func NewHandler(
 	logger *zerolog.Logger,
 	srvc *service.Service,
 	clrk clerk.Client,
-	conf config.Config,
+	conf *config.Live,
 	m *sentryhttp.Handler,
+	hub *realtime.Hub,
 ) *Handler {
+	// One OpenAPI document per served API version
+	specs := make(map[string]*openapi.Document, len(apiVersions))
+	for _, v := range apiVersions {
+		specs[v.Name] = openapi.New(apiTitle, v.SpecVersion)
+	}
+
 	return &Handler{
 		z:       logger,
 		s:       srvc,
 		c:       clrk,
 		conf:    conf,
 		monitor: m,
+		specs:   specs,
+		hub:     hub,
 	}
 }
 
@@ -52,38 +81,412 @@ func (h *Handler) WriteJSON(w http.ResponseWriter, status int, v any) error {
 	return json.NewEncoder(w).Encode(v)
 }
 
-func formatError(err error) map[string]string {
+func formatError(err error) openapi.ErrorResponse {
 	var handlerError = err.Error()
 
 	if err.Error() == "sql: no rows in result set" {
 		handlerError = "No email found. Please sign up."
 	}
-	return map[string]string{
-		"error": handlerError,
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return openapi.ErrorResponse{
+			Error:  "validation failed",
+			Fields: validationErr.Fields,
+		}
+	}
+
+	return openapi.ErrorResponse{
+		Error: handlerError,
+	}
+}
+
+// Map well known errors to their http status, anything else is a bad request
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge), errors.As(err, new(*http.MaxBytesError)):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, realtime.ErrTooManyConnections):
+		return http.StatusTooManyRequests
+	case errors.Is(err, realtime.ErrClosed):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrCSRF), errors.Is(err, listings.ErrNotOwner),
+		errors.Is(err, offers.ErrNotParty), errors.Is(err, offers.ErrNotAllowed), errors.Is(err, orders.ErrNotParty),
+		errors.Is(err, shipping.ErrNotOwner), errors.Is(err, reviews.ErrNotAuthor),
+		errors.Is(err, messages.ErrNotParticipant), errors.Is(err, messages.ErrBlocked):
+		return http.StatusForbidden
+	case errors.Is(err, ErrUnsupportedVersion):
+		return http.StatusNotAcceptable
+	case errors.Is(err, ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrPreconditionNeeded):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, persist.ErrConflict), errors.Is(err, catalog.ErrDuplicate),
+		errors.Is(err, listings.ErrInvalidTransition), errors.Is(err, listings.ErrNotEditable),
+		errors.Is(err, listings.ErrNotSplit), errors.Is(err, listings.ErrOutOfStock),
+		errors.Is(err, offers.ErrOfferClosed), errors.Is(err, offers.ErrOpenOffer), errors.Is(err, offers.ErrListingUnavailable),
+		errors.Is(err, orders.ErrInvalidTransition), errors.Is(err, orders.ErrNotPurchasable), errors.Is(err, orders.ErrNoPayoutAccount),
+		errors.Is(err, shipping.ErrNoAddress), errors.Is(err, shipping.ErrRateUnavailable),
+		errors.Is(err, shipping.ErrHazmatRestricted), errors.Is(err, shipping.ErrAlreadyShipped),
+		errors.Is(err, reviews.ErrNotReviewable), errors.Is(err, reviews.ErrAlreadyReviewed), errors.Is(err, reviews.ErrEditWindowClosed),
+		errors.Is(err, watchlists.ErrTooManyWatches), errors.Is(err, watchlists.ErrTooManySearches), errors.Is(err, watchlists.ErrListingNotActive):
+		return http.StatusConflict
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
 	}
 }
 
 func (h *Handler) HandleErrorResponse(w http.ResponseWriter, e error) error {
-	return h.WriteJSON(w, http.StatusBadRequest, formatError(e))
+	return h.WriteJSON(w, errorStatus(e), formatError(e))
 }
 
-func (h *Handler) RegisterRoutes() *mux.Router {
+// Register the routes of every API version. Unversioned /api requests are
+// routed to the version negotiated from the request headers.
+func (h *Handler) RegisterRoutes() http.Handler {
 	r := mux.NewRouter()
-	r.Use(h.LoggerMiddleware)
-	r.Use(h.RateLimiterMiddleware)
+	r.Use(h.RequestIDMiddleware)
 
 	// sentry monitoring only for dev+prod environments
-	if h.conf.Env != "local" {
+	if h.conf.Load().Env != "local" && h.monitor != nil {
 		r.Use(h.monitor.Handle)
 	}
 
-	api := r.PathPrefix("/api").Subrouter()
-	private := r.PathPrefix("/api").Subrouter()
+	// recover panics inside the sentry scope so they are reported once, with
+	// the request attached
+	r.Use(h.RecoveryMiddleware)
+
+	r.Use(h.LoggerMiddleware)
+	r.Use(h.RateLimiterMiddleware)
+	r.Use(h.CSRFMiddleware)
+
+	// limit request bodies, then validate requests against the generated
+	// OpenAPI document
+	r.Use(h.BodyLimitMiddleware)
+	r.Use(h.OpenAPIValidationMiddleware)
+
+	for _, v := range apiVersions {
+		h.registerAPI(r, v)
+	}
+
+	// r.Use only wraps matched routes, recover panics in the outer handlers
+	// and unmatched requests too
+	return h.RecoveryMiddleware(h.CORSMiddleware(h.SecurityHeadersMiddleware(h.VersionNegotiation(r))))
+}
+
+func (h *Handler) registerAPI(r *mux.Router, v apiVersion) {
+	prefix := apiPrefix + "/" + v.Name
+	api := r.PathPrefix(prefix).Subrouter()
+	api.Use(v.middleware)
+	private := r.PathPrefix(prefix).Subrouter()
+	private.Use(v.middleware)
 
 	// clerk authentication for private routes
 	private.Use(h.ClerkAuthMiddleware)
 
+	// admin routes require an admin principal
+	admin := private.PathPrefix("/admin").Subrouter()
+	admin.Use(h.AdminMiddleware)
+
 	// Probe
-	api.HandleFunc("/probe", h.HTTPHandlerFunc(h.Probe)).Methods("GET")
-	return r
+	h.document(api.HandleFunc("/probe", h.WithCache(NoStoreCache, h.HTTPHandlerFunc(h.Probe))).Methods("GET"), openapi.Operation{
+		OperationID: "probe", Summary: "Health check", Tags: []string{"system"}, Response: ProbeResponse{},
+	})
+
+	// API contract
+	api.HandleFunc("/openapi.json", h.HTTPHandlerFunc(h.GetOpenAPI)).Methods("GET")
+	api.HandleFunc("/docs", h.HTTPHandlerFunc(h.GetDocs)).Methods("GET")
+
+	// Search
+	h.document(api.HandleFunc("/search", h.WithCache(PublicCatalogCache, h.HTTPHandlerFunc(h.Search))).Methods("GET", "HEAD"), openapi.Operation{
+		OperationID: "searchListings", Summary: "Search listings", Tags: []string{"search"}, Query: SearchParams{}, Response: search.Result{},
+	})
+
+	// Catalog
+	h.document(api.HandleFunc("/catalog/brands", h.WithCache(PublicCatalogCache, h.HTTPHandlerFunc(h.ListBrands))).Methods("GET", "HEAD"), openapi.Operation{
+		OperationID: "listBrands", Summary: "List brands", Tags: []string{"catalog"}, Query: PaginationParams{}, Response: PaginatedResponse[catalog.Brand]{},
+	})
+	h.document(api.HandleFunc("/catalog/brands/{id}", h.WithCache(PublicCatalogCache, h.HTTPHandlerFunc(h.GetBrand))).Methods("GET", "HEAD"), openapi.Operation{
+		OperationID: "getBrand", Summary: "Get a brand", Tags: []string{"catalog"}, Response: catalog.Brand{},
+	})
+	h.document(api.HandleFunc("/catalog/notes", h.WithCache(PublicCatalogCache, h.HTTPHandlerFunc(h.ListNotes))).Methods("GET", "HEAD"), openapi.Operation{
+		OperationID: "listNotes", Summary: "List notes", Tags: []string{"catalog"}, Query: NoteParams{}, Response: PaginatedResponse[catalog.Note]{},
+	})
+	h.document(api.HandleFunc("/catalog/notes/{id}", h.WithCache(PublicCatalogCache, h.HTTPHandlerFunc(h.GetNote))).Methods("GET", "HEAD"), openapi.Operation{
+		OperationID: "getNote", Summary: "Get a note", Tags: []string{"catalog"}, Response: catalog.Note{},
+	})
+	h.document(api.HandleFunc("/catalog/fragrances", h.WithCache(PublicCatalogCache, h.HTTPHandlerFunc(h.ListFragrances))).Methods("GET", "HEAD"), openapi.Operation{
+		OperationID: "listFragrances", Summary: "List fragrances", Tags: []string{"catalog"}, Query: FragranceParams{}, Response: PaginatedResponse[catalog.Fragrance]{},
+	})
+	h.document(api.HandleFunc("/catalog/fragrances/{id}", h.WithCache(PublicCatalogCache, h.HTTPHandlerFunc(h.GetFragrance))).Methods("GET", "HEAD"), openapi.Operation{
+		OperationID: "getFragrance", Summary: "Get a fragrance", Tags: []string{"catalog"}, Response: catalog.Fragrance{},
+	})
+
+	// Listings
+	h.document(api.HandleFunc("/listings", h.WithCache(PublicCatalogCache, h.HTTPHandlerFunc(h.ListListings))).Methods("GET", "HEAD"), openapi.Operation{
+		OperationID: "listListings", Summary: "List active listings", Tags: []string{"listings"}, Query: ListingParams{}, Response: PaginatedResponse[listings.Listing]{},
+	})
+	h.document(api.HandleFunc("/listings/{id}", h.HTTPHandlerFunc(h.GetListing)).Methods("GET", "HEAD"), openapi.Operation{
+		OperationID: "getListing", Summary: "Get a listing", Tags: []string{"listings"}, Response: listings.Listing{},
+	})
+	h.document(private.HandleFunc("/listings", h.HTTPHandlerFunc(h.CreateListing)).Methods("POST"), openapi.Operation{
+		OperationID: "createListing", Summary: "Create a draft listing", Tags: []string{"listings"}, Request: listings.ListingInput{}, Response: listings.Listing{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(private.HandleFunc("/listings/{id}", h.HTTPHandlerFunc(h.UpdateListing)).Methods("PUT"), openapi.Operation{
+		OperationID: "updateListing", Summary: "Replace the details of a listing", Tags: []string{"listings"}, Request: listings.ListingInput{}, Response: listings.Listing{}, Private: true,
+	})
+	h.document(private.HandleFunc("/listings/{id}/transitions", h.HTTPHandlerFunc(h.TransitionListing)).Methods("POST"), openapi.Operation{
+		OperationID: "transitionListing", Summary: "Change the status of a listing", Tags: []string{"listings"}, Request: TransitionListingRequest{}, Response: listings.Listing{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/listings", h.HTTPHandlerFunc(h.ListSellerListings)).Methods("GET"), openapi.Operation{
+		OperationID: "listSellerListings", Summary: "Listings of the caller", Tags: []string{"listings"}, Query: SellerListingParams{}, Response: PaginatedResponse[listings.Listing]{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/listings/{id}", h.HTTPHandlerFunc(h.GetSellerListing)).Methods("GET"), openapi.Operation{
+		OperationID: "getSellerListing", Summary: "Listing of the caller with its status history", Tags: []string{"listings"}, Response: listings.Listing{}, Private: true,
+	})
+
+	// Offers
+	h.document(private.HandleFunc("/listings/{id}/offers", h.HTTPHandlerFunc(h.MakeOffer)).Methods("POST"), openapi.Operation{
+		OperationID: "makeOffer", Summary: "Make an offer on a listing", Tags: []string{"offers"}, Request: offers.OfferInput{}, Response: offers.Offer{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(private.HandleFunc("/listings/{id}/offers", h.HTTPHandlerFunc(h.ListListingOffers)).Methods("GET"), openapi.Operation{
+		OperationID: "listListingOffers", Summary: "Offers on a listing", Tags: []string{"offers"}, Response: []offers.Offer{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/offers", h.HTTPHandlerFunc(h.ListOffers)).Methods("GET"), openapi.Operation{
+		OperationID: "listOffers", Summary: "Offers of the caller", Tags: []string{"offers"}, Query: OfferParams{}, Response: PaginatedResponse[offers.Offer]{}, Private: true,
+	})
+	h.document(private.HandleFunc("/offers/{id}", h.HTTPHandlerFunc(h.GetOffer)).Methods("GET"), openapi.Operation{
+		OperationID: "getOffer", Summary: "Get an offer", Tags: []string{"offers"}, Response: offers.Offer{}, Private: true,
+	})
+	h.document(private.HandleFunc("/offers/{id}/counter", h.HTTPHandlerFunc(h.CounterOffer)).Methods("POST"), openapi.Operation{
+		OperationID: "counterOffer", Summary: "Counter an offer", Tags: []string{"offers"}, Request: offers.OfferInput{}, Response: offers.Offer{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(private.HandleFunc("/offers/{id}/accept", h.HTTPHandlerFunc(h.AcceptOffer)).Methods("POST"), openapi.Operation{
+		OperationID: "acceptOffer", Summary: "Accept an offer, reserving the listing", Tags: []string{"offers"}, Response: offers.Offer{}, Private: true,
+	})
+	h.document(private.HandleFunc("/offers/{id}/decline", h.HTTPHandlerFunc(h.DeclineOffer)).Methods("POST"), openapi.Operation{
+		OperationID: "declineOffer", Summary: "Decline an offer", Tags: []string{"offers"}, Response: offers.Offer{}, Private: true,
+	})
+	h.document(private.HandleFunc("/offers/{id}/withdraw", h.HTTPHandlerFunc(h.WithdrawOffer)).Methods("POST"), openapi.Operation{
+		OperationID: "withdrawOffer", Summary: "Withdraw an offer", Tags: []string{"offers"}, Response: offers.Offer{}, Private: true,
+	})
+
+	// Orders
+	h.document(private.HandleFunc("/orders", h.HTTPHandlerFunc(h.PlaceOrder)).Methods("POST"), openapi.Operation{
+		OperationID: "placeOrder", Summary: "Order a listing or an accepted offer", Tags: []string{"orders"}, Request: orders.OrderInput{}, Response: orders.Order{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(private.HandleFunc("/me/orders", h.HTTPHandlerFunc(h.ListOrders)).Methods("GET"), openapi.Operation{
+		OperationID: "listOrders", Summary: "Orders of the caller", Tags: []string{"orders"}, Query: OrderParams{}, Response: PaginatedResponse[orders.Order]{}, Private: true,
+	})
+	h.document(private.HandleFunc("/orders/{id}", h.HTTPHandlerFunc(h.GetOrder)).Methods("GET"), openapi.Operation{
+		OperationID: "getOrder", Summary: "Get an order", Tags: []string{"orders"}, Response: orders.Order{}, Private: true,
+	})
+	h.document(private.HandleFunc("/orders/{id}/cancel", h.HTTPHandlerFunc(h.CancelOrder)).Methods("POST"), openapi.Operation{
+		OperationID: "cancelOrder", Summary: "Cancel an order before payment capture", Tags: []string{"orders"}, Response: orders.Order{}, Private: true,
+	})
+	h.document(private.HandleFunc("/orders/{id}/confirm", h.HTTPHandlerFunc(h.ConfirmDelivery)).Methods("POST"), openapi.Operation{
+		OperationID: "confirmDelivery", Summary: "Confirm delivery, releasing funds to the seller", Tags: []string{"orders"}, Response: orders.Order{}, Private: true,
+	})
+	h.document(private.HandleFunc("/orders/{id}/dispute", h.HTTPHandlerFunc(h.DisputeOrder)).Methods("POST"), openapi.Operation{
+		OperationID: "disputeOrder", Summary: "Dispute an order", Tags: []string{"orders"}, Request: DisputeOrderRequest{}, Response: orders.Order{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/payout-account", h.HTTPHandlerFunc(h.GetPayoutAccount)).Methods("GET"), openapi.Operation{
+		OperationID: "getPayoutAccount", Summary: "Payout account of the caller", Tags: []string{"orders"}, Response: orders.PayoutAccount{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/payout-account", h.HTTPHandlerFunc(h.SavePayoutAccount)).Methods("PUT"), openapi.Operation{
+		OperationID: "savePayoutAccount", Summary: "Set the payout account of the caller", Tags: []string{"orders"}, Request: PayoutAccountRequest{}, Response: orders.PayoutAccount{}, Private: true,
+	})
+	// Events of the fake provider are unsigned, they are only taken locally
+	if conf := h.conf.Load(); conf.Env == "local" || conf.Payments.Provider == config.PaymentProviderStripe {
+		h.document(api.HandleFunc("/webhooks/payments", h.HTTPHandlerFunc(h.PaymentWebhook)).Methods("POST"), openapi.Operation{
+			OperationID: "paymentWebhook", Summary: "Payment provider events", Tags: []string{"orders"}, Response: WebhookResponse{},
+		})
+	}
+
+	// Shipping
+	h.document(private.HandleFunc("/me/addresses", h.HTTPHandlerFunc(h.ListAddresses)).Methods("GET"), openapi.Operation{
+		OperationID: "listAddresses", Summary: "Address book of the caller", Tags: []string{"shipping"}, Response: []shipping.Address{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/addresses", h.HTTPHandlerFunc(h.CreateAddress)).Methods("POST"), openapi.Operation{
+		OperationID: "createAddress", Summary: "Add an address", Tags: []string{"shipping"}, Request: shipping.AddressInput{}, Response: shipping.Address{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(private.HandleFunc("/me/addresses/{id}", h.HTTPHandlerFunc(h.UpdateAddress)).Methods("PUT"), openapi.Operation{
+		OperationID: "updateAddress", Summary: "Replace an address", Tags: []string{"shipping"}, Request: shipping.AddressInput{}, Response: shipping.Address{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/addresses/{id}", h.HTTPHandlerFunc(h.DeleteAddress)).Methods("DELETE"), openapi.Operation{
+		OperationID: "deleteAddress", Summary: "Delete an address", Tags: []string{"shipping"}, Status: http.StatusNoContent, Private: true,
+	})
+	h.document(private.HandleFunc("/orders/{id}/shipping-rates", h.HTTPHandlerFunc(h.QuoteShipping)).Methods("GET"), openapi.Operation{
+		OperationID: "quoteShipping", Summary: "Shipping rates for a paid order", Tags: []string{"shipping"}, Response: []shipping.Rate{}, Private: true,
+	})
+	h.document(private.HandleFunc("/orders/{id}/shipment", h.HTTPHandlerFunc(h.ShipOrder)).Methods("POST"), openapi.Operation{
+		OperationID: "shipOrder", Summary: "Buy a shipping label for an order", Tags: []string{"shipping"}, Request: shipping.ShipmentInput{}, Response: shipping.Shipment{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(private.HandleFunc("/orders/{id}/shipment", h.HTTPHandlerFunc(h.GetShipment)).Methods("GET"), openapi.Operation{
+		OperationID: "getShipment", Summary: "Shipment and tracking status of an order", Tags: []string{"shipping"}, Response: shipping.Shipment{}, Private: true,
+	})
+
+	// Reviews
+	h.document(private.HandleFunc("/orders/{id}/reviews", h.HTTPHandlerFunc(h.CreateReview)).Methods("POST"), openapi.Operation{
+		OperationID: "createReview", Summary: "Review a completed order", Tags: []string{"reviews"}, Request: reviews.ReviewInput{}, Response: reviews.Review{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(private.HandleFunc("/orders/{id}/reviews", h.HTTPHandlerFunc(h.ListOrderReviews)).Methods("GET"), openapi.Operation{
+		OperationID: "listOrderReviews", Summary: "Reviews of an order", Tags: []string{"reviews"}, Response: []reviews.Review{}, Private: true,
+	})
+	h.document(private.HandleFunc("/reviews/{id}", h.HTTPHandlerFunc(h.UpdateReview)).Methods("PUT"), openapi.Operation{
+		OperationID: "updateReview", Summary: "Edit a review while its edit window is open", Tags: []string{"reviews"}, Request: reviews.ReviewInput{}, Response: reviews.Review{}, Private: true,
+	})
+	h.document(api.HandleFunc("/users/{id}/reviews", h.WithCache(PublicCatalogCache, h.HTTPHandlerFunc(h.ListUserReviews))).Methods("GET", "HEAD"), openapi.Operation{
+		OperationID: "listUserReviews", Summary: "Reviews a user received", Tags: []string{"reviews"}, Query: UserReviewParams{}, Response: PaginatedResponse[reviews.Review]{},
+	})
+	h.document(api.HandleFunc("/users/{id}/reputation", h.WithCache(PublicCatalogCache, h.HTTPHandlerFunc(h.GetReputation))).Methods("GET", "HEAD"), openapi.Operation{
+		OperationID: "getReputation", Summary: "Aggregated ratings and reputation score of a user", Tags: []string{"reviews"}, Response: reviews.Reputation{},
+	})
+
+	// Messaging
+	h.document(private.HandleFunc("/conversations", h.HTTPHandlerFunc(h.StartConversation)).Methods("POST"), openapi.Operation{
+		OperationID: "startConversation", Summary: "Start or resume a conversation about a listing or an order", Tags: []string{"messages"}, Request: messages.ConversationInput{}, Response: messages.Conversation{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/conversations", h.HTTPHandlerFunc(h.ListConversations)).Methods("GET"), openapi.Operation{
+		OperationID: "listConversations", Summary: "Conversations of the caller with unread counts", Tags: []string{"messages"}, Query: PaginationParams{}, Response: PaginatedResponse[messages.Conversation]{}, Private: true,
+	})
+	h.document(private.HandleFunc("/conversations/{id}", h.HTTPHandlerFunc(h.GetConversation)).Methods("GET"), openapi.Operation{
+		OperationID: "getConversation", Summary: "Get a conversation", Tags: []string{"messages"}, Response: messages.Conversation{}, Private: true,
+	})
+	h.document(private.HandleFunc("/conversations/{id}/messages", h.HTTPHandlerFunc(h.ListMessages)).Methods("GET"), openapi.Operation{
+		OperationID: "listMessages", Summary: "Message history, newest first", Tags: []string{"messages"}, Query: CursorParams{}, Response: CursorResponse[messages.Message]{}, Private: true,
+	})
+	h.document(private.HandleFunc("/conversations/{id}/messages", h.HTTPHandlerFunc(h.SendMessage)).Methods("POST"), openapi.Operation{
+		OperationID: "sendMessage", Summary: "Send a message", Tags: []string{"messages"}, Request: messages.MessageInput{}, Response: messages.Message{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(private.HandleFunc("/conversations/{id}/read", h.HTTPHandlerFunc(h.MarkConversationRead)).Methods("POST"), openapi.Operation{
+		OperationID: "markConversationRead", Summary: "Mark received messages as read", Tags: []string{"messages"}, Response: messages.Receipt{}, Private: true,
+	})
+	h.document(private.HandleFunc("/conversations/{id}/attachments", h.HTTPHandlerFunc(h.CreateUploadTicket)).Methods("POST"), openapi.Operation{
+		OperationID: "createUploadTicket", Summary: "Sign an image upload to attach to a message", Tags: []string{"messages"}, Response: messages.UploadTicket{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(private.HandleFunc("/me/blocks", h.HTTPHandlerFunc(h.ListBlocks)).Methods("GET"), openapi.Operation{
+		OperationID: "listBlocks", Summary: "Users the caller blocked", Tags: []string{"messages"}, Response: []messages.Block{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/blocks/{id}", h.HTTPHandlerFunc(h.BlockUser)).Methods("PUT"), openapi.Operation{
+		OperationID: "blockUser", Summary: "Block messaging with a user", Tags: []string{"messages"}, Response: messages.Block{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/blocks/{id}", h.HTTPHandlerFunc(h.UnblockUser)).Methods("DELETE"), openapi.Operation{
+		OperationID: "unblockUser", Summary: "Unblock a user", Tags: []string{"messages"}, Status: http.StatusNoContent, Private: true,
+	})
+
+	// Watchlists and saved searches
+	h.document(private.HandleFunc("/me/watches", h.HTTPHandlerFunc(h.ListWatches)).Methods("GET"), openapi.Operation{
+		OperationID: "listWatches", Summary: "Fragrances and listings the caller watches", Tags: []string{"watchlists"}, Query: PaginationParams{}, Response: PaginatedResponse[watchlists.Watch]{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/watches", h.HTTPHandlerFunc(h.SaveWatch)).Methods("POST"), openapi.Operation{
+		OperationID: "saveWatch", Summary: "Watch a fragrance or a listing, or change its price limit", Tags: []string{"watchlists"}, Request: watchlists.WatchInput{}, Response: watchlists.Watch{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/watches/{id}", h.HTTPHandlerFunc(h.DeleteWatch)).Methods("DELETE"), openapi.Operation{
+		OperationID: "deleteWatch", Summary: "Stop watching", Tags: []string{"watchlists"}, Status: http.StatusNoContent, Private: true,
+	})
+	h.document(private.HandleFunc("/me/searches", h.HTTPHandlerFunc(h.ListSavedSearches)).Methods("GET"), openapi.Operation{
+		OperationID: "listSavedSearches", Summary: "Saved searches of the caller", Tags: []string{"watchlists"}, Response: []watchlists.SavedSearch{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/searches", h.HTTPHandlerFunc(h.CreateSavedSearch)).Methods("POST"), openapi.Operation{
+		OperationID: "createSavedSearch", Summary: "Save listing filters to be alerted of new matches", Tags: []string{"watchlists"}, Request: watchlists.SavedSearchInput{}, Response: watchlists.SavedSearch{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(private.HandleFunc("/me/searches/{id}", h.HTTPHandlerFunc(h.UpdateSavedSearch)).Methods("PUT"), openapi.Operation{
+		OperationID: "updateSavedSearch", Summary: "Replace a saved search", Tags: []string{"watchlists"}, Request: watchlists.SavedSearchInput{}, Response: watchlists.SavedSearch{}, Private: true,
+	})
+	h.document(private.HandleFunc("/me/searches/{id}", h.HTTPHandlerFunc(h.DeleteSavedSearch)).Methods("DELETE"), openapi.Operation{
+		OperationID: "deleteSavedSearch", Summary: "Delete a saved search", Tags: []string{"watchlists"}, Status: http.StatusNoContent, Private: true,
+	})
+	h.document(private.HandleFunc("/me/alerts", h.HTTPHandlerFunc(h.ListAlerts)).Methods("GET"), openapi.Operation{
+		OperationID: "listAlerts", Summary: "Alerts of the caller's watches and saved searches", Tags: []string{"watchlists"}, Query: PaginationParams{}, Response: PaginatedResponse[watchlists.Alert]{}, Private: true,
+	})
+
+	// Realtime updates
+	h.document(private.HandleFunc("/realtime/events", h.HTTPHandlerFunc(h.StreamEvents)).Methods("GET"), openapi.Operation{
+		OperationID: "streamEvents", Summary: "Server-Sent Events stream of updates for the caller", Tags: []string{"realtime"}, Query: RealtimeParams{}, Response: events.Event{}, Private: true,
+	})
+	h.document(private.HandleFunc("/realtime/ws", h.HTTPHandlerFunc(h.ServeWebSocket)).Methods("GET"), openapi.Operation{
+		OperationID: "serveWebSocket", Summary: "WebSocket stream of updates for the caller", Tags: []string{"realtime"}, Query: RealtimeParams{}, Response: events.Event{}, Status: http.StatusSwitchingProtocols, Private: true,
+	})
+
+	// Feature flags
+	h.document(private.HandleFunc("/flags", h.HTTPHandlerFunc(h.GetFlags)).Methods("GET"), openapi.Operation{
+		OperationID: "getFlags", Summary: "Flags evaluated for the caller", Tags: []string{"flags"}, Response: []flags.Evaluation{}, Private: true,
+	})
+	h.document(admin.HandleFunc("/flags", h.HTTPHandlerFunc(h.ListFlags)).Methods("GET"), openapi.Operation{
+		OperationID: "listFlags", Summary: "List flags", Tags: []string{"admin"}, Response: []flags.Flag{}, Private: true,
+	})
+	h.document(admin.HandleFunc("/flags/{key}", h.HTTPHandlerFunc(h.SaveFlag)).Methods("PUT"), openapi.Operation{
+		OperationID: "saveFlag", Summary: "Create or replace a flag", Tags: []string{"admin"}, Request: flags.Flag{}, Response: flags.Flag{}, Private: true,
+	})
+	h.document(admin.HandleFunc("/flags/{key}/toggle", h.HTTPHandlerFunc(h.ToggleFlag)).Methods("POST"), openapi.Operation{
+		OperationID: "toggleFlag", Summary: "Toggle a flag", Tags: []string{"admin"}, Request: ToggleFlagRequest{}, Response: flags.Flag{}, Private: true,
+	})
+	h.document(admin.HandleFunc("/flags/{key}", h.HTTPHandlerFunc(h.DeleteFlag)).Methods("DELETE"), openapi.Operation{
+		OperationID: "deleteFlag", Summary: "Delete a flag", Tags: []string{"admin"}, Status: http.StatusNoContent, Private: true,
+	})
+
+	// Catalog administration
+	h.document(admin.HandleFunc("/catalog/brands", h.HTTPHandlerFunc(h.CreateBrand)).Methods("POST"), openapi.Operation{
+		OperationID: "createBrand", Summary: "Create a brand", Tags: []string{"admin"}, Request: catalog.BrandInput{}, Response: catalog.Brand{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(admin.HandleFunc("/catalog/brands/{id}", h.HTTPHandlerFunc(h.UpdateBrand)).Methods("PUT"), openapi.Operation{
+		OperationID: "updateBrand", Summary: "Replace a brand", Tags: []string{"admin"}, Request: catalog.BrandInput{}, Response: catalog.Brand{}, Private: true,
+	})
+	h.document(admin.HandleFunc("/catalog/brands/{id}", h.HTTPHandlerFunc(h.DeleteBrand)).Methods("DELETE"), openapi.Operation{
+		OperationID: "deleteBrand", Summary: "Delete a brand", Tags: []string{"admin"}, Status: http.StatusNoContent, Private: true,
+	})
+	h.document(admin.HandleFunc("/catalog/notes", h.HTTPHandlerFunc(h.CreateNote)).Methods("POST"), openapi.Operation{
+		OperationID: "createNote", Summary: "Create a note", Tags: []string{"admin"}, Request: catalog.NoteInput{}, Response: catalog.Note{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(admin.HandleFunc("/catalog/notes/{id}", h.HTTPHandlerFunc(h.UpdateNote)).Methods("PUT"), openapi.Operation{
+		OperationID: "updateNote", Summary: "Replace a note", Tags: []string{"admin"}, Request: catalog.NoteInput{}, Response: catalog.Note{}, Private: true,
+	})
+	h.document(admin.HandleFunc("/catalog/notes/{id}", h.HTTPHandlerFunc(h.DeleteNote)).Methods("DELETE"), openapi.Operation{
+		OperationID: "deleteNote", Summary: "Delete a note", Tags: []string{"admin"}, Status: http.StatusNoContent, Private: true,
+	})
+	h.document(admin.HandleFunc("/catalog/fragrances", h.HTTPHandlerFunc(h.CreateFragrance)).Methods("POST"), openapi.Operation{
+		OperationID: "createFragrance", Summary: "Create a fragrance", Tags: []string{"admin"}, Request: catalog.FragranceInput{}, Response: catalog.Fragrance{}, Status: http.StatusCreated, Private: true,
+	})
+	h.document(admin.HandleFunc("/catalog/fragrances/duplicates", h.HTTPHandlerFunc(h.GetFragranceDuplicates)).Methods("GET"), openapi.Operation{
+		OperationID: "getFragranceDuplicates", Summary: "Likely duplicate fragrances", Tags: []string{"admin"}, Query: DuplicateParams{}, Response: []catalog.DuplicateCandidate{}, Private: true,
+	})
+	h.document(admin.HandleFunc("/catalog/fragrances/{id}", h.HTTPHandlerFunc(h.UpdateFragrance)).Methods("PUT"), openapi.Operation{
+		OperationID: "updateFragrance", Summary: "Replace a fragrance", Tags: []string{"admin"}, Request: catalog.FragranceInput{}, Response: catalog.Fragrance{}, Private: true,
+	})
+	h.document(admin.HandleFunc("/catalog/fragrances/{id}", h.HTTPHandlerFunc(h.DeleteFragrance)).Methods("DELETE"), openapi.Operation{
+		OperationID: "deleteFragrance", Summary: "Delete a fragrance", Tags: []string{"admin"}, Status: http.StatusNoContent, Private: true,
+	})
+	h.document(admin.HandleFunc("/catalog/fragrances/{id}/merge", h.HTTPHandlerFunc(h.MergeFragrance)).Methods("POST"), openapi.Operation{
+		OperationID: "mergeFragrance", Summary: "Merge a duplicate into a fragrance", Tags: []string{"admin"}, Request: MergeFragranceRequest{}, Response: catalog.Fragrance{}, Private: true,
+	})
+
+	// Order administration
+	h.document(admin.HandleFunc("/orders/{id}/refund", h.HTTPHandlerFunc(h.RefundOrder)).Methods("POST"), openapi.Operation{
+		OperationID: "refundOrder", Summary: "Refund an order", Tags: []string{"admin"}, Response: orders.Order{}, Private: true,
+	})
+	h.document(admin.HandleFunc("/orders/{id}/resolve", h.HTTPHandlerFunc(h.ResolveDispute)).Methods("POST"), openapi.Operation{
+		OperationID: "resolveDispute", Summary: "Resolve a disputed order", Tags: []string{"admin"}, Request: ResolveDisputeRequest{}, Response: orders.Order{}, Private: true,
+	})
+
+	// Message moderation
+	h.document(admin.HandleFunc("/messages/flagged", h.HTTPHandlerFunc(h.ListFlaggedMessages)).Methods("GET"), openapi.Operation{
+		OperationID: "listFlaggedMessages", Summary: "Messages flagged by moderation checks", Tags: []string{"admin"}, Query: PaginationParams{}, Response: PaginatedResponse[messages.FlaggedMessage]{}, Private: true,
+	})
+
+	// Audit history
+	h.document(admin.HandleFunc("/audit", h.HTTPHandlerFunc(h.GetAuditHistory)).Methods("GET"), openapi.Operation{
+		OperationID: "getAuditHistory", Summary: "Query audit history", Tags: []string{"admin"}, Query: AuditParams{}, Response: PaginatedResponse[audit.Entry]{}, Private: true,
+	})
+	h.document(admin.HandleFunc("/audit/resources/{resource_type}/{resource_id}", h.HTTPHandlerFunc(h.GetAuditHistory)).Methods("GET"), openapi.Operation{
+		OperationID: "getResourceAuditHistory", Summary: "Audit history of a resource", Tags: []string{"admin"}, Query: AuditParams{}, Response: PaginatedResponse[audit.Entry]{}, Private: true,
+	})
+	h.document(admin.HandleFunc("/audit/actors/{actor_id}", h.HTTPHandlerFunc(h.GetAuditHistory)).Methods("GET"), openapi.Operation{
+		OperationID: "getActorAuditHistory", Summary: "Audit history of an actor", Tags: []string{"admin"}, Query: AuditParams{}, Response: PaginatedResponse[audit.Entry]{}, Private: true,
+	})
+
+	h.deprecateVersion(v, api, private)
 }
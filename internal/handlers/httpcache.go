@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CachePolicy declares how a route's responses may be cached by browsers
+// and CDNs
+type CachePolicy struct {
+	// public responses may be stored by shared caches, otherwise private
+	Public  bool
+	NoStore bool
+
+	MaxAge               time.Duration
+	SharedMaxAge         time.Duration
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+
+	// Request headers that select between response variants
+	Vary []string
+
+	// Weak ETags for responses that are semantically but not byte equivalent
+	WeakETag bool
+}
+
+var (
+	// Public catalog data, served from CDN caches and revalidated in the background
+	PublicCatalogCache = CachePolicy{
+		Public:               true,
+		MaxAge:               time.Minute,
+		SharedMaxAge:         5 * time.Minute,
+		StaleWhileRevalidate: 10 * time.Minute,
+		StaleIfError:         time.Hour,
+		Vary:                 []string{"Accept-Encoding"},
+	}
+
+	// Never cached
+	NoStoreCache = CachePolicy{NoStore: true}
+)
+
+func (p CachePolicy) header() string {
+	if p.NoStore {
+		return "no-store"
+	}
+
+	directives := []string{"private"}
+	if p.Public {
+		directives = []string{"public"}
+	}
+	directives = append(directives, fmt.Sprintf("max-age=%d", int(p.MaxAge.Seconds())))
+	if p.SharedMaxAge > 0 && p.Public {
+		directives = append(directives, fmt.Sprintf("s-maxage=%d", int(p.SharedMaxAge.Seconds())))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, fmt.Sprintf("stale-while-revalidate=%d", int(p.StaleWhileRevalidate.Seconds())))
+	}
+	if p.StaleIfError > 0 {
+		directives = append(directives, fmt.Sprintf("stale-if-error=%d", int(p.StaleIfError.Seconds())))
+	}
+	return strings.Join(directives, ", ")
+}
+
+// Buffers the response so the ETag can be computed from the body
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// Set the Last-Modified header, compared against If-Modified-Since by the
+// cache middleware
+func SetLastModified(w http.ResponseWriter, t time.Time) {
+	if !t.IsZero() {
+		w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+func notModifiedSince(r *http.Request, lastModified string) bool {
+	if lastModified == "" || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// Apply a cache policy to a route. Successful GET and HEAD responses get
+// Cache-Control, Vary and an ETag computed from the body, and conditional
+// requests are answered with 304 Not Modified.
+func (h *Handler) WithCache(policy CachePolicy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, v := range policy.Vary {
+			w.Header().Add("Vary", v)
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		bw := &bufferedWriter{ResponseWriter: w}
+		next(bw, r)
+		if bw.status == 0 {
+			bw.status = http.StatusOK
+		}
+
+		// Only successful responses are cacheable
+		if bw.status != http.StatusOK {
+			w.WriteHeader(bw.status)
+			w.Write(bw.body.Bytes())
+			return
+		}
+
+		// Responses setting a cookie, like a fresh CSRF token, belong to one
+		// client and must never be handed out by a shared cache
+		if len(w.Header().Values("Set-Cookie")) > 0 {
+			policy = NoStoreCache
+		}
+		w.Header().Set("Cache-Control", policy.header())
+
+		etag := w.Header().Get("ETag")
+		if etag == "" && !policy.NoStore {
+			sum := sha256.Sum256(bw.body.Bytes())
+			etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+			if policy.WeakETag {
+				etag = "W/" + etag
+			}
+			w.Header().Set("ETag", etag)
+		}
+
+		if (etag != "" && notModified(r, etag)) || notModifiedSince(r, w.Header().Get("Last-Modified")) {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.WriteHeader(bw.status)
+		if r.Method != http.MethodHead {
+			w.Write(bw.body.Bytes())
+		}
+	}
+}
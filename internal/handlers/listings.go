@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+	"x/core/internal/listings"
+	"x/core/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+type ListingParams struct {
+	PaginationParams
+	Brand         string `schema:"brand"`
+	Concentration string `schema:"concentration"`
+	Condition     string `schema:"condition" validate:"oneof=sealed like_new good fair"`
+	FragranceID   string `schema:"fragrance_id"`
+	SellerID      string `schema:"seller_id"`
+	Price         string `schema:"price" doc:"Price bound, e.g. 100- or 50+"`
+	PctRemaining  string `schema:"pct_remaining" doc:"Remaining percentage bound, e.g. 80+"`
+	Split         string `schema:"split" validate:"oneof=true false" doc:"Only listings sold as decants, or only whole bottles"`
+}
+
+type SellerListingParams struct {
+	PaginationParams
+	Status string `schema:"status" validate:"oneof=draft active reserved sold withdrawn expired"`
+}
+
+type TransitionListingRequest struct {
+	Status listings.Status `json:"status" validate:"required,oneof=active withdrawn" doc:"active publishes a draft, withdrawn takes the listing off the market"`
+	Reason string          `json:"reason,omitempty" validate:"max=500"`
+}
+
+// Active listings, filtered by the listing filters
+func (h *Handler) ListListings(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[ListingParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	// Only whitelisted filters, field names end up in the query
+	filters := make(map[string]interface{})
+	for _, field := range service.ListingFilters {
+		if v := r.URL.Query().Get(field); v != "" {
+			filters[field] = v
+		}
+	}
+
+	records, totalPages, err := h.s.ListListings(r.Context(), filters, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[listings.Listing]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
+
+func (h *Handler) GetListing(w http.ResponseWriter, r *http.Request) error {
+	l, err := h.s.GetListing(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, l.ID, l.GetVersion(), l)
+}
+
+// Listings of the authenticated seller, in any status
+func (h *Handler) ListSellerListings(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[SellerListingParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	records, totalPages, err := h.s.ListSellerListings(r.Context(), listings.Status(params.Status), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[listings.Listing]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
+
+func (h *Handler) GetSellerListing(w http.ResponseWriter, r *http.Request) error {
+	l, err := h.s.GetSellerListing(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, l.ID, l.GetVersion(), l)
+}
+
+func (h *Handler) CreateListing(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[listings.ListingInput](r)
+	if err != nil {
+		return err
+	}
+
+	l, err := h.s.CreateListing(r.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusCreated, l.ID, l.GetVersion(), l)
+}
+
+func (h *Handler) UpdateListing(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+	version, err := requiredVersion(r, id)
+	if err != nil {
+		return err
+	}
+	in, err := DecodeJSON[listings.ListingInput](r)
+	if err != nil {
+		return err
+	}
+
+	l, err := h.s.UpdateListing(r.Context(), id, version, in)
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, l.ID, l.GetVersion(), l)
+}
+
+// Move a listing through its lifecycle, e.g. publish a draft or withdraw it
+func (h *Handler) TransitionListing(w http.ResponseWriter, r *http.Request) error {
+	req, err := DecodeJSON[TransitionListingRequest](r)
+	if err != nil {
+		return err
+	}
+
+	l, err := h.s.TransitionListing(r.Context(), mux.Vars(r)["id"], req.Status, req.Reason)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, l.ID, l.GetVersion(), l)
+}
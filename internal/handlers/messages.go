@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"x/core/internal/messages"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) StartConversation(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[messages.ConversationInput](r)
+	if err != nil {
+		return err
+	}
+
+	c, err := h.s.StartConversation(r.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) ListConversations(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[PaginationParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	records, totalPages, err := h.s.ListConversations(r.Context(), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[messages.Conversation]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
+
+func (h *Handler) GetConversation(w http.ResponseWriter, r *http.Request) error {
+	c, err := h.s.GetConversation(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, c)
+}
+
+// Message history, newest first. The next page is requested with the cursor
+// of the previous one.
+func (h *Handler) ListMessages(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[CursorParams](r)
+	if err != nil {
+		return err
+	}
+	limit := params.Normalize()
+
+	records, err := h.s.ListMessages(r.Context(), mux.Vars(r)["id"], params.Before, limit)
+	if err != nil {
+		return err
+	}
+
+	resp := CursorResponse[messages.Message]{Data: records}
+	if len(records) == limit {
+		resp.NextCursor = records[len(records)-1].ID
+	}
+	return h.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[messages.MessageInput](r)
+	if err != nil {
+		return err
+	}
+
+	m, err := h.s.SendMessage(r.Context(), mux.Vars(r)["id"], in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusCreated, m)
+}
+
+func (h *Handler) MarkConversationRead(w http.ResponseWriter, r *http.Request) error {
+	receipt, err := h.s.MarkConversationRead(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, receipt)
+}
+
+// Signed parameters to upload an image straight to Cloudinary, to attach to
+// a message afterwards
+func (h *Handler) CreateUploadTicket(w http.ResponseWriter, r *http.Request) error {
+	ticket, err := h.s.CreateUploadTicket(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusCreated, ticket)
+}
+
+func (h *Handler) ListBlocks(w http.ResponseWriter, r *http.Request) error {
+	records, err := h.s.ListBlocks(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, records)
+}
+
+func (h *Handler) BlockUser(w http.ResponseWriter, r *http.Request) error {
+	b, err := h.s.BlockUser(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, b)
+}
+
+func (h *Handler) UnblockUser(w http.ResponseWriter, r *http.Request) error {
+	if err := h.s.UnblockUser(r.Context(), mux.Vars(r)["id"]); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) ListFlaggedMessages(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[PaginationParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	records, totalPages, err := h.s.ListFlaggedMessages(r.Context(), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[messages.FlaggedMessage]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
@@ -10,7 +10,10 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"x/core/internal/auth"
+	"x/core/internal/reqctx"
 
+	"github.com/google/uuid"
 	"golang.org/x/time/rate"
 )
 
@@ -21,6 +24,52 @@ type Message struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+const (
+	RequestIDHeader = "X-Request-ID"
+	// Longest client request ID reused, longer ones are replaced
+	maxRequestIDLength = 128
+)
+
+// Assigns a request ID, reusing the client's if present and well formed, and
+// adds the request metadata to the context
+func (h *Handler) RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		ctx := reqctx.WithMeta(r.Context(), reqctx.Meta{
+			RequestID: requestID,
+			ClientIP:  ip,
+			UserAgent: r.UserAgent(),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Whether a client request ID is safe to log and echo back: at most
+// maxRequestIDLength letters, digits, '-', '_' or '.'
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func (h *Handler) LoggerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract the IP address from the request.
@@ -75,10 +124,18 @@ func (h *Handler) RateLimiterMiddleware(next http.Handler) http.Handler {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		// Policy is read per request so reloaded limits apply to existing clients
+		policy := h.conf.Load().RateLimit
+		limit := rate.Limit(policy.RequestsPerSecond)
+
 		// Lock the mutex to protect this section from race conditions.
 		mu.Lock()
 		if _, found := clients[ip]; !found {
-			clients[ip] = &client{limiter: rate.NewLimiter(3, 6)}
+			clients[ip] = &client{limiter: rate.NewLimiter(limit, policy.Burst)}
+		}
+		if clients[ip].limiter.Limit() != limit || clients[ip].limiter.Burst() != policy.Burst {
+			clients[ip].limiter.SetLimit(limit)
+			clients[ip].limiter.SetBurst(policy.Burst)
 		}
 		clients[ip].lastSeen = time.Now()
 		if !clients[ip].limiter.Allow() {
@@ -105,22 +162,24 @@ func (h *Handler) ClerkAuthMiddleware(next http.Handler) http.Handler {
 		type SessionName string
 		var ClerkSessionName SessionName = "clerksession"
 
-		// Get the session token from the Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
+		// Get the session token from the Authorization header, falling back
+		// to the session cookie of browser clients
+		var sessionToken string
+		if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+			// The token should be in the format "Bearer <token>"
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
+				return
+			}
+			sessionToken = parts[1]
+		} else if cookie, err := r.Cookie(SessionCookieName); err == nil && cookie.Value != "" {
+			sessionToken = cookie.Value
+		} else {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// The token should be in the format "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
-			return
-		}
-
-		sessionToken := parts[1]
-
 		// Verify the session
 		session, err := h.c.VerifyToken(sessionToken)
 		if err != nil {
@@ -128,8 +187,26 @@ func (h *Handler) ClerkAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Add the session to the request context
+		// Add the session and principal to the request context
 		ctx := context.WithValue(r.Context(), ClerkSessionName, session)
+		ctx = auth.WithPrincipal(ctx, auth.FromClerkSession(session, h.conf.Load().Clerk.AdminUserIDs))
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// Requires an authenticated admin principal, must run after ClerkAuthMiddleware
+func (h *Handler) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !principal.IsAdmin() {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
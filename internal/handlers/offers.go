@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"x/core/internal/offers"
+	"x/core/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+type OfferParams struct {
+	PaginationParams
+	Role   string `schema:"role" validate:"oneof=buyer seller" doc:"Offers made as buyer or received as seller, buyer by default"`
+	Status string `schema:"status" validate:"oneof=pending countered accepted declined withdrawn expired"`
+}
+
+func (h *Handler) MakeOffer(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[offers.OfferInput](r)
+	if err != nil {
+		return err
+	}
+
+	o, err := h.s.MakeOffer(r.Context(), mux.Vars(r)["id"], in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusCreated, o)
+}
+
+func (h *Handler) ListListingOffers(w http.ResponseWriter, r *http.Request) error {
+	records, err := h.s.ListListingOffers(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, records)
+}
+
+// Offers of the authenticated user, as buyer or seller
+func (h *Handler) ListOffers(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[OfferParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	records, totalPages, err := h.s.ListOffers(r.Context(), service.OfferRole(params.Role), offers.Status(params.Status), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[offers.Offer]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
+
+func (h *Handler) GetOffer(w http.ResponseWriter, r *http.Request) error {
+	o, err := h.s.GetOffer(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) CounterOffer(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[offers.OfferInput](r)
+	if err != nil {
+		return err
+	}
+
+	o, err := h.s.CounterOffer(r.Context(), mux.Vars(r)["id"], in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusCreated, o)
+}
+
+func (h *Handler) AcceptOffer(w http.ResponseWriter, r *http.Request) error {
+	o, err := h.s.AcceptOffer(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) DeclineOffer(w http.ResponseWriter, r *http.Request) error {
+	o, err := h.s.DeclineOffer(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) WithdrawOffer(w http.ResponseWriter, r *http.Request) error {
+	o, err := h.s.WithdrawOffer(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, o)
+}
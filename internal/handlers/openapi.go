@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"x/core/internal/openapi"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/plugin/optimisticlock"
+)
+
+const apiTitle = "Core API"
+
+func init() {
+	// Types with custom JSON marshalling
+	openapi.Override(reflect.TypeOf(gorm.DeletedAt{}), &openapi.Schema{Type: openapi.Types{"string", "null"}, Format: "date-time"})
+	openapi.Override(reflect.TypeOf(optimisticlock.Version{}), &openapi.Schema{Type: openapi.Types{"integer"}})
+}
+
+// Describe a registered route in the OpenAPI document
+func (h *Handler) document(route *mux.Route, op openapi.Operation) *mux.Route {
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return route
+	}
+	methods, err := route.GetMethods()
+	if err != nil {
+		return route
+	}
+
+	spec, ok := h.specs[templateVersion(template)]
+	if !ok {
+		return route
+	}
+	for _, method := range methods {
+		spec.AddOperation(method, template, op)
+	}
+	return route
+}
+
+// Generated OpenAPI document of an API version, populated by RegisterRoutes
+func (h *Handler) OpenAPI(version string) (*openapi.Document, bool) {
+	v, ok := findAPIVersion(version)
+	if !ok {
+		return nil, false
+	}
+	return h.specs[v.Name], true
+}
+
+// Latest API version, the default for exported documents
+func LatestAPIVersion() string {
+	return latestAPIVersion.Name
+}
+
+// Validates query parameters and request bodies of documented routes
+func (h *Handler) OpenAPIValidationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		spec, ok := h.specs[templateVersion(template)]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		op, ok := spec.Operation(r.Method, template)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		fields, err := op.ValidateRequest(r)
+		if err != nil {
+			h.HandleErrorResponse(w, err)
+			return
+		}
+		if len(fields) > 0 {
+			h.HandleErrorResponse(w, &ValidationError{Fields: fields})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OpenAPI document of the version the request was routed to
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, r *http.Request) error {
+	template, err := mux.CurrentRoute(r).GetPathTemplate()
+	if err != nil {
+		return err
+	}
+	spec, ok := h.OpenAPI(templateVersion(template))
+	if !ok {
+		return ErrUnsupportedVersion
+	}
+	return h.WriteJSON(w, http.StatusOK, spec)
+}
+
+const docsPage = `<!DOCTYPE html>
+<html>
+  <head>
+    <title>Core API</title>
+    <meta charset="utf-8"/>
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+  </head>
+  <body>
+    <redoc spec-url="openapi.json"></redoc>
+    <script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+  </body>
+</html>`
+
+func (h *Handler) GetDocs(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", docsContentSecurityPolicy)
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write([]byte(docsPage))
+	return err
+}
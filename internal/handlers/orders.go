@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"x/core/internal/orders"
+	"x/core/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+type OrderParams struct {
+	PaginationParams
+	Role   string `schema:"role" validate:"oneof=buyer seller" doc:"Orders placed as buyer or received as seller, buyer by default"`
+	Status string `schema:"status" validate:"oneof=pending authorized captured released refunded disputed canceled"`
+}
+
+type DisputeOrderRequest struct {
+	Reason string `json:"reason" validate:"required,max=2000"`
+}
+
+type ResolveDisputeRequest struct {
+	Outcome string `json:"outcome" validate:"required,oneof=release refund" doc:"Release the funds to the seller or refund the buyer"`
+}
+
+type PayoutAccountRequest struct {
+	AccountID string `json:"account_id" validate:"required,max=255" doc:"Account at the payment provider sales are paid out to"`
+}
+
+type WebhookResponse struct {
+	Received bool `json:"received"`
+}
+
+func (h *Handler) PlaceOrder(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[orders.OrderInput](r)
+	if err != nil {
+		return err
+	}
+
+	o, err := h.s.PlaceOrder(r.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusCreated, o)
+}
+
+// Orders of the authenticated user, as buyer or seller
+func (h *Handler) ListOrders(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[OrderParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	records, totalPages, err := h.s.ListOrders(r.Context(), service.OrderRole(params.Role), orders.Status(params.Status), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[orders.Order]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
+
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) error {
+	o, err := h.s.GetOrder(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) CancelOrder(w http.ResponseWriter, r *http.Request) error {
+	o, err := h.s.CancelOrder(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) ConfirmDelivery(w http.ResponseWriter, r *http.Request) error {
+	o, err := h.s.ConfirmDelivery(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) DisputeOrder(w http.ResponseWriter, r *http.Request) error {
+	req, err := DecodeJSON[DisputeOrderRequest](r)
+	if err != nil {
+		return err
+	}
+
+	o, err := h.s.DisputeOrder(r.Context(), mux.Vars(r)["id"], req.Reason)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) RefundOrder(w http.ResponseWriter, r *http.Request) error {
+	o, err := h.s.RefundOrder(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) ResolveDispute(w http.ResponseWriter, r *http.Request) error {
+	req, err := DecodeJSON[ResolveDisputeRequest](r)
+	if err != nil {
+		return err
+	}
+
+	o, err := h.s.ResolveDispute(r.Context(), mux.Vars(r)["id"], service.DisputeOutcome(req.Outcome))
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, o)
+}
+
+func (h *Handler) GetPayoutAccount(w http.ResponseWriter, r *http.Request) error {
+	account, err := h.s.GetPayoutAccount(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, account)
+}
+
+func (h *Handler) SavePayoutAccount(w http.ResponseWriter, r *http.Request) error {
+	req, err := DecodeJSON[PayoutAccountRequest](r)
+	if err != nil {
+		return err
+	}
+
+	account, err := h.s.SavePayoutAccount(r.Context(), req.AccountID)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, account)
+}
+
+// Payment provider webhook. The raw body is needed to verify the signature,
+// so it isn't decoded as JSON here.
+func (h *Handler) PaymentWebhook(w http.ResponseWriter, r *http.Request) error {
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, maxBytesErr.Limit)
+		}
+		return fmt.Errorf("error reading request body: %v", err)
+	}
+
+	if err := h.s.HandlePaymentWebhook(r.Context(), payload, r.Header); err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, WebhookResponse{Received: true})
+}
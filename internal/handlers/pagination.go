@@ -0,0 +1,60 @@
+package handlers
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Query parameters shared by paginated list endpoints
+type PaginationParams struct {
+	Page     int `schema:"page" validate:"min=1" doc:"Page number, starting at 1"`
+	PageSize int `schema:"page_size" validate:"min=1,max=100" doc:"Results per page"`
+}
+
+type PaginatedResponse[T any] struct {
+	Data       []T `json:"data"`
+	Page       int `json:"page"`
+	PageSize   int `json:"page_size"`
+	TotalPages int `json:"total_pages"`
+}
+
+// Page and page size with defaults for omitted values, clamped to sane bounds
+func (p PaginationParams) Normalize() (page, pageSize int) {
+	page, pageSize = p.Page, p.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	return page, pageSize
+}
+
+// Query parameters of endpoints paginated with a cursor, for histories that
+// grow while being read
+type CursorParams struct {
+	Before string `schema:"before" doc:"Cursor from the previous page, the id of its oldest result"`
+	Limit  int    `schema:"limit" validate:"min=1,max=100" doc:"Results per page"`
+}
+
+type CursorResponse[T any] struct {
+	Data []T `json:"data"`
+	// Cursor of the next page, empty on the last one
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Limit with the default for an omitted value, clamped to sane bounds
+func (p CursorParams) Normalize() int {
+	if p.Limit < 1 {
+		return defaultPageSize
+	}
+	if p.Limit > maxPageSize {
+		return maxPageSize
+	}
+
+	return p.Limit
+}
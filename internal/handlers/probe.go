@@ -26,7 +26,7 @@ func (h *Handler) Probe(w http.ResponseWriter, r *http.Request) error {
 		Status:      ServerStatusHealthy,
 		Message:     "pong",
 		DateTime:    time.Now(),
-		Environment: h.conf.Env,
+		Environment: h.conf.Load().Env,
 	}
 
 	return h.WriteJSON(w, http.StatusOK, pong)
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"x/core/internal/auth"
+	"x/core/internal/events"
+	"x/core/internal/metrics"
+	"x/core/internal/realtime"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// How long a single write to a realtime client may take
+	realtimeWriteWait = 10 * time.Second
+	// Largest message accepted from WebSocket clients, which only send
+	// control frames
+	realtimeReadLimit = 512
+	// How long SSE clients wait before reconnecting
+	sseRetry = 5 * time.Second
+)
+
+type RealtimeParams struct {
+	Listings []string `schema:"listing" doc:"Listings to receive public updates of, e.g. price changes"`
+}
+
+// Frame sent to SSE and WebSocket clients before the server closes the
+// connection
+type RealtimeClose struct {
+	Reason string `json:"reason"`
+}
+
+// Connect the caller to the realtime hub
+func (h *Handler) registerRealtime(r *http.Request) (*realtime.Client, error) {
+	p, err := auth.Require(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	params, err := DecodeQuery[RealtimeParams](r)
+	if err != nil {
+		return nil, err
+	}
+	return h.hub.Register(p.UserID, params.Listings)
+}
+
+// Whether the WebSocket handshake comes from the API's own origin or one
+// allowed by the CORS settings. Sessions may be carried by cookies, so other
+// origins must not be able to open connections on the user's behalf.
+func (h *Handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	return newCORSHandlers(h.conf.Load()).base.OriginAllowed(r)
+}
+
+// Server-Sent Events stream of the events concerning the caller. Idle
+// streams get a comment every heartbeat interval so proxies keep them open.
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) error {
+	c, err := h.registerRealtime(r)
+	if err != nil {
+		return err
+	}
+	defer h.hub.Unregister(c)
+
+	metrics.RealtimeConnections.WithLabelValues("sse").Inc()
+	defer metrics.RealtimeConnections.WithLabelValues("sse").Dec()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Extends the server's write timeout for every write of the stream
+	rc := http.NewResponseController(w)
+	write := func(format string, args ...any) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(realtimeWriteWait)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	if err := write("retry: %d\n\n", sseRetry.Milliseconds()); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(h.conf.Load().Realtime.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-c.Done():
+			data, _ := json.Marshal(RealtimeClose{Reason: c.Err().Error()})
+			write("event: close\ndata: %s\n\n", data)
+			return nil
+		case e := <-c.Events():
+			data, err := json.Marshal(e)
+			if err != nil {
+				h.z.Error().Err(err).Str("event", string(e.Type)).Msg("error encoding realtime event")
+				continue
+			}
+			if err := write("id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if err := write(": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// WebSocket stream of the events concerning the caller, one JSON event per
+// text message. Idle connections are pinged every heartbeat interval and
+// closed when pongs stop coming back.
+func (h *Handler) ServeWebSocket(w http.ResponseWriter, r *http.Request) error {
+	c, err := h.registerRealtime(r)
+	if err != nil {
+		return err
+	}
+	defer h.hub.Unregister(c)
+
+	upgrader := websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already answered the handshake
+		return nil
+	}
+	defer conn.Close()
+
+	metrics.RealtimeConnections.WithLabelValues("websocket").Inc()
+	defer metrics.RealtimeConnections.WithLabelValues("websocket").Dec()
+
+	interval := h.conf.Load().Realtime.HeartbeatInterval
+	pongWait := 2 * interval
+
+	// Reads only serve control frames, ending when the client goes away or
+	// stops answering pings
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(realtimeReadLimit)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(interval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return nil
+		case <-c.Done():
+			code := websocket.CloseGoingAway
+			if errors.Is(c.Err(), realtime.ErrLagging) {
+				code = websocket.CloseTryAgainLater
+			}
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, c.Err().Error()), time.Now().Add(realtimeWriteWait))
+			return nil
+		case e := <-c.Events():
+			if err := writeEvent(conn, e); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(realtimeWriteWait)); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+func writeEvent(conn *websocket.Conn, e events.Event) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(realtimeWriteWait)); err != nil {
+		return err
+	}
+	return conn.WriteJSON(e)
+}
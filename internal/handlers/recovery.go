@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"x/core/internal/metrics"
+	"x/core/internal/reqctx"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// Problem is an RFC 9457 problem details response
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Holds the response until the handler returns so a panic can replace it
+// with a clean 500. Flushed and hijacked responses are streamed and can no
+// longer be replaced.
+type recoveryWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	committed bool
+}
+
+func (rw *recoveryWriter) WriteHeader(status int) {
+	if rw.committed {
+		rw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if rw.status == 0 {
+		rw.status = status
+	}
+}
+
+func (rw *recoveryWriter) Write(p []byte) (int, error) {
+	if rw.committed {
+		return rw.ResponseWriter.Write(p)
+	}
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	return rw.body.Write(p)
+}
+
+// Send the held response
+func (rw *recoveryWriter) commit() {
+	if rw.committed {
+		return
+	}
+	rw.committed = true
+	if rw.status != 0 {
+		rw.ResponseWriter.WriteHeader(rw.status)
+	}
+	if rw.body.Len() > 0 {
+		rw.ResponseWriter.Write(rw.body.Bytes())
+		rw.body.Reset()
+	}
+}
+
+func (rw *recoveryWriter) Flush() {
+	rw.commit()
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *recoveryWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	rw.committed = true
+	return hj.Hijack()
+}
+
+func (rw *recoveryWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Recovers panics in later handlers. The panic is logged with its stack,
+// counted and reported to Sentry when monitoring is enabled, and the client
+// gets a 500 problem response instead of whatever was partially written.
+// Responses already streamed to the client are aborted instead.
+func (h *Handler) RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoveryWriter{ResponseWriter: w}
+		// Headers set before this point, e.g. CORS, are kept on a 500
+		outer := w.Header().Clone()
+
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				rw.commit()
+				return
+			}
+			// Deliberate aborts are left to the server
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			err, ok := rec.(error)
+			if !ok {
+				err = fmt.Errorf("%v", rec)
+			}
+			err = errors.WithStack(fmt.Errorf("panic: %w", err))
+
+			route := "unmatched"
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, terr := current.GetPathTemplate(); terr == nil {
+					route = template
+				}
+			}
+			meta, _ := reqctx.FromContext(r.Context())
+
+			h.z.Error().Stack().Err(err).
+				Str("request_id", meta.RequestID).
+				Str("method", r.Method).
+				Str("route", route).
+				Msg("recovered panic while serving request")
+			metrics.RecoveredPanics.WithLabelValues(route).Inc()
+
+			if hub := sentry.GetHubFromContext(r.Context()); hub != nil {
+				hub.RecoverWithContext(r.Context(), rec)
+			}
+
+			if rw.committed {
+				panic(http.ErrAbortHandler)
+			}
+
+			// Drop whatever the handler wrote, including its headers
+			for key := range w.Header() {
+				w.Header().Del(key)
+			}
+			for key, values := range outer {
+				w.Header()[key] = values
+			}
+			if meta.RequestID != "" {
+				w.Header().Set(RequestIDHeader, meta.RequestID)
+			}
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(Problem{
+				Type:      "about:blank",
+				Title:     http.StatusText(http.StatusInternalServerError),
+				Status:    http.StatusInternalServerError,
+				Detail:    "The server encountered an unexpected error.",
+				Instance:  r.URL.Path,
+				RequestID: meta.RequestID,
+			})
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
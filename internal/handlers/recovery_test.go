@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func newRecoveryHandler() *Handler {
+	logger := zerolog.Nop()
+	return &Handler{z: &logger}
+}
+
+// Serve the request, returning the recorded response and the value the
+// middleware panicked with, if any
+func serveRecovered(t *testing.T, handler http.Handler) (rec *httptest.ResponseRecorder, panicked any) {
+	t.Helper()
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/boom", nil)
+
+	defer func() {
+		panicked = recover()
+	}()
+	handler.ServeHTTP(rec, req)
+	return rec, nil
+}
+
+func TestRecoveryReplacesPartialResponse(t *testing.T) {
+	h := newRecoveryHandler()
+	handler := h.RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Partial", "yes")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"partial":`))
+		panic("boom")
+	}))
+
+	rec, panicked := serveRecovered(t, handler)
+	if panicked != nil {
+		t.Fatalf("panic escaped the middleware: %v", panicked)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if got := rec.Header().Get("X-Partial"); got != "" {
+		t.Errorf("header set by the handler was kept: %q", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("content type = %q, want application/problem+json", got)
+	}
+	if strings.Contains(rec.Body.String(), "partial") {
+		t.Errorf("partial body was sent: %s", rec.Body.String())
+	}
+
+	var problem Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("body is not a problem: %v", err)
+	}
+	if problem.Status != http.StatusInternalServerError {
+		t.Errorf("problem status = %d, want %d", problem.Status, http.StatusInternalServerError)
+	}
+}
+
+func TestRecoveryAbortsFlushedResponse(t *testing.T) {
+	h := newRecoveryHandler()
+	handler := h.RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: streamed\n\n"))
+		w.(http.Flusher).Flush()
+		panic("boom")
+	}))
+
+	rec, panicked := serveRecovered(t, handler)
+	if panicked != http.ErrAbortHandler {
+		t.Fatalf("panicked with %v, want http.ErrAbortHandler", panicked)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want the streamed %d", rec.Code, http.StatusOK)
+	}
+	if !rec.Flushed {
+		t.Error("response was not flushed")
+	}
+	if body := rec.Body.String(); body != "data: streamed\n\n" {
+		t.Errorf("body = %q, want only the streamed data", body)
+	}
+}
+
+func TestRecoveryRepanicsAbortHandler(t *testing.T) {
+	h := newRecoveryHandler()
+	handler := h.RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	rec, panicked := serveRecovered(t, handler)
+	if panicked != http.ErrAbortHandler {
+		t.Fatalf("panicked with %v, want http.ErrAbortHandler", panicked)
+	}
+	if rec.Body.Len() > 0 {
+		t.Errorf("aborted response has a body: %s", rec.Body.String())
+	}
+}
+
+func TestRecoveryKeepsOuterHeaders(t *testing.T) {
+	h := newRecoveryHandler()
+	cors := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", "https://app.example.com")
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := cors(h.RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("X-Partial", "yes")
+		panic("boom")
+	})))
+
+	rec, panicked := serveRecovered(t, handler)
+	if panicked != nil {
+		t.Fatalf("panic escaped the middleware: %v", panicked)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the outer value", got)
+	}
+	if got := rec.Header().Get("X-Partial"); got != "" {
+		t.Errorf("header set by the handler was kept: %q", got)
+	}
+}
+
+// RegisterRoutes recovers both around the outer handlers and inside the
+// router, the inner middleware's response must pass through the outer one
+func TestRecoveryNested(t *testing.T) {
+	h := newRecoveryHandler()
+	handler := h.RecoveryMiddleware(h.RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("boom")
+	})))
+
+	rec, panicked := serveRecovered(t, handler)
+	if panicked != nil {
+		t.Fatalf("panic escaped the middleware: %v", panicked)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	var problem Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("body is not a single problem: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"x/core/internal/reviews"
+	"x/core/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+type UserReviewParams struct {
+	PaginationParams
+	As string `schema:"as" validate:"oneof=buyer seller" doc:"Only reviews received as the buyer or the seller of orders"`
+}
+
+// Review windows and reputation decay from the live config
+func (h *Handler) reviewPolicy() reviews.Policy {
+	m := h.conf.Load().Marketplace
+	return reviews.Policy{
+		ReviewWindow: m.ReviewWindow,
+		EditWindow:   m.ReviewEditWindow,
+		HalfLife:     m.ReputationHalfLife,
+	}
+}
+
+func (h *Handler) CreateReview(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[reviews.ReviewInput](r)
+	if err != nil {
+		return err
+	}
+
+	review, err := h.s.CreateReview(r.Context(), mux.Vars(r)["id"], in, h.reviewPolicy())
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusCreated, review.ID, review.GetVersion(), review)
+}
+
+func (h *Handler) UpdateReview(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+	version, err := requiredVersion(r, id)
+	if err != nil {
+		return err
+	}
+	in, err := DecodeJSON[reviews.ReviewInput](r)
+	if err != nil {
+		return err
+	}
+
+	review, err := h.s.UpdateReview(r.Context(), id, version, in)
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, review.ID, review.GetVersion(), review)
+}
+
+func (h *Handler) ListOrderReviews(w http.ResponseWriter, r *http.Request) error {
+	records, err := h.s.ListOrderReviews(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, records)
+}
+
+// Public reviews a user received
+func (h *Handler) ListUserReviews(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[UserReviewParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	records, totalPages, err := h.s.ListUserReviews(r.Context(), mux.Vars(r)["id"], service.OrderRole(params.As), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[reviews.Review]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
+
+// Public reputation of a user
+func (h *Handler) GetReputation(w http.ResponseWriter, r *http.Request) error {
+	rep, err := h.s.GetReputation(r.Context(), mux.Vars(r)["id"], h.reviewPolicy())
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, rep)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"x/core/internal/search"
+)
+
+type SearchParams struct {
+	PaginationParams
+	Query         string `schema:"q" doc:"Search text, matched against name, brand and notes"`
+	Brand         string `schema:"brand"`
+	Concentration string `schema:"concentration"`
+	Currency      string `schema:"currency" validate:"oneof=USD EUR GBP" doc:"Only listings priced in the currency, price bounds are in its units"`
+	Price         string `schema:"price" doc:"Price bound, e.g. 100- or 50+"`
+	PctRemaining  string `schema:"pct_remaining" doc:"Remaining percentage bound, e.g. 80+"`
+}
+
+// Full text search over listings, combined with the listing filters
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[SearchParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	q := search.Query{
+		Text:     params.Query,
+		Filters:  params.filters(),
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	result, err := h.s.SearchListings(r.Context(), q)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, result)
+}
+
+// Filters of the decoded parameters, mapped onto persist.ApplyFilters
+func (p SearchParams) filters() map[string]interface{} {
+	filters := make(map[string]interface{})
+	for field, v := range map[string]string{
+		"brand":         p.Brand,
+		"concentration": p.Concentration,
+		"currency":      p.Currency,
+		"price":         p.Price,
+		"pct_remaining": p.PctRemaining,
+	} {
+		if v != "" {
+			filters[field] = v
+		}
+	}
+	return filters
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	CSRFCookieName    = "csrf_token"
+	CSRFHeader        = "X-CSRF-Token"
+	SessionCookieName = "__session"
+	csrfTokenBytes    = 32
+)
+
+var ErrCSRF = errors.New("missing or invalid csrf token")
+
+// CSP of the API docs page, which loads redoc from its CDN
+const docsContentSecurityPolicy = "default-src 'none'; " +
+	"script-src https://cdn.redoc.ly; " +
+	"style-src 'unsafe-inline' https://fonts.googleapis.com; " +
+	"font-src https://fonts.gstatic.com; " +
+	"img-src 'self' data: https://cdn.redoc.ly; " +
+	"worker-src blob:; " +
+	"connect-src 'self'; " +
+	"frame-ancestors 'none'"
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// Sets the security headers configured for every response. Handlers may
+// replace them, e.g. the docs page relaxes the content security policy.
+func (h *Handler) SecurityHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conf := h.conf.Load()
+		policy := conf.Security
+
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Content-Security-Policy", policy.ContentSecurityPolicy)
+		header.Set("X-Frame-Options", policy.FrameOptions)
+		header.Set("Referrer-Policy", policy.ReferrerPolicy)
+		header.Set("Cross-Origin-Opener-Policy", "same-origin")
+
+		// HSTS is only honoured over https and would pin localhost in browsers
+		if conf.Env != "local" && isHTTPS(r) && policy.HSTSMaxAge > 0 {
+			header.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", int(policy.HSTSMaxAge.Seconds())))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Requests authenticated by the session cookie rather than a bearer token,
+// the only ones a browser attaches credentials to on its own
+func cookieAuthenticated(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	_, err := r.Cookie(SessionCookieName)
+	return err == nil
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// Double submit cookie CSRF protection. Every client gets a random token in
+// a cookie readable by the frontend, which must echo it in the X-CSRF-Token
+// header on unsafe requests authenticated by the session cookie. Another
+// origin can neither read the cookie nor set the header. Responses issuing a
+// token are never stored, a shared cache would hand one token to everyone.
+func (h *Handler) CSRFMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(CSRFCookieName)
+		if err != nil || cookie.Value == "" {
+			token, err := newCSRFToken()
+			if err != nil {
+				h.HandleErrorResponse(w, fmt.Errorf("error generating csrf token: %v", err))
+				return
+			}
+			cookie = &http.Cookie{
+				Name:     CSRFCookieName,
+				Value:    token,
+				Path:     "/",
+				Secure:   h.conf.Load().Env != "local",
+				SameSite: http.SameSiteLaxMode,
+			}
+			http.SetCookie(w, cookie)
+			w.Header().Set("Cache-Control", "private, no-store")
+
+			// A fresh token can't have been submitted yet
+			cookie.Value = ""
+		}
+
+		if !safeMethod(r.Method) && cookieAuthenticated(r) {
+			submitted := r.Header.Get(CSRFHeader)
+			if cookie.Value == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(cookie.Value)) != 1 {
+				h.HandleErrorResponse(w, ErrCSRF)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"x/core/internal/config"
+
+	"github.com/rs/zerolog"
+)
+
+func newSecurityHandler() *Handler {
+	logger := zerolog.Nop()
+	return &Handler{z: &logger, conf: config.NewLive(config.Config{Env: "local"})}
+}
+
+func TestCSRFTokenNotPubliclyCached(t *testing.T) {
+	h := newSecurityHandler()
+	handler := h.CSRFMiddleware(h.WithCache(PublicCatalogCache, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"brands":[]}`))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/catalog/brands", nil))
+
+	if len(rec.Result().Cookies()) == 0 {
+		t.Fatal("no csrf cookie was issued")
+	}
+	cacheControl := rec.Header().Get("Cache-Control")
+	if !strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "public") {
+		t.Errorf("Cache-Control = %q, want no-store for a response setting a cookie", cacheControl)
+	}
+	if etag := rec.Header().Get("ETag"); etag != "" {
+		t.Errorf("ETag = %q, want none for a response that isn't stored", etag)
+	}
+}
+
+func TestCSRFExistingTokenKeepsCachePolicy(t *testing.T) {
+	h := newSecurityHandler()
+	handler := h.CSRFMiddleware(h.WithCache(PublicCatalogCache, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"brands":[]}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/catalog/brands", nil)
+	req.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: "token"})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if cookies := rec.Result().Cookies(); len(cookies) > 0 {
+		t.Errorf("cookies were set for a client that has a token: %v", cookies)
+	}
+	if got, want := rec.Header().Get("Cache-Control"), PublicCatalogCache.header(); got != want {
+		t.Errorf("Cache-Control = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"x/core/internal/shipping"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) ListAddresses(w http.ResponseWriter, r *http.Request) error {
+	records, err := h.s.ListAddresses(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, records)
+}
+
+func (h *Handler) CreateAddress(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[shipping.AddressInput](r)
+	if err != nil {
+		return err
+	}
+
+	a, err := h.s.CreateAddress(r.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusCreated, a.ID, a.GetVersion(), a)
+}
+
+func (h *Handler) UpdateAddress(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+	version, err := requiredVersion(r, id)
+	if err != nil {
+		return err
+	}
+	in, err := DecodeJSON[shipping.AddressInput](r)
+	if err != nil {
+		return err
+	}
+
+	a, err := h.s.UpdateAddress(r.Context(), id, version, in)
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, a.ID, a.GetVersion(), a)
+}
+
+func (h *Handler) DeleteAddress(w http.ResponseWriter, r *http.Request) error {
+	if err := h.s.DeleteAddress(r.Context(), mux.Vars(r)["id"]); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Shipping rates for one of the seller's paid orders
+func (h *Handler) QuoteShipping(w http.ResponseWriter, r *http.Request) error {
+	rates, err := h.s.QuoteShipping(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, rates)
+}
+
+func (h *Handler) ShipOrder(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[shipping.ShipmentInput](r)
+	if err != nil {
+		return err
+	}
+
+	shipment, err := h.s.ShipOrder(r.Context(), mux.Vars(r)["id"], in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusCreated, shipment)
+}
+
+func (h *Handler) GetShipment(w http.ResponseWriter, r *http.Request) error {
+	shipment, err := h.s.GetShipment(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, shipment)
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"strings"
+	"x/core/internal/openapi"
+)
+
+// ValidationError carries field level failures back to the client
+type ValidationError struct {
+	Fields []openapi.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+" "+f.Message)
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
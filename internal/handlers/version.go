@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"x/core/internal/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	APIVersionHeader       = "API-Version"
+	AcceptVersionHeader    = "Accept-Version"
+	ClientNameHeader       = "X-Client-Name"
+	apiPrefix              = "/api"
+	versionedMediaTypeBase = "application/vnd.core"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported api version")
+
+// Deprecation of a route or a whole API version, announced to clients with
+// the Deprecation, Sunset and Link headers
+type Deprecation struct {
+	// When the route was deprecated
+	Since time.Time
+	// When the route stops being served, zero if not scheduled yet
+	Sunset time.Time
+	// Migration docs for clients moving off the route
+	Link string
+}
+
+type apiVersion struct {
+	Name        string
+	SpecVersion string
+	Deprecation *Deprecation
+}
+
+// Served API versions, oldest first. Unversioned /api requests without a
+// version header are routed to the default version.
+var (
+	apiVersions = []apiVersion{
+		{Name: "v1", SpecVersion: "1.0.0"},
+		{Name: "v2", SpecVersion: "2.0.0"},
+	}
+	defaultAPIVersion = apiVersions[0]
+	latestAPIVersion  = apiVersions[len(apiVersions)-1]
+)
+
+func findAPIVersion(name string) (apiVersion, bool) {
+	if name != "" && !strings.HasPrefix(name, "v") {
+		name = "v" + name
+	}
+	for _, v := range apiVersions {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return apiVersion{}, false
+}
+
+var versionedPath = regexp.MustCompile(`^` + apiPrefix + `/(v\d+)(/|$)`)
+
+// Version a path template belongs to, e.g. v2 for /api/v2/search
+func templateVersion(template string) string {
+	if m := versionedPath.FindStringSubmatch(template); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Version requested through the Accept-Version header or a versioned media
+// type such as application/vnd.core.v2+json
+func requestedVersion(r *http.Request) string {
+	if v := r.Header.Get(AcceptVersionHeader); v != "" {
+		return strings.TrimSpace(v)
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil || !strings.HasPrefix(mediaType, versionedMediaTypeBase) {
+			continue
+		}
+		if v, ok := params["version"]; ok {
+			return v
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(mediaType, versionedMediaTypeBase+"."), "+")
+		if name != "" && name != mediaType {
+			return name
+		}
+	}
+	return ""
+}
+
+// Route unversioned /api requests to the negotiated version by rewriting
+// the path before it is matched
+func (h *Handler) VersionNegotiation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if path != apiPrefix && !strings.HasPrefix(path, apiPrefix+"/") || versionedPath.MatchString(path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Shared caches must key negotiated responses on the version headers
+		w.Header().Add("Vary", AcceptVersionHeader)
+		w.Header().Add("Vary", "Accept")
+
+		version := defaultAPIVersion
+		if requested := requestedVersion(r); requested != "" {
+			v, ok := findAPIVersion(requested)
+			if !ok {
+				h.HandleErrorResponse(w, fmt.Errorf("%w: %s", ErrUnsupportedVersion, requested))
+				return
+			}
+			version = v
+		}
+
+		r.URL.Path = apiPrefix + "/" + version.Name + strings.TrimPrefix(path, apiPrefix)
+		r.URL.RawPath = ""
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Client label values of the deprecation metrics. Anything else is counted
+// as "other" so callers can't grow the label's cardinality.
+var knownClients = map[string]string{
+	// X-Client-Name of our own apps
+	"web":     "web",
+	"ios":     "ios",
+	"android": "android",
+	// User agent products
+	"mozilla":           "browser",
+	"curl":              "curl",
+	"okhttp":            "okhttp",
+	"go-http-client":    "go",
+	"python-requests":   "python",
+	"postmanruntime":    "postman",
+	"cfnetwork":         "ios",
+	"dalvik":            "android",
+	"node-fetch":        "node",
+	"axios":             "node",
+	"insomnia":          "insomnia",
+	"apache-httpclient": "java",
+}
+
+// Name of the calling client for deprecation metrics, from the client name
+// header or the product token of the user agent, mapped onto knownClients
+func clientName(r *http.Request) string {
+	name := r.Header.Get(ClientNameHeader)
+	if name == "" {
+		name, _, _ = strings.Cut(r.UserAgent(), "/")
+	}
+	if name == "" {
+		return "unknown"
+	}
+	if client, ok := knownClients[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return client
+	}
+	return "other"
+}
+
+// Deprecation, Sunset and Link headers of a deprecated route
+func (d Deprecation) setHeaders(w http.ResponseWriter) {
+	if d.Since.IsZero() {
+		w.Header().Set("Deprecation", "true")
+	} else {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, d.Link))
+	}
+}
+
+func deprecated(d Deprecation, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.setHeaders(w)
+
+		template, _ := mux.CurrentRoute(r).GetPathTemplate()
+		metrics.DeprecatedRequests.WithLabelValues(template, templateVersion(template), clientName(r)).Inc()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Mark a route deprecated. Responses carry the deprecation headers, calls
+// are counted per client and the operation is flagged in the OpenAPI
+// document.
+func (h *Handler) deprecate(route *mux.Route, d Deprecation) *mux.Route {
+	route.Handler(deprecated(d, route.GetHandler()))
+
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return route
+	}
+	methods, err := route.GetMethods()
+	if err != nil {
+		return route
+	}
+	if spec, ok := h.specs[templateVersion(template)]; ok {
+		for _, method := range methods {
+			if op, ok := spec.Operation(method, template); ok {
+				op.Deprecated = true
+			}
+		}
+	}
+	return route
+}
+
+// Announces the version serving the request
+func (v apiVersion) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(APIVersionHeader, v.Name)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Deprecate every route of a deprecated version
+func (h *Handler) deprecateVersion(v apiVersion, routers ...*mux.Router) {
+	if v.Deprecation == nil {
+		return
+	}
+	for _, router := range routers {
+		router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			if route.GetHandler() != nil {
+				h.deprecate(route, *v.Deprecation)
+			}
+			return nil
+		})
+	}
+}
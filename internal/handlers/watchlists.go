@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"x/core/internal/watchlists"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) ListWatches(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[PaginationParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	records, totalPages, err := h.s.ListWatches(r.Context(), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[watchlists.Watch]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
+
+// Watch a fragrance or a listing, or change the price limit of an existing
+// watch
+func (h *Handler) SaveWatch(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[watchlists.WatchInput](r)
+	if err != nil {
+		return err
+	}
+
+	watch, err := h.s.SaveWatch(r.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, watch)
+}
+
+func (h *Handler) DeleteWatch(w http.ResponseWriter, r *http.Request) error {
+	if err := h.s.DeleteWatch(r.Context(), mux.Vars(r)["id"]); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) ListSavedSearches(w http.ResponseWriter, r *http.Request) error {
+	records, err := h.s.ListSavedSearches(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, records)
+}
+
+func (h *Handler) CreateSavedSearch(w http.ResponseWriter, r *http.Request) error {
+	in, err := DecodeJSON[watchlists.SavedSearchInput](r)
+	if err != nil {
+		return err
+	}
+
+	s, err := h.s.CreateSavedSearch(r.Context(), in)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteVersioned(w, r, http.StatusCreated, s.ID, s.GetVersion(), s)
+}
+
+func (h *Handler) UpdateSavedSearch(w http.ResponseWriter, r *http.Request) error {
+	id := mux.Vars(r)["id"]
+	version, err := requiredVersion(r, id)
+	if err != nil {
+		return err
+	}
+	in, err := DecodeJSON[watchlists.SavedSearchInput](r)
+	if err != nil {
+		return err
+	}
+
+	s, err := h.s.UpdateSavedSearch(r.Context(), id, version, in)
+	if err != nil {
+		return preconditionError(err)
+	}
+
+	return h.WriteVersioned(w, r, http.StatusOK, s.ID, s.GetVersion(), s)
+}
+
+func (h *Handler) DeleteSavedSearch(w http.ResponseWriter, r *http.Request) error {
+	if err := h.s.DeleteSavedSearch(r.Context(), mux.Vars(r)["id"]); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Alerts of the caller's watches and saved searches, newest first. Alerts
+// over the rate limit are listed but weren't pushed.
+func (h *Handler) ListAlerts(w http.ResponseWriter, r *http.Request) error {
+	params, err := DecodeQuery[PaginationParams](r)
+	if err != nil {
+		return err
+	}
+	page, pageSize := params.Normalize()
+
+	records, totalPages, err := h.s.ListAlerts(r.Context(), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	return h.WriteJSON(w, http.StatusOK, PaginatedResponse[watchlists.Alert]{
+		Data: records, Page: page, PageSize: pageSize, TotalPages: totalPages,
+	})
+}
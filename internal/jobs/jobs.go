@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Job runs periodically in the background until the scheduler context ends
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	z    *zerolog.Logger
+	jobs []Job
+	wg   sync.WaitGroup
+}
+
+func NewScheduler(logger *zerolog.Logger) *Scheduler {
+	return &Scheduler{
+		z: logger,
+	}
+}
+
+// Add a job, jobs without an interval are skipped
+func (s *Scheduler) Add(job Job) {
+	if job.Interval <= 0 {
+		s.z.Warn().Str("job", job.Name).Msg("job has no interval, skipping")
+		return
+	}
+	s.jobs = append(s.jobs, job)
+}
+
+// Start every job in its own goroutine
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					start := time.Now()
+					if err := job.Run(ctx); err != nil {
+						s.z.Error().Err(err).Str("job", job.Name).Msg("job failed")
+						continue
+					}
+					s.z.Debug().Str("job", job.Name).Dur("duration", time.Since(start)).Msg("job completed")
+				}
+			}
+		}(job)
+
+		s.z.Info().Str("job", job.Name).Dur("interval", job.Interval).Msg("job scheduled")
+	}
+}
+
+// Block until all jobs have stopped
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
@@ -0,0 +1,232 @@
+package listings
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+	"x/core/internal/auth"
+	"x/core/internal/events"
+	"x/core/internal/persist"
+)
+
+const (
+	// Most images a listing can have
+	MaxImages = 10
+	// Most vial sizes a split listing can be sold in
+	MaxDecants = 5
+)
+
+var (
+	ErrTooManyImages     = fmt.Errorf("a listing can have at most %d images", MaxImages)
+	ErrTooManyDecants    = fmt.Errorf("a listing can have at most %d decant sizes", MaxDecants)
+	ErrInvalidDecant     = errors.New("invalid decant sizes")
+	ErrInvalidTransition = errors.New("invalid listing status transition")
+	ErrNotOwner          = errors.New("listing belongs to another seller")
+	ErrNotEditable       = errors.New("listing can no longer be edited")
+	ErrNotSplit          = errors.New("listing is not sold as decants")
+	ErrOutOfStock        = errors.New("not enough volume left in the bottle")
+)
+
+type Status string
+
+const (
+	StatusDraft     Status = "draft"
+	StatusActive    Status = "active"
+	StatusReserved  Status = "reserved"
+	StatusSold      Status = "sold"
+	StatusWithdrawn Status = "withdrawn"
+	StatusExpired   Status = "expired"
+)
+
+// Statuses each status can move to, sold, withdrawn and expired are final
+var transitions = map[Status][]Status{
+	StatusDraft:    {StatusActive, StatusWithdrawn},
+	StatusActive:   {StatusReserved, StatusWithdrawn, StatusExpired},
+	StatusReserved: {StatusActive, StatusSold, StatusWithdrawn},
+}
+
+func (s Status) CanTransition(to Status) bool {
+	return slices.Contains(transitions[s], to)
+}
+
+func (s Status) Final() bool {
+	return len(transitions[s]) == 0
+}
+
+// Only drafts and active listings can be edited by their seller
+func (s Status) Editable() bool {
+	return s == StatusDraft || s == StatusActive
+}
+
+const EventPriceChanged events.Type = "listing.price_changed"
+
+const resourceType = "listing"
+
+// PriceChange is published when the seller reprices a published listing.
+// It concerns anyone following the listing rather than particular users.
+type PriceChange struct {
+	ListingID string `json:"listing_id"`
+	Previous  int    `json:"previous"`
+	Price     int    `json:"price"`
+	Currency  string `json:"currency"`
+}
+
+func (c *PriceChange) Event() events.Event {
+	return events.New(EventPriceChanged, resourceType, c.ListingID, c)
+}
+
+type Condition string
+
+const (
+	ConditionSealed  Condition = "sealed"
+	ConditionLikeNew Condition = "like_new"
+	ConditionGood    Condition = "good"
+	ConditionFair    Condition = "fair"
+)
+
+// Listing is a bottle offered for sale by a seller. Catalog details of the
+// fragrance are copied onto the listing so it can be searched on its own.
+type Listing struct {
+	persist.Base
+	SellerID    string `gorm:"not null;index" json:"seller_id"`
+	FragranceID string `gorm:"type:uuid;not null;index" json:"fragrance_id"`
+	Status      Status `gorm:"not null;index;default:draft" json:"status"`
+
+	Name          string `gorm:"not null" json:"name"`
+	Brand         string `gorm:"not null;index" json:"brand"`
+	Concentration string `gorm:"not null;index" json:"concentration"`
+	Notes         string `json:"notes,omitempty"`
+
+	// Whole currency units
+	Price        int       `gorm:"not null;index" json:"price"`
+	Currency     string    `gorm:"not null;default:USD" json:"currency"`
+	PctRemaining float64   `gorm:"not null" json:"pct_remaining"`
+	BottleSizeML int       `gorm:"not null" json:"bottle_size_ml"`
+	BatchCode    string    `json:"batch_code,omitempty"`
+	Condition    Condition `gorm:"not null" json:"condition"`
+	Images       []string  `gorm:"serializer:json" json:"images"`
+	Description  string    `json:"description,omitempty"`
+
+	// Split listings sell the bottle's remaining volume in decants instead of
+	// as a whole. Allocated volume is held by or sold to decant orders.
+	Split       bool     `gorm:"not null;default:false;index" json:"split"`
+	VolumeML    float64  `gorm:"not null;default:0" json:"volume_ml"`
+	AllocatedML float64  `gorm:"not null;default:0;check:chk_listing_allocated,allocated_ml <= volume_ml" json:"allocated_ml"`
+	Decants     []Decant `json:"decants,omitempty"`
+
+	PublishedAt *time.Time `gorm:"index" json:"published_at,omitempty"`
+	ClosedAt    *time.Time `json:"closed_at,omitempty"`
+
+	Transitions []Transition `json:"transitions,omitempty"`
+}
+
+// Volume left to sell as decants
+func (l *Listing) AvailableML() float64 {
+	return max(0, l.VolumeML-l.AllocatedML)
+}
+
+// Decant is a vial size a split listing is sold in, at a price per vial
+type Decant struct {
+	ListingID string `gorm:"type:uuid;primaryKey" json:"-"`
+	SizeML    int    `gorm:"primaryKey;autoIncrement:false" json:"size_ml"`
+	// Whole currency units per vial
+	Price int `gorm:"not null" json:"price"`
+}
+
+func (Decant) TableName() string {
+	return "listing_decants"
+}
+
+// Transition is an append-only record of a listing status change
+type Transition struct {
+	ID        uint64         `gorm:"primaryKey" json:"id"`
+	ListingID string         `gorm:"type:uuid;not null;index" json:"listing_id"`
+	From      Status         `gorm:"not null" json:"from"`
+	To        Status         `gorm:"not null" json:"to"`
+	ActorID   string         `gorm:"index" json:"actor_id,omitempty"`
+	ActorType auth.ActorType `json:"actor_type,omitempty"`
+	Reason    string         `json:"reason,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func (Transition) TableName() string {
+	return "listing_transitions"
+}
+
+// ListingInput creates or replaces the seller editable details of a listing
+type ListingInput struct {
+	FragranceID  string        `json:"fragrance_id" validate:"required"`
+	Price        int           `json:"price" validate:"required,min=1,max=100000"`
+	Currency     string        `json:"currency,omitempty" validate:"oneof=USD EUR GBP"`
+	PctRemaining float64       `json:"pct_remaining" validate:"required,min=1,max=100"`
+	BottleSizeML int           `json:"bottle_size_ml" validate:"required,min=1,max=1000"`
+	BatchCode    string        `json:"batch_code,omitempty" validate:"max=40"`
+	Condition    Condition     `json:"condition" validate:"required,oneof=sealed like_new good fair"`
+	Images       []string      `json:"images,omitempty"`
+	Description  string        `json:"description,omitempty" validate:"max=4000"`
+	Decants      []DecantInput `json:"decants,omitempty" doc:"Vial sizes to sell the remaining volume in instead of the whole bottle"`
+}
+
+type DecantInput struct {
+	SizeML int `json:"size_ml" validate:"required,min=1,max=30"`
+	Price  int `json:"price" validate:"required,min=1,max=10000" doc:"Price per vial"`
+}
+
+func (in ListingInput) currency() string {
+	if in.Currency == "" {
+		return "USD"
+	}
+	return in.Currency
+}
+
+// Remaining volume of the bottle, rounded to a tenth of a millilitre
+func (in ListingInput) volume() float64 {
+	return math.Round(float64(in.BottleSizeML)*in.PctRemaining/10) / 10
+}
+
+// Decants of the input, each size once and fitting in the remaining volume
+func (in ListingInput) decants() ([]Decant, error) {
+	if len(in.Decants) > MaxDecants {
+		return nil, ErrTooManyDecants
+	}
+
+	decants := make([]Decant, 0, len(in.Decants))
+	for _, d := range in.Decants {
+		if float64(d.SizeML) > in.volume() {
+			return nil, fmt.Errorf("%w: %d ml is more than the %.1f ml left", ErrInvalidDecant, d.SizeML, in.volume())
+		}
+		if slices.ContainsFunc(decants, func(existing Decant) bool { return existing.SizeML == d.SizeML }) {
+			return nil, fmt.Errorf("%w: %d ml is listed twice", ErrInvalidDecant, d.SizeML)
+		}
+		decants = append(decants, Decant{SizeML: d.SizeML, Price: d.Price})
+	}
+
+	slices.SortFunc(decants, func(a, b Decant) int { return a.SizeML - b.SizeML })
+	return decants, nil
+}
+
+func (in ListingInput) images() []string {
+	if in.Images == nil {
+		return []string{}
+	}
+	return in.Images
+}
+
+// Actor changing a listing, the seller, an admin or the system
+type Actor struct {
+	ID   string
+	Type auth.ActorType
+}
+
+// System actor for automatic transitions such as expiry
+var System = Actor{Type: auth.ActorTypeSystem}
+
+func ActorFrom(p auth.Principal) Actor {
+	return Actor{ID: p.ActorID(), Type: p.ActorType()}
+}
+
+func transitionError(from, to Status) error {
+	return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+}
@@ -0,0 +1,332 @@
+package listings
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"x/core/internal/catalog"
+	"x/core/internal/persist"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Preload the decants of listings, smallest first
+func WithDecants(db *gorm.DB) *gorm.DB {
+	return db.Preload("Decants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("size_ml")
+	})
+}
+
+func GetListing(db *gorm.DB, id string) (*Listing, error) {
+	return persist.GetRecordByID[Listing](WithDecants(db), id)
+}
+
+// Listing with its status history, oldest transition first
+func GetListingWithTransitions(db *gorm.DB, id string) (*Listing, error) {
+	var l Listing
+	err := WithDecants(db).Preload("Transitions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("id = ?", id).First(&l).Error
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Load a listing and lock its row until the transaction ends
+func Lock(tx *gorm.DB, id string) (*Listing, error) {
+	var l Listing
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&l).Error; err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Copy the catalog details of the fragrance onto the listing
+func (l *Listing) describe(f *catalog.Fragrance) {
+	var notes []string
+	for _, layer := range [][]catalog.Note{f.TopNotes, f.HeartNotes, f.BaseNotes} {
+		for _, n := range layer {
+			notes = append(notes, n.Name)
+		}
+	}
+
+	l.FragranceID = f.ID
+	l.Name = f.Name
+	l.Concentration = string(f.Concentration)
+	l.Notes = strings.Join(notes, ", ")
+	if f.Brand != nil {
+		l.Brand = f.Brand.Name
+	}
+}
+
+// Catalog details of the fragrance as listing columns, to refresh listings
+// after the fragrance changed
+func Details(f *catalog.Fragrance) map[string]interface{} {
+	var l Listing
+	l.describe(f)
+	return map[string]interface{}{
+		"name":          l.Name,
+		"brand":         l.Brand,
+		"concentration": l.Concentration,
+		"notes":         l.Notes,
+	}
+}
+
+func (in ListingInput) listing(f *catalog.Fragrance) (*Listing, error) {
+	if len(in.Images) > MaxImages {
+		return nil, ErrTooManyImages
+	}
+	decants, err := in.decants()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Listing{
+		Price:        in.Price,
+		Currency:     in.currency(),
+		PctRemaining: in.PctRemaining,
+		BottleSizeML: in.BottleSizeML,
+		BatchCode:    in.BatchCode,
+		Condition:    in.Condition,
+		Images:       in.images(),
+		Description:  in.Description,
+		Split:        len(decants) > 0,
+		VolumeML:     in.volume(),
+		Decants:      decants,
+	}
+	l.describe(f)
+	return l, nil
+}
+
+// Create a draft listing of the seller for a catalog fragrance
+func CreateListing(db *gorm.DB, sellerID string, in ListingInput, f *catalog.Fragrance) (*Listing, error) {
+	l, err := in.listing(f)
+	if err != nil {
+		return nil, err
+	}
+	l.SellerID = sellerID
+	l.Status = StatusDraft
+
+	if err := db.Create(l).Error; err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Replace the seller editable details of a locked listing
+func UpdateListing(tx *gorm.DB, l *Listing, version int64, in ListingInput, f *catalog.Fragrance) error {
+	if !l.Status.Editable() {
+		return fmt.Errorf("%w: listing is %s", ErrNotEditable, l.Status)
+	}
+
+	next, err := in.listing(f)
+	if err != nil {
+		return err
+	}
+	if l.AllocatedML > 0 {
+		if !next.Split {
+			return fmt.Errorf("%w: decants were already ordered", ErrNotEditable)
+		}
+		if next.VolumeML < l.AllocatedML {
+			return fmt.Errorf("%w: %.1f ml were already ordered", ErrNotEditable, l.AllocatedML)
+		}
+	}
+	images, err := json.Marshal(next.Images)
+	if err != nil {
+		return err
+	}
+
+	// Map updates so cleared fields are written too
+	err = persist.UpdateRecordByIDIfVersion[Listing](tx, l.ID, version, map[string]interface{}{
+		"fragrance_id":   next.FragranceID,
+		"name":           next.Name,
+		"brand":          next.Brand,
+		"concentration":  next.Concentration,
+		"notes":          next.Notes,
+		"price":          next.Price,
+		"currency":       next.Currency,
+		"pct_remaining":  next.PctRemaining,
+		"bottle_size_ml": next.BottleSizeML,
+		"batch_code":     next.BatchCode,
+		"condition":      next.Condition,
+		"images":         string(images),
+		"description":    next.Description,
+		"split":          next.Split,
+		"volume_ml":      next.VolumeML,
+	})
+	if err != nil {
+		return err
+	}
+
+	return replaceDecants(tx, l.ID, next.Decants)
+}
+
+func replaceDecants(tx *gorm.DB, listingID string, decants []Decant) error {
+	if err := tx.Where("listing_id = ?", listingID).Delete(&Decant{}).Error; err != nil {
+		return err
+	}
+	if len(decants) == 0 {
+		return nil
+	}
+	for i := range decants {
+		decants[i].ListingID = listingID
+	}
+	return tx.Create(&decants).Error
+}
+
+// Decant of a listing in the given vial size
+func GetDecant(db *gorm.DB, listingID string, sizeML int) (*Decant, error) {
+	var d Decant
+	if err := db.Where("listing_id = ? AND size_ml = ?", listingID, sizeML).First(&d).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Whether a split listing has too little volume left for its smallest decant
+func soldOut(tx *gorm.DB, l *Listing) (bool, error) {
+	var smallest int
+	err := tx.Model(&Decant{}).Where("listing_id = ?", l.ID).Select("COALESCE(MIN(size_ml), 0)").Scan(&smallest).Error
+	if err != nil {
+		return false, err
+	}
+	return smallest == 0 || l.AvailableML() < float64(smallest), nil
+}
+
+// Allocate volume of a locked split listing to a decant order. A listing
+// without enough volume left for another vial is reserved until the orders
+// holding the rest of its volume are settled.
+func Allocate(tx *gorm.DB, l *Listing, ml float64, actor Actor) error {
+	if !l.Split {
+		return ErrNotSplit
+	}
+	if l.Status != StatusActive {
+		return fmt.Errorf("%w: listing is %s", ErrOutOfStock, l.Status)
+	}
+	if ml > l.AvailableML() {
+		return fmt.Errorf("%w: %.1f ml left", ErrOutOfStock, l.AvailableML())
+	}
+
+	if err := setAllocated(tx, l, l.AllocatedML+ml); err != nil {
+		return err
+	}
+
+	out, err := soldOut(tx, l)
+	if err != nil || !out {
+		return err
+	}
+	return SetStatus(tx, l, StatusReserved, actor, "decants sold out")
+}
+
+// Return the volume of a canceled decant order to a locked split listing,
+// making it available again if it was sold out
+func Release(tx *gorm.DB, l *Listing, ml float64, actor Actor) error {
+	if !l.Split {
+		return ErrNotSplit
+	}
+	if err := setAllocated(tx, l, max(0, l.AllocatedML-ml)); err != nil {
+		return err
+	}
+
+	if l.Status != StatusReserved {
+		return nil
+	}
+	out, err := soldOut(tx, l)
+	if err != nil || out {
+		return err
+	}
+	return SetStatus(tx, l, StatusActive, actor, "decant order canceled")
+}
+
+func setAllocated(tx *gorm.DB, l *Listing, ml float64) error {
+	result := tx.Model(l).Update("allocated_ml", ml)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return persist.ErrConflict
+	}
+	l.AllocatedML = ml
+	bumpVersion(l)
+	return nil
+}
+
+// The optimistic lock increments the version in the database only, the
+// listing has to follow for later updates in the transaction to match
+func bumpVersion(l *Listing) {
+	l.SetVersion(l.GetVersion() + 1)
+}
+
+// Mark a sold out split listing sold, for when no decant orders holding its
+// volume are left unpaid
+func CloseSoldOut(tx *gorm.DB, l *Listing, actor Actor) error {
+	if !l.Split || l.Status != StatusReserved {
+		return nil
+	}
+	out, err := soldOut(tx, l)
+	if err != nil || !out {
+		return err
+	}
+	return SetStatus(tx, l, StatusSold, actor, "decants sold out")
+}
+
+// Move a locked listing to another status, recording the transition
+func SetStatus(tx *gorm.DB, l *Listing, to Status, actor Actor, reason string) error {
+	if !l.Status.CanTransition(to) {
+		return transitionError(l.Status, to)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": to}
+	if to == StatusActive && l.PublishedAt == nil {
+		updates["published_at"] = now
+	}
+	if to.Final() {
+		updates["closed_at"] = now
+	}
+
+	result := tx.Model(l).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return persist.ErrConflict
+	}
+
+	bumpVersion(l)
+	from := l.Status
+	l.Status = to
+	return tx.Create(&Transition{
+		ListingID: l.ID,
+		From:      from,
+		To:        to,
+		ActorID:   actor.ID,
+		ActorType: actor.Type,
+		Reason:    reason,
+	}).Error
+}
+
+// IDs of active listings published before the cutoff
+func StaleListingIDs(db *gorm.DB, publishedBefore time.Time, limit int) ([]string, error) {
+	var ids []string
+	err := db.Model(&Listing{}).
+		Where("status = ? AND published_at < ?", StatusActive, publishedBefore).
+		Order("published_at").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Active listings updated after the (updated_at, id) cursor and no later than
+// until, oldest first
+func UpdatedSince(db *gorm.DB, after time.Time, afterID string, until time.Time, limit int) ([]Listing, error) {
+	var records []Listing
+	err := db.Where("status = ? AND (updated_at, id) > (?, ?) AND updated_at <= ?", StatusActive, after, afterID, until).
+		Order("updated_at, id").
+		Limit(limit).
+		Find(&records).Error
+	return records, err
+}
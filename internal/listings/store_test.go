@@ -0,0 +1,129 @@
+package listings
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"x/core/internal/persist"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Transaction on the database of CORE_TEST_DATABASE_URL, rolled back once
+// the test is done. Tests are skipped without one.
+func testTx(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("CORE_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("CORE_TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("error connecting to test database: %v", err)
+	}
+	if err := db.AutoMigrate(&Listing{}, &Decant{}, &Transition{}); err != nil {
+		t.Fatalf("error migrating listings: %v", err)
+	}
+
+	tx := db.Begin()
+	t.Cleanup(func() {
+		tx.Rollback()
+	})
+	return tx
+}
+
+// Active split listing of 10 ml sold in 5 ml decants
+func createSplitListing(t *testing.T, tx *gorm.DB) *Listing {
+	t.Helper()
+	l := &Listing{
+		SellerID:     "seller",
+		FragranceID:  uuid.NewString(),
+		Status:       StatusActive,
+		Name:         "Test",
+		Brand:        "Test",
+		Price:        100,
+		PctRemaining: 100,
+		BottleSizeML: 10,
+		Condition:    ConditionGood,
+		Split:        true,
+		VolumeML:     10,
+		Decants:      []Decant{{SizeML: 5, Price: 40}},
+	}
+	if err := tx.Create(l).Error; err != nil {
+		t.Fatalf("error creating listing: %v", err)
+	}
+	return lockListing(t, tx, l.ID)
+}
+
+func lockListing(t *testing.T, tx *gorm.DB, id string) *Listing {
+	t.Helper()
+	l, err := Lock(tx, id)
+	if err != nil {
+		t.Fatalf("error locking listing: %v", err)
+	}
+	return l
+}
+
+func TestAllocateSellsOutAndReleaseRelists(t *testing.T) {
+	tx := testTx(t)
+	actor := System
+
+	l := createSplitListing(t, tx)
+	if err := Allocate(tx, l, 5, actor); err != nil {
+		t.Fatalf("error allocating the first decant: %v", err)
+	}
+	if l.Status != StatusActive {
+		t.Fatalf("status after the first decant = %s, want %s", l.Status, StatusActive)
+	}
+
+	// The last decant updates the volume and reserves the listing on the
+	// same listing value
+	if err := Allocate(tx, l, 5, actor); err != nil {
+		t.Fatalf("error allocating the last decant: %v", err)
+	}
+	stored := lockListing(t, tx, l.ID)
+	if stored.Status != StatusReserved || stored.AllocatedML != 10 {
+		t.Fatalf("sold out listing is %s with %.1f ml allocated, want %s with 10 ml", stored.Status, stored.AllocatedML, StatusReserved)
+	}
+	if stored.GetVersion() != l.GetVersion() {
+		t.Errorf("version = %d, stored version = %d", l.GetVersion(), stored.GetVersion())
+	}
+
+	// Canceling a decant order returns its volume and relists the listing
+	if err := Release(tx, stored, 5, actor); err != nil {
+		t.Fatalf("error releasing a decant: %v", err)
+	}
+	released := lockListing(t, tx, l.ID)
+	if released.Status != StatusActive || released.AllocatedML != 5 {
+		t.Fatalf("released listing is %s with %.1f ml allocated, want %s with 5 ml", released.Status, released.AllocatedML, StatusActive)
+	}
+
+	var transitions []Transition
+	if err := tx.Where("listing_id = ?", l.ID).Order("id").Find(&transitions).Error; err != nil {
+		t.Fatalf("error loading transitions: %v", err)
+	}
+	if len(transitions) != 2 || transitions[0].To != StatusReserved || transitions[1].To != StatusActive {
+		t.Errorf("transitions = %+v, want reserved then active", transitions)
+	}
+}
+
+func TestAllocateStaleListingConflicts(t *testing.T) {
+	tx := testTx(t)
+	actor := System
+
+	l := createSplitListing(t, tx)
+	stale := *l
+	if err := Allocate(tx, l, 5, actor); err != nil {
+		t.Fatalf("error allocating a decant: %v", err)
+	}
+
+	// A listing read before the allocation must not overwrite it
+	err := Allocate(tx, &stale, 5, actor)
+	if !errors.Is(err, persist.ErrConflict) {
+		t.Fatalf("allocating on a stale listing returned %v, want a conflict", err)
+	}
+}
@@ -0,0 +1,149 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"x/core/internal/events"
+	"x/core/internal/persist"
+)
+
+const (
+	// Most images a message can carry
+	MaxAttachments = 4
+)
+
+var (
+	ErrNotParticipant    = errors.New("conversation belongs to other users")
+	ErrOwnListing        = errors.New("can't message yourself about your own listing")
+	ErrBlocked           = errors.New("messaging between these users is blocked")
+	ErrEmptyMessage      = errors.New("message needs a body or an attachment")
+	ErrTooManyImages     = fmt.Errorf("a message can have at most %d attachments", MaxAttachments)
+	ErrInvalidAttachment = errors.New("attachment was not uploaded to this conversation")
+	ErrBlockSelf         = errors.New("can't block yourself")
+)
+
+const (
+	EventMessageCreated events.Type = "message.created"
+	EventMessageFlagged events.Type = "message.flagged"
+	EventRead           events.Type = "conversation.read"
+)
+
+const (
+	conversationResource = "conversation"
+	messageResource      = "message"
+)
+
+// Conversation between a buyer and the seller of a listing. Questions before
+// buying share one conversation per listing and buyer, each order has its own.
+type Conversation struct {
+	persist.Base
+	ListingID string  `gorm:"type:uuid;not null;index;uniqueIndex:idx_conversation_listing,where:order_id IS NULL AND deleted_at IS NULL" json:"listing_id"`
+	BuyerID   string  `gorm:"not null;index;uniqueIndex:idx_conversation_listing,where:order_id IS NULL AND deleted_at IS NULL" json:"buyer_id"`
+	OrderID   *string `gorm:"type:uuid;uniqueIndex:idx_conversation_order,where:deleted_at IS NULL" json:"order_id,omitempty"`
+	SellerID  string  `gorm:"not null;index" json:"seller_id"`
+
+	LastMessageAt *time.Time `gorm:"index" json:"last_message_at,omitempty"`
+	// Messages from the other participant the caller hasn't read, filled in
+	// when listing conversations
+	Unread int64 `gorm:"-:all" json:"unread"`
+}
+
+func (c *Conversation) IsParticipant(userID string) bool {
+	return userID != "" && (userID == c.BuyerID || userID == c.SellerID)
+}
+
+// The participant other than the user
+func (c *Conversation) Other(userID string) string {
+	if userID == c.BuyerID {
+		return c.SellerID
+	}
+	return c.BuyerID
+}
+
+// Image attached to a message, stored in Cloudinary
+type Attachment struct {
+	PublicID string `json:"public_id"`
+	URL      string `json:"url"`
+	Format   string `json:"format,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Bytes    int    `json:"bytes,omitempty"`
+}
+
+type Message struct {
+	persist.Base
+	ConversationID string       `gorm:"type:uuid;not null;index" json:"conversation_id"`
+	SenderID       string       `gorm:"not null" json:"sender_id"`
+	RecipientID    string       `gorm:"not null;index" json:"recipient_id"`
+	Body           string       `json:"body"`
+	Attachments    []Attachment `gorm:"serializer:json" json:"attachments"`
+	// Set once the recipient read the message
+	ReadAt *time.Time `json:"read_at,omitempty"`
+
+	// Messages matching moderation checks are delivered but flagged for review
+	Flagged     bool     `gorm:"not null;default:false;index" json:"-"`
+	FlagReasons []string `gorm:"serializer:json" json:"-"`
+}
+
+func (m *Message) Event(t events.Type) events.Event {
+	return events.New(t, messageResource, m.ID, m, m.RecipientID)
+}
+
+// Flagged message as seen by moderators, with the reasons it was flagged
+type FlaggedMessage struct {
+	Message
+	Flagged     bool     `json:"flagged"`
+	FlagReasons []string `json:"flag_reasons"`
+}
+
+// Event for moderators about a flagged message, addressed to no user
+func (m *FlaggedMessage) Event() events.Event {
+	return events.New(EventMessageFlagged, messageResource, m.ID, m)
+}
+
+// Receipt tells the sender their messages were read
+type Receipt struct {
+	ConversationID string    `json:"conversation_id"`
+	ReaderID       string    `json:"reader_id"`
+	ReadAt         time.Time `json:"read_at"`
+	Count          int64     `json:"count"`
+}
+
+func (r *Receipt) Event(senderID string) events.Event {
+	return events.New(EventRead, conversationResource, r.ConversationID, r, senderID)
+}
+
+// Block stops two users from messaging each other, whoever started it
+type Block struct {
+	persist.Base
+	BlockerID string `gorm:"not null;uniqueIndex:idx_block_pair,where:deleted_at IS NULL" json:"blocker_id"`
+	BlockedID string `gorm:"not null;index;uniqueIndex:idx_block_pair,where:deleted_at IS NULL" json:"blocked_id"`
+}
+
+// ConversationInput starts a conversation about a listing, or about an order
+// between its buyer and seller
+type ConversationInput struct {
+	ListingID string `json:"listing_id,omitempty" doc:"Listing to ask its seller about"`
+	OrderID   string `json:"order_id,omitempty" doc:"Order to message the other party about"`
+}
+
+type MessageInput struct {
+	Body        string   `json:"body,omitempty" validate:"max=4000"`
+	Attachments []string `json:"attachments,omitempty" doc:"Public ids of images uploaded with an upload ticket of the conversation"`
+}
+
+// UploadTicket signs a direct image upload to Cloudinary for a conversation
+type UploadTicket struct {
+	URL            string `json:"url"`
+	APIKey         string `json:"api_key"`
+	PublicID       string `json:"public_id"`
+	Timestamp      int64  `json:"timestamp"`
+	AllowedFormats string `json:"allowed_formats"`
+	Signature      string `json:"signature"`
+}
+
+// Folder images of a conversation are uploaded to
+func AttachmentFolder(conversationID string) string {
+	return "messages/" + conversationID
+}
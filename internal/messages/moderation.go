@@ -0,0 +1,67 @@
+package messages
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Check inspects a message body, returning why it should be flagged or an
+// empty string
+type Check func(body string) string
+
+// Wording steering buyers to pay outside the marketplace, losing escrow
+// protection
+var paymentPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(pay\s?pal|venmo|cash\s?app|zelle|revolut|western union|money\s?gram)\b`),
+	regexp.MustCompile(`\b(f\s?&\s?f|friends (and|&) family|goods (and|&) services)\b`),
+	regexp.MustCompile(`\b(bank|wire) transfer\b|\biban\b|\bsort code\b|\brouting number\b`),
+	regexp.MustCompile(`\b(bitcoin|btc|usdt|crypto)\b`),
+	regexp.MustCompile(`\b(pay|paying|payment|buy|deal)\b.{0,40}\b(off|outside)\s?(the\s)?(platform|site|app)\b`),
+}
+
+// Ways to take the conversation somewhere it can't be moderated
+var contactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(whats\s?app|telegram|wechat)\b`),
+	regexp.MustCompile(`[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}`),
+	regexp.MustCompile(`(\+?\d[\d\s().-]{8,}\d)`),
+}
+
+func matchAny(patterns []*regexp.Regexp, body string) bool {
+	body = strings.ToLower(body)
+	for _, p := range patterns {
+		if p.MatchString(body) {
+			return true
+		}
+	}
+	return false
+}
+
+// Flags asking to pay outside the marketplace
+func PaymentSolicitation(body string) string {
+	if matchAny(paymentPatterns, body) {
+		return "off_platform_payment"
+	}
+	return ""
+}
+
+// Flags sharing contact details to continue elsewhere
+func ContactSharing(body string) string {
+	if matchAny(contactPatterns, body) {
+		return "contact_details"
+	}
+	return ""
+}
+
+// Checks run on every message unless others are configured
+var DefaultChecks = []Check{PaymentSolicitation, ContactSharing}
+
+// Reasons the checks flag the body for
+func Moderate(checks []Check, body string) []string {
+	var reasons []string
+	for _, check := range checks {
+		if reason := check(body); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	return reasons
+}
@@ -0,0 +1,213 @@
+package messages
+
+import (
+	"errors"
+	"time"
+	"x/core/internal/persist"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Conversation the user takes part in
+func GetConversation(db *gorm.DB, userID, id string) (*Conversation, error) {
+	c, err := persist.GetRecordByID[Conversation](db, id)
+	if err != nil {
+		return nil, err
+	}
+	if !c.IsParticipant(userID) {
+		return nil, ErrNotParticipant
+	}
+	return c, nil
+}
+
+// Load a conversation and lock its row until the transaction ends
+func Lock(tx *gorm.DB, id string) (*Conversation, error) {
+	var c Conversation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Find the conversation matching c, creating it if there is none yet. Not
+// for use in a transaction, which a concurrent create would abort.
+func FindOrCreate(db *gorm.DB, c *Conversation) (*Conversation, error) {
+	find := func() (*Conversation, error) {
+		query := db.Where("listing_id = ? AND buyer_id = ? AND order_id IS NULL", c.ListingID, c.BuyerID)
+		if c.OrderID != nil {
+			query = db.Where("order_id = ?", *c.OrderID)
+		}
+		var existing Conversation
+		if err := query.First(&existing).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+
+	existing, err := find()
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return existing, err
+	}
+
+	if err := db.Create(c).Error; err != nil {
+		// Started concurrently by the other participant
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return find()
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// Conversations of a user, most recently active first, with their unread
+// message counts
+func ListConversations(db *gorm.DB, userID string, page, pageSize int) ([]Conversation, int, error) {
+	query := db.Model(&Conversation{}).Where("buyer_id = ? OR seller_id = ?", userID, userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+
+	var records []Conversation
+	err := query.Order("last_message_at DESC NULLS LAST, created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&records).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(records) == 0 {
+		return records, totalPages, nil
+	}
+
+	ids := make([]string, len(records))
+	for i := range records {
+		ids[i] = records[i].ID
+	}
+	var counts []struct {
+		ConversationID string
+		Unread         int64
+	}
+	err = db.Model(&Message{}).
+		Select("conversation_id, COUNT(*) AS unread").
+		Where("conversation_id IN ? AND recipient_id = ? AND read_at IS NULL", ids, userID).
+		Group("conversation_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, c := range counts {
+		for i := range records {
+			if records[i].ID == c.ConversationID {
+				records[i].Unread = c.Unread
+			}
+		}
+	}
+	return records, totalPages, nil
+}
+
+// Messages of a conversation, newest first, older than the message before
+// when it is set. Ids are time ordered so they double as the cursor.
+func ListMessages(db *gorm.DB, conversationID, before string, limit int) ([]Message, error) {
+	query := db.Where("conversation_id = ?", conversationID)
+	if before != "" {
+		query = query.Where("id < ?", before)
+	}
+
+	var records []Message
+	err := query.Order("id DESC").Limit(limit).Find(&records).Error
+	return records, err
+}
+
+// Add a message to a locked conversation
+func CreateMessage(tx *gorm.DB, c *Conversation, m *Message) error {
+	if err := tx.Create(m).Error; err != nil {
+		return err
+	}
+
+	result := tx.Model(c).Update("last_message_at", m.CreatedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return persist.ErrConflict
+	}
+	c.LastMessageAt = &m.CreatedAt
+	return nil
+}
+
+// Mark the messages the reader received in a conversation as read
+func MarkRead(db *gorm.DB, conversationID, readerID string, at time.Time) (int64, error) {
+	result := db.Model(&Message{}).
+		Where("conversation_id = ? AND recipient_id = ? AND read_at IS NULL", conversationID, readerID).
+		Update("read_at", at)
+	return result.RowsAffected, result.Error
+}
+
+// Flagged messages for moderators, newest first
+func ListFlagged(db *gorm.DB, page, pageSize int) ([]FlaggedMessage, int, error) {
+	records, totalPages, err := persist.GetFilteredPaginatedRecords[Message](db.Order("created_at DESC"), page, pageSize, map[string]interface{}{"flagged": true})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	flagged := make([]FlaggedMessage, len(records))
+	for i, m := range records {
+		flagged[i] = FlaggedMessage{Message: m, Flagged: m.Flagged, FlagReasons: m.FlagReasons}
+	}
+	return flagged, totalPages, nil
+}
+
+// Whether either user blocked the other
+func IsBlocked(db *gorm.DB, a, b string) (bool, error) {
+	var count int64
+	err := db.Model(&Block{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", a, b, b, a).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Users the user blocked, most recent first
+func ListBlocks(db *gorm.DB, userID string) ([]Block, error) {
+	var records []Block
+	err := db.Where("blocker_id = ?", userID).Order("created_at DESC").Find(&records).Error
+	return records, err
+}
+
+// Block a user, blocking them again is a no-op
+func CreateBlock(db *gorm.DB, blockerID, blockedID string) (*Block, error) {
+	if blockerID == blockedID {
+		return nil, ErrBlockSelf
+	}
+
+	var existing Block
+	err := db.Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	b := &Block{BlockerID: blockerID, BlockedID: blockedID}
+	if err := db.Create(b).Error; err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func DeleteBlock(db *gorm.DB, blockerID, blockedID string) error {
+	result := db.Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).Delete(&Block{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "core"
+
+var (
+	// Calls to deprecated routes, by route template, API version and client
+	DeprecatedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "deprecated_requests_total",
+		Help:      "Requests served by deprecated routes.",
+	}, []string{"route", "version", "client"})
+
+	// Panics recovered while serving requests, by route template
+	RecoveredPanics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "recovered_panics_total",
+		Help:      "Panics recovered while serving requests.",
+	}, []string{"route"})
+
+	// Open realtime connections, by transport
+	RealtimeConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "realtime",
+		Name:      "connections",
+		Help:      "Open realtime connections.",
+	}, []string{"transport"})
+
+	// Realtime connections closed by the server, by reason
+	RealtimeDisconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "realtime",
+		Name:      "disconnects_total",
+		Help:      "Realtime connections closed by the server.",
+	}, []string{"reason"})
+
+	// Watchlist and saved search alerts raised, by whether the user was
+	// notified or over their rate limit
+	Alerts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "watchlists",
+		Name:      "alerts_total",
+		Help:      "Alerts raised for watches and saved searches.",
+	}, []string{"outcome"})
+)
+
+// Handler exposing the metrics for scraping
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
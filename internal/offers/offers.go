@@ -0,0 +1,109 @@
+package offers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"x/core/internal/events"
+	"x/core/internal/persist"
+)
+
+const (
+	DefaultTTL = 48 * time.Hour
+	MaxTTL     = 7 * 24 * time.Hour
+)
+
+var (
+	ErrNotParty           = errors.New("offer belongs to other users")
+	ErrNotAllowed         = errors.New("offer can't be answered by its proposer")
+	ErrOfferClosed        = errors.New("offer is no longer open")
+	ErrOpenOffer          = errors.New("an open offer already exists for this listing")
+	ErrOwnListing         = errors.New("cannot make an offer on your own listing")
+	ErrListingUnavailable = errors.New("listing is not available for offers")
+)
+
+type Status string
+
+const (
+	// Waiting for an answer from the recipient
+	StatusPending   Status = "pending"
+	StatusCountered Status = "countered"
+	StatusAccepted  Status = "accepted"
+	StatusDeclined  Status = "declined"
+	StatusWithdrawn Status = "withdrawn"
+	StatusExpired   Status = "expired"
+)
+
+const (
+	EventCreated   events.Type = "offer.created"
+	EventCountered events.Type = "offer.countered"
+	EventAccepted  events.Type = "offer.accepted"
+	EventDeclined  events.Type = "offer.declined"
+	EventWithdrawn events.Type = "offer.withdrawn"
+	EventExpired   events.Type = "offer.expired"
+)
+
+const resourceType = "offer"
+
+// Offer is a price proposed for a listing by either the buyer or the seller.
+// A counter-offer answers the offer it counters with a new offer from the
+// other party, so the negotiation is the chain of offers linked by ParentID.
+type Offer struct {
+	persist.Base
+	ListingID  string  `gorm:"type:uuid;not null;index;uniqueIndex:idx_offer_open,where:status = 'pending'" json:"listing_id"`
+	BuyerID    string  `gorm:"not null;index;uniqueIndex:idx_offer_open" json:"buyer_id"`
+	SellerID   string  `gorm:"not null;index" json:"seller_id"`
+	ProposerID string  `gorm:"not null" json:"proposer_id"`
+	ParentID   *string `gorm:"type:uuid;index" json:"parent_id,omitempty"`
+
+	// Whole currency units, in the currency of the listing
+	Amount   int    `gorm:"not null" json:"amount"`
+	Currency string `gorm:"not null" json:"currency"`
+	Message  string `json:"message,omitempty"`
+
+	Status      Status     `gorm:"not null;index;default:pending" json:"status"`
+	ExpiresAt   time.Time  `gorm:"not null;index" json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+// Party of the negotiation expected to answer the offer
+func (o *Offer) RecipientID() string {
+	if o.ProposerID == o.BuyerID {
+		return o.SellerID
+	}
+	return o.BuyerID
+}
+
+func (o *Offer) IsParty(userID string) bool {
+	return userID != "" && (userID == o.BuyerID || userID == o.SellerID)
+}
+
+// Pending and not yet past its expiry
+func (o *Offer) Open(now time.Time) bool {
+	return o.Status == StatusPending && now.Before(o.ExpiresAt)
+}
+
+// Event about the offer addressed to the buyer and the seller
+func (o *Offer) Event(t events.Type) events.Event {
+	return events.New(t, resourceType, o.ID, o, o.BuyerID, o.SellerID)
+}
+
+// OfferInput proposes a price, for a new offer or a counter-offer
+type OfferInput struct {
+	Amount  int    `json:"amount" validate:"required,min=1,max=100000"`
+	Message string `json:"message,omitempty" validate:"max=1000"`
+	// Hours the offer stays open, 48 by default
+	ExpiresInHours int `json:"expires_in_hours,omitempty" validate:"min=1,max=168"`
+}
+
+func (in OfferInput) expiresAt(now time.Time) time.Time {
+	ttl := DefaultTTL
+	if in.ExpiresInHours > 0 {
+		ttl = min(time.Duration(in.ExpiresInHours)*time.Hour, MaxTTL)
+	}
+	return now.Add(ttl)
+}
+
+func closedError(o *Offer) error {
+	return fmt.Errorf("%w: offer is %s", ErrOfferClosed, o.Status)
+}
@@ -0,0 +1,219 @@
+package offers
+
+import (
+	"fmt"
+	"time"
+	"x/core/internal/listings"
+	"x/core/internal/persist"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func GetOffer(db *gorm.DB, id string) (*Offer, error) {
+	return persist.GetRecordByID[Offer](db, id)
+}
+
+// Load an offer and lock its row until the transaction ends
+func Lock(tx *gorm.DB, id string) (*Offer, error) {
+	var o Offer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&o).Error; err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Offers made on a listing, newest first. Buyers only see their own.
+func ListForListing(db *gorm.DB, listingID, buyerID string) ([]Offer, error) {
+	query := db.Where("listing_id = ?", listingID)
+	if buyerID != "" {
+		query = query.Where("buyer_id = ?", buyerID)
+	}
+
+	var records []Offer
+	if err := query.Order("created_at DESC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Open a negotiation on a locked listing with an offer from the buyer
+func Create(tx *gorm.DB, l *listings.Listing, buyerID string, in OfferInput) (*Offer, error) {
+	if l.SellerID == buyerID {
+		return nil, ErrOwnListing
+	}
+	if l.Status != listings.StatusActive {
+		return nil, ErrListingUnavailable
+	}
+	if l.Split {
+		return nil, fmt.Errorf("%w: listing is sold as decants", ErrListingUnavailable)
+	}
+
+	var open int64
+	err := tx.Model(&Offer{}).
+		Where("listing_id = ? AND buyer_id = ? AND status = ?", l.ID, buyerID, StatusPending).
+		Count(&open).Error
+	if err != nil {
+		return nil, err
+	}
+	if open > 0 {
+		return nil, ErrOpenOffer
+	}
+
+	o := &Offer{
+		ListingID:  l.ID,
+		BuyerID:    buyerID,
+		SellerID:   l.SellerID,
+		ProposerID: buyerID,
+		Amount:     in.Amount,
+		Currency:   l.Currency,
+		Message:    in.Message,
+		Status:     StatusPending,
+		ExpiresAt:  in.expiresAt(time.Now()),
+	}
+	if err := tx.Create(o).Error; err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Close a locked offer with the given status
+func setStatus(tx *gorm.DB, o *Offer, status Status) error {
+	now := time.Now()
+	result := tx.Model(o).Updates(map[string]interface{}{
+		"status":       status,
+		"responded_at": now,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return persist.ErrConflict
+	}
+
+	o.Status = status
+	o.RespondedAt = &now
+	return nil
+}
+
+// Recipient of a locked offer answering it
+func checkAnswer(o *Offer, userID string) error {
+	if !o.IsParty(userID) {
+		return ErrNotParty
+	}
+	if userID != o.RecipientID() {
+		return ErrNotAllowed
+	}
+	if !o.Open(time.Now()) {
+		return closedError(o)
+	}
+	return nil
+}
+
+// Answer a locked offer on a locked listing with a counter-offer from its
+// recipient
+func Counter(tx *gorm.DB, o *Offer, l *listings.Listing, userID string, in OfferInput) (*Offer, error) {
+	if err := checkAnswer(o, userID); err != nil {
+		return nil, err
+	}
+	if l.Status != listings.StatusActive {
+		return nil, ErrListingUnavailable
+	}
+	if err := setStatus(tx, o, StatusCountered); err != nil {
+		return nil, err
+	}
+
+	counter := &Offer{
+		ListingID:  o.ListingID,
+		BuyerID:    o.BuyerID,
+		SellerID:   o.SellerID,
+		ProposerID: userID,
+		ParentID:   &o.ID,
+		Amount:     in.Amount,
+		Currency:   o.Currency,
+		Message:    in.Message,
+		Status:     StatusPending,
+		ExpiresAt:  in.expiresAt(time.Now()),
+	}
+	if err := tx.Create(counter).Error; err != nil {
+		return nil, err
+	}
+	return counter, nil
+}
+
+// Decline a locked offer as its recipient
+func Decline(tx *gorm.DB, o *Offer, userID string) error {
+	if err := checkAnswer(o, userID); err != nil {
+		return err
+	}
+	return setStatus(tx, o, StatusDeclined)
+}
+
+// Withdraw a locked offer as its proposer
+func Withdraw(tx *gorm.DB, o *Offer, userID string) error {
+	if !o.IsParty(userID) {
+		return ErrNotParty
+	}
+	if userID != o.ProposerID {
+		return ErrNotAllowed
+	}
+	if o.Status != StatusPending {
+		return closedError(o)
+	}
+	return setStatus(tx, o, StatusWithdrawn)
+}
+
+// Accept a locked offer as its recipient. The locked listing is reserved for
+// the buyer and every other pending offer on it is declined, returning the
+// declined offers.
+func Accept(tx *gorm.DB, o *Offer, l *listings.Listing, actor listings.Actor) ([]Offer, error) {
+	if err := checkAnswer(o, actor.ID); err != nil {
+		return nil, err
+	}
+	if l.Status != listings.StatusActive {
+		return nil, ErrListingUnavailable
+	}
+
+	if err := setStatus(tx, o, StatusAccepted); err != nil {
+		return nil, err
+	}
+	if err := listings.SetStatus(tx, l, listings.StatusReserved, actor, "offer "+o.ID+" accepted"); err != nil {
+		return nil, err
+	}
+
+	var competing []Offer
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("listing_id = ? AND status = ? AND id <> ?", l.ID, StatusPending, o.ID).
+		Find(&competing).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range competing {
+		if err := setStatus(tx, &competing[i], StatusDeclined); err != nil {
+			return nil, err
+		}
+	}
+	return competing, nil
+}
+
+// Expire a locked offer past its expiry, reporting whether it was expired
+func Expire(tx *gorm.DB, o *Offer, now time.Time) (bool, error) {
+	if o.Status != StatusPending || now.Before(o.ExpiresAt) {
+		return false, nil
+	}
+	if err := setStatus(tx, o, StatusExpired); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// IDs of pending offers past their expiry
+func ExpiredOfferIDs(db *gorm.DB, now time.Time, limit int) ([]string, error) {
+	var ids []string
+	err := db.Model(&Offer{}).
+		Where("status = ? AND expires_at <= ?", StatusPending, now).
+		Order("expires_at").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,188 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	generator *generator
+	routes    map[string]*Operation
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type PathItem map[string]*Operation
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Operation describes a route. Request, Response and Query are example
+// values of Go types the schemas are derived from.
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+
+	Request  any  `json:"-"`
+	Response any  `json:"-"`
+	Query    any  `json:"-"`
+	Status   int  `json:"-"`
+	Private  bool `json:"-"`
+
+	// Schemas used to validate incoming requests
+	bodySchema *Schema
+}
+
+const bearerAuth = "bearerAuth"
+
+func New(title, version string) *Document {
+	d := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version},
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]*SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		routes: make(map[string]*Operation),
+	}
+	d.generator = newGenerator(d.Components.Schemas)
+	return d
+}
+
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Add an operation for a mux path template
+func (d *Document) AddOperation(method, template string, op Operation) {
+	method = strings.ToUpper(method)
+	path := pathParam.ReplaceAllString(template, "{$1}")
+
+	for _, m := range pathParam.FindAllStringSubmatch(template, -1) {
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: Types{"string"}},
+		})
+	}
+
+	if op.Query != nil {
+		op.Parameters = append(op.Parameters, d.generator.queryParameters(reflect.TypeOf(op.Query))...)
+	}
+
+	if op.Request != nil {
+		op.bodySchema = d.generator.schemaFor(reflect.TypeOf(op.Request))
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: op.bodySchema}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	op.Responses = map[string]*Response{
+		"default": {
+			Description: "Error",
+			Content:     map[string]*MediaType{"application/json": {Schema: d.generator.schemaFor(reflect.TypeOf(ErrorResponse{}))}},
+		},
+	}
+	ok := &Response{Description: http.StatusText(status)}
+	if op.Response != nil {
+		ok.Content = map[string]*MediaType{"application/json": {Schema: d.generator.schemaFor(reflect.TypeOf(op.Response))}}
+	}
+	op.Responses[statusKey(status)] = ok
+
+	if op.Private {
+		op.Security = []map[string][]string{{bearerAuth: {}}}
+	}
+
+	item, found := d.Paths[path]
+	if !found {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = &op
+	d.routes[method+" "+template] = &op
+}
+
+func statusKey(status int) string {
+	b, _ := json.Marshal(status)
+	return string(b)
+}
+
+// Operation registered for a method and mux path template
+func (d *Document) Operation(method, template string) (*Operation, bool) {
+	op, ok := d.routes[strings.ToUpper(method)+" "+template]
+	return op, ok
+}
+
+// Error body returned by every route on failure
+type ErrorResponse struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError is a validation failure of a single field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
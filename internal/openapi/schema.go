@@ -0,0 +1,328 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Types marshals as a single type or a list, e.g. ["string", "null"]
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+
+	// resolved component for $ref schemas, used by validation
+	resolved *Schema
+}
+
+var overrides = map[reflect.Type]*Schema{
+	reflect.TypeOf(time.Time{}):       {Type: Types{"string"}, Format: "date-time"},
+	reflect.TypeOf(json.RawMessage{}): {},
+}
+
+// Override the schema generated for a type, e.g. for types with custom JSON
+// marshalling
+func Override(t reflect.Type, s *Schema) {
+	overrides[t] = s
+}
+
+type generator struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newGenerator(components map[string]*Schema) *generator {
+	return &generator{
+		components: components,
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+var packagePath = regexp.MustCompile(`[\w.\-]+/`)
+
+// Component name for a named type, generic arguments are folded into the name
+func componentName(t reflect.Type) string {
+	name := packagePath.ReplaceAllString(t.Name(), "")
+
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if s, ok := overrides[t]; ok {
+		copied := *s
+		return &copied
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: Types{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: Types{"string"}, Format: "byte"}
+		}
+		return &Schema{Type: Types{"array"}, Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: Types{"object"}, AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return g.structRef(t)
+	default:
+		// interfaces and anything else accept any value
+		return &Schema{}
+	}
+}
+
+// Named structs are added to the components and referenced
+func (g *generator) structRef(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return g.structSchema(t)
+	}
+
+	name, ok := g.names[t]
+	if !ok {
+		name = componentName(t)
+		if _, taken := g.components[name]; taken {
+			// Same name in another package, qualify with the package name
+			pkg := []rune(path.Base(t.PkgPath()))
+			pkg[0] = unicode.ToUpper(pkg[0])
+			name = string(pkg) + name
+		}
+		g.names[t] = name
+
+		// Reserve before recursing so self references terminate
+		g.components[name] = &Schema{}
+		*g.components[name] = *g.structSchema(t)
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name, resolved: g.components[name]}
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: Types{"object"}, Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs are flattened like encoding/json does
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schemaFor(f.Type)
+		applyRules(prop, f.Tag.Get("validate"))
+		if desc := f.Tag.Get("doc"); desc != "" {
+			prop.Description = desc
+		}
+		s.Properties[name] = prop
+
+		// Nullable unless required, omitted fields are simply absent
+		if hasRule(f.Tag.Get("validate"), "required") {
+			s.Required = append(s.Required, name)
+		} else if f.Type.Kind() == reflect.Pointer && !strings.Contains(opts, "omitempty") && len(prop.Type) == 1 {
+			prop.Type = append(prop.Type, "null")
+		}
+	}
+}
+
+func hasRule(tag, rule string) bool {
+	for _, r := range strings.Split(tag, ",") {
+		if name, _, _ := strings.Cut(r, "="); name == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Map validate struct tag rules onto schema keywords. Rules that can't be
+// expressed on the schema's type panic, so a tag that wouldn't be enforced
+// fails when the routes are registered rather than being silently ignored.
+func applyRules(s *Schema, tag string) {
+	if tag == "" {
+		return
+	}
+
+	var kind string
+	if len(s.Type) > 0 {
+		kind = s.Type[0]
+	}
+	unsupported := func(rule string) {
+		panic(fmt.Sprintf("openapi: validate rule %q can't be applied to %s", rule, s.describe()))
+	}
+
+	for _, r := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(r, "=")
+		if name == "required" {
+			// Required strings can't be empty either
+			if kind == "string" && s.MinLength == nil {
+				one := 1
+				s.MinLength = &one
+			}
+			continue
+		}
+		if s.Ref != "" {
+			unsupported(r)
+		}
+
+		switch name {
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				unsupported(r)
+			}
+			switch {
+			case kind == "string" && name == "min":
+				v := int(n)
+				s.MinLength = &v
+			case kind == "string" && name == "max":
+				v := int(n)
+				s.MaxLength = &v
+			case kind != "integer" && kind != "number":
+				unsupported(r)
+			case name == "min":
+				s.Minimum = &n
+			default:
+				s.Maximum = &n
+			}
+		case "oneof":
+			for _, v := range strings.Fields(arg) {
+				switch kind {
+				case "string":
+					s.Enum = append(s.Enum, v)
+				case "integer", "number":
+					// JSON numbers are decoded as float64
+					n, err := strconv.ParseFloat(v, 64)
+					if err != nil || (kind == "integer" && n != math.Trunc(n)) {
+						unsupported(r)
+					}
+					s.Enum = append(s.Enum, n)
+				default:
+					unsupported(r)
+				}
+			}
+		case "email", "url":
+			if kind != "string" {
+				unsupported(r)
+			}
+			s.Format = formats[name]
+		default:
+			unsupported(r)
+		}
+	}
+}
+
+// Schema formats of the validate rules
+var formats = map[string]string{"email": "email", "url": "uri"}
+
+// Type or reference of the schema, for errors
+func (s *Schema) describe() string {
+	if s.Ref != "" {
+		return s.Ref
+	}
+	if len(s.Type) == 0 {
+		return "any value"
+	}
+	return strings.Join(s.Type, " or ")
+}
+
+// Query parameters from the schema tags of a struct
+func (g *generator) queryParameters(t reflect.Type) []*Parameter {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var params []*Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			params = append(params, g.queryParameters(f.Type)...)
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("schema"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		s := g.schemaFor(f.Type)
+		applyRules(s, f.Tag.Get("validate"))
+		params = append(params, &Parameter{
+			Name:        name,
+			In:          "query",
+			Description: f.Tag.Get("doc"),
+			Required:    hasRule(f.Tag.Get("validate"), "required"),
+			Schema:      s,
+		})
+	}
+	return params
+}
@@ -0,0 +1,229 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+func (s *Schema) allows(t string) bool {
+	return len(s.Type) == 0 || slices.Contains(s.Type, t)
+}
+
+// Validate a value decoded from JSON against the schema
+func (s *Schema) Validate(field string, value any) []FieldError {
+	if s.resolved != nil {
+		return s.resolved.Validate(field, value)
+	}
+
+	fail := func(format string, args ...any) []FieldError {
+		return []FieldError{{Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		if !s.allows("null") {
+			return fail("must not be null")
+		}
+	case bool:
+		if !s.allows("boolean") {
+			return fail("must be of type %s", s.Type[0])
+		}
+	case float64:
+		if !s.allows("number") && !(s.allows("integer") && v == math.Trunc(v)) {
+			return fail("must be of type %s", s.Type[0])
+		}
+		if s.Minimum != nil && v < *s.Minimum {
+			return fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			return fail("must be at most %v", *s.Maximum)
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, any(v)) {
+			return fail("must be one of %v", s.Enum)
+		}
+	case string:
+		if !s.allows("string") {
+			return fail("must be of type %s", s.Type[0])
+		}
+		if s.MinLength != nil && utf8.RuneCountInString(v) < *s.MinLength {
+			return fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && utf8.RuneCountInString(v) > *s.MaxLength {
+			return fail("must be at most %d characters", *s.MaxLength)
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, any(v)) {
+			return fail("must be one of %v", s.Enum)
+		}
+		if !validFormat(s.Format, v) {
+			return fail("must be a valid %s", s.Format)
+		}
+	case []any:
+		if !s.allows("array") {
+			return fail("must be of type %s", s.Type[0])
+		}
+		var errs []FieldError
+		if s.Items != nil {
+			for i, item := range v {
+				errs = append(errs, s.Items.Validate(fmt.Sprintf("%s[%d]", field, i), item)...)
+			}
+		}
+		return errs
+	case map[string]any:
+		if !s.allows("object") {
+			return fail("must be of type %s", s.Type[0])
+		}
+		var errs []FieldError
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, FieldError{Field: join(field, name), Message: "is required"})
+			}
+		}
+		for name, prop := range v {
+			if ps, ok := s.Properties[name]; ok {
+				errs = append(errs, ps.Validate(join(field, name), prop)...)
+			} else if as, ok := s.AdditionalProperties.(*Schema); ok {
+				errs = append(errs, as.Validate(join(field, name), prop)...)
+			}
+		}
+		return errs
+	}
+
+	return nil
+}
+
+// Whether the string is in the format, unknown formats like date-time are
+// left to the decoder
+func validFormat(format, v string) bool {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(v)
+		return err == nil && addr.Address == v
+	case "uri":
+		u, err := url.ParseRequestURI(v)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	}
+	return true
+}
+
+func join(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// Validate a single query parameter value
+func (p *Parameter) validate(raw string) []FieldError {
+	var value any = raw
+	switch {
+	case p.Schema.allows("string"):
+	case p.Schema.allows("integer"):
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return []FieldError{{Field: p.Name, Message: "must be an integer"}}
+		}
+		value = float64(n)
+	case p.Schema.allows("number"):
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return []FieldError{{Field: p.Name, Message: "must be a number"}}
+		}
+		value = n
+	case p.Schema.allows("boolean"):
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []FieldError{{Field: p.Name, Message: "must be a boolean"}}
+		}
+		value = b
+	}
+	return p.Schema.Validate(p.Name, value)
+}
+
+// Validate the query parameters and JSON body of a request against the
+// operation. The body is restored so handlers can still decode it.
+func (op *Operation) ValidateRequest(r *http.Request) ([]FieldError, error) {
+	errs := validateParams(op.Parameters, r.URL.Query())
+
+	if op.bodySchema != nil && r.Body != nil {
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		errs = append(errs, validateBody(op.bodySchema, body)...)
+	}
+
+	return errs, nil
+}
+
+func validateParams(params []*Parameter, values url.Values) []FieldError {
+	var errs []FieldError
+	for _, p := range params {
+		if p.In != "query" {
+			continue
+		}
+		raw := values.Get(p.Name)
+		if raw == "" {
+			if p.Required {
+				errs = append(errs, FieldError{Field: p.Name, Message: "is required"})
+			}
+			continue
+		}
+		if p.Schema.allows("array") && p.Schema.Items != nil {
+			// Repeated parameters, each validated as an item
+			for i, v := range values[p.Name] {
+				item := &Parameter{Name: fmt.Sprintf("%s[%d]", p.Name, i), Schema: p.Schema.Items}
+				errs = append(errs, item.validate(v)...)
+			}
+			continue
+		}
+		errs = append(errs, p.validate(raw)...)
+	}
+	return errs
+}
+
+func validateBody(s *Schema, body []byte) []FieldError {
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []FieldError{{Field: "body", Message: "must be valid JSON"}}
+	}
+	return s.Validate("", value)
+}
+
+// Schemas of types validated outside of a documented route, generated once
+var (
+	shared   = newGenerator(make(map[string]*Schema))
+	sharedMu sync.Mutex
+)
+
+// Validate a JSON body against the schema generated from a Go type
+func ValidateJSON(t reflect.Type, body []byte) []FieldError {
+	sharedMu.Lock()
+	s := shared.schemaFor(t)
+	sharedMu.Unlock()
+
+	return validateBody(s, body)
+}
+
+// Validate query or form values against the parameters generated from the
+// schema tags of a struct
+func ValidateValues(t reflect.Type, values url.Values) []FieldError {
+	sharedMu.Lock()
+	params := shared.queryParameters(t)
+	sharedMu.Unlock()
+
+	return validateParams(params, values)
+}
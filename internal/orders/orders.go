@@ -0,0 +1,200 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	"x/core/internal/events"
+	"x/core/internal/persist"
+)
+
+var (
+	ErrNotParty          = errors.New("order belongs to other users")
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	ErrNotPurchasable    = errors.New("listing can't be purchased")
+	ErrNoPayoutAccount   = errors.New("seller has no payout account")
+)
+
+type Status string
+
+const (
+	// Waiting for the buyer to authorize the payment
+	StatusPending Status = "pending"
+	// Funds are held on the buyer's payment method
+	StatusAuthorized Status = "authorized"
+	// Funds are held by the marketplace until the buyer confirms delivery
+	StatusCaptured Status = "captured"
+	// Funds were paid out to the seller
+	StatusReleased Status = "released"
+	StatusRefunded Status = "refunded"
+	StatusDisputed Status = "disputed"
+	StatusCanceled Status = "canceled"
+)
+
+// Statuses each status can move to, released, refunded and canceled are final
+var transitions = map[Status][]Status{
+	StatusPending:    {StatusAuthorized, StatusCaptured, StatusCanceled},
+	StatusAuthorized: {StatusCaptured, StatusCanceled},
+	StatusCaptured:   {StatusReleased, StatusRefunded, StatusDisputed},
+	StatusDisputed:   {StatusReleased, StatusRefunded},
+}
+
+func (s Status) CanTransition(to Status) bool {
+	return slices.Contains(transitions[s], to)
+}
+
+// Column recording when an order reached the status
+var statusTimestamps = map[Status]string{
+	StatusAuthorized: "authorized_at",
+	StatusCaptured:   "captured_at",
+	StatusReleased:   "released_at",
+	StatusRefunded:   "refunded_at",
+	StatusDisputed:   "disputed_at",
+	StatusCanceled:   "canceled_at",
+}
+
+const (
+	EventCreated    events.Type = "order.created"
+	EventAuthorized events.Type = "order.authorized"
+	EventCaptured   events.Type = "order.captured"
+	EventReleased   events.Type = "order.released"
+	EventRefunded   events.Type = "order.refunded"
+	EventDisputed   events.Type = "order.disputed"
+	EventCanceled   events.Type = "order.canceled"
+)
+
+var statusEvents = map[Status]events.Type{
+	StatusAuthorized: EventAuthorized,
+	StatusCaptured:   EventCaptured,
+	StatusReleased:   EventReleased,
+	StatusRefunded:   EventRefunded,
+	StatusDisputed:   EventDisputed,
+	StatusCanceled:   EventCanceled,
+}
+
+const resourceType = "order"
+
+// Order is the sale of a listing to a buyer, paid through the marketplace.
+// Amounts are in minor currency units.
+type Order struct {
+	persist.Base
+	// Only one bottle order per listing can be in progress or completed, split
+	// listings take decant orders until their volume runs out
+	ListingID string  `gorm:"type:uuid;not null;index;uniqueIndex:idx_order_listing,where:decant_size_ml = 0 AND status <> 'canceled' AND deleted_at IS NULL" json:"listing_id"`
+	OfferID   *string `gorm:"type:uuid;index" json:"offer_id,omitempty"`
+	BuyerID   string  `gorm:"not null;index" json:"buyer_id"`
+	SellerID  string  `gorm:"not null;index" json:"seller_id"`
+
+	// Address book entry of the buyer the order ships to
+	ShippingAddressID *string `gorm:"type:uuid" json:"shipping_address_id,omitempty"`
+
+	// Vials of a split listing ordered, zero for the whole bottle
+	DecantSizeML int `gorm:"not null;default:0" json:"decant_size_ml,omitempty"`
+	Quantity     int `gorm:"not null;default:1" json:"quantity"`
+
+	Currency     string `gorm:"not null" json:"currency"`
+	Subtotal     int64  `gorm:"not null" json:"subtotal"`
+	Fee          int64  `gorm:"not null" json:"fee"`
+	SellerPayout int64  `gorm:"not null" json:"seller_payout"`
+	Total        int64  `gorm:"not null" json:"total"`
+	// Shipping label bought by the seller, deducted from their payout
+	ShippingCost int64 `gorm:"not null;default:0" json:"shipping_cost"`
+
+	Status     Status  `gorm:"not null;index;default:pending" json:"status"`
+	Provider   string  `gorm:"not null" json:"provider"`
+	PaymentID  *string `gorm:"uniqueIndex" json:"payment_id,omitempty"`
+	TransferID string  `json:"transfer_id,omitempty"`
+
+	// Returned once when the order is placed, for the client to confirm the
+	// payment with
+	ClientSecret string `gorm:"-" json:"client_secret,omitempty"`
+
+	DisputeReason string     `json:"dispute_reason,omitempty"`
+	AuthorizedAt  *time.Time `json:"authorized_at,omitempty"`
+	CapturedAt    *time.Time `json:"captured_at,omitempty"`
+	ReleasedAt    *time.Time `json:"released_at,omitempty"`
+	RefundedAt    *time.Time `json:"refunded_at,omitempty"`
+	DisputedAt    *time.Time `json:"disputed_at,omitempty"`
+	CanceledAt    *time.Time `json:"canceled_at,omitempty"`
+}
+
+// Orders are financial records, soft deleted ones are never purged
+func (*Order) Retention() time.Duration {
+	return 0
+}
+
+// Volume of the vials ordered from a split listing
+func (o *Order) VolumeML() float64 {
+	return float64(o.DecantSizeML * o.Quantity)
+}
+
+func (o *Order) IsParty(userID string) bool {
+	return userID != "" && (userID == o.BuyerID || userID == o.SellerID)
+}
+
+// Event about the order addressed to the buyer and the seller
+func (o *Order) Event(t events.Type) events.Event {
+	return events.New(t, resourceType, o.ID, o, o.BuyerID, o.SellerID)
+}
+
+// Event of the order reaching its current status
+func (o *Order) StatusEvent() (events.Event, bool) {
+	t, ok := statusEvents[o.Status]
+	if !ok {
+		return events.Event{}, false
+	}
+	return o.Event(t), true
+}
+
+// PayoutAccount is the account of a seller at the payment provider that
+// released funds are paid out to
+type PayoutAccount struct {
+	persist.Base
+	UserID    string `gorm:"not null;uniqueIndex:idx_payout_account_user,where:deleted_at IS NULL" json:"user_id"`
+	Provider  string `gorm:"not null" json:"provider"`
+	AccountID string `gorm:"not null" json:"account_id" audit:"redact"`
+}
+
+// PaymentEvent records a processed provider webhook event so redeliveries
+// are ignored
+type PaymentEvent struct {
+	Provider   string    `gorm:"primaryKey" json:"provider"`
+	ID         string    `gorm:"primaryKey" json:"id"`
+	Type       string    `json:"type"`
+	PaymentID  string    `gorm:"index" json:"payment_id"`
+	ReceivedAt time.Time `gorm:"autoCreateTime" json:"received_at"`
+}
+
+// FeePolicy is the commission the marketplace keeps from every sale
+type FeePolicy struct {
+	// Basis points of the subtotal, 1000 is 10%
+	RateBPS int64
+	// Minor currency units added to every order
+	Fixed int64
+}
+
+// Commission on a subtotal, never more than the subtotal itself
+func (p FeePolicy) Fee(subtotal int64) int64 {
+	fee := subtotal*p.RateBPS/10000 + p.Fixed
+	return max(0, min(fee, subtotal))
+}
+
+// Listing prices and offers are in whole units of currencies with cents
+func MinorUnits(amount int) int64 {
+	return int64(amount) * 100
+}
+
+// OrderInput places an order for an accepted offer, at the listing price or
+// for decants of a split listing
+type OrderInput struct {
+	OfferID      string `json:"offer_id,omitempty" doc:"Accepted offer to pay for"`
+	ListingID    string `json:"listing_id,omitempty" doc:"Listing to buy at its price"`
+	DecantSizeML int    `json:"decant_size_ml,omitempty" validate:"min=1,max=30" doc:"Vial size to order from a split listing"`
+	Quantity     int    `json:"quantity,omitempty" validate:"min=1,max=20" doc:"Vials to order, 1 by default"`
+	AddressID    string `json:"address_id,omitempty" doc:"Address to ship to, the buyer's default address by default"`
+}
+
+func transitionError(from, to Status) error {
+	return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+}
@@ -0,0 +1,156 @@
+package orders
+
+import (
+	"time"
+	"x/core/internal/payments"
+	"x/core/internal/persist"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func GetOrder(db *gorm.DB, id string) (*Order, error) {
+	return persist.GetRecordByID[Order](db, id)
+}
+
+// Load an order and lock its row until the transaction ends
+func Lock(tx *gorm.DB, id string) (*Order, error) {
+	var o Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&o).Error; err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Lock the order paid by a provider payment
+func LockByPayment(tx *gorm.DB, provider, paymentID string) (*Order, error) {
+	var o Order
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("provider = ? AND payment_id = ?", provider, paymentID).
+		First(&o).Error
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Create a pending order, with the fee of the policy deducted from the
+// seller's payout
+func Create(tx *gorm.DB, o *Order, fees FeePolicy) error {
+	o.Status = StatusPending
+	o.Fee = fees.Fee(o.Subtotal)
+	o.SellerPayout = o.Subtotal - o.Fee
+	o.Total = o.Subtotal
+	return tx.Create(o).Error
+}
+
+// Count the decant orders of a listing still waiting for payment
+func CountUnpaidDecants(tx *gorm.DB, listingID string) (int64, error) {
+	var count int64
+	err := tx.Model(&Order{}).
+		Where("listing_id = ? AND decant_size_ml > 0 AND status IN ?", listingID, []Status{StatusPending, StatusAuthorized}).
+		Count(&count).Error
+	return count, err
+}
+
+// Attach the provider payment to a pending order
+func SetPayment(tx *gorm.DB, o *Order, paymentID string) error {
+	o.PaymentID = &paymentID
+	return tx.Model(o).Update("payment_id", paymentID).Error
+}
+
+// Deduct the shipping label bought for a locked order from the seller's
+// payout
+func ChargeShipping(tx *gorm.DB, o *Order, cost int64) error {
+	result := tx.Model(o).Updates(map[string]interface{}{
+		"shipping_cost": o.ShippingCost + cost,
+		"seller_payout": o.SellerPayout - cost,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return persist.ErrConflict
+	}
+
+	o.ShippingCost += cost
+	o.SellerPayout -= cost
+	return nil
+}
+
+// Move a locked order to another status, along with any other column updates
+func SetStatus(tx *gorm.DB, o *Order, to Status, updates map[string]interface{}) error {
+	if !o.Status.CanTransition(to) {
+		return transitionError(o.Status, to)
+	}
+
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = to
+	if column, ok := statusTimestamps[to]; ok {
+		updates[column] = time.Now()
+	}
+
+	result := tx.Model(o).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return persist.ErrConflict
+	}
+
+	o.Status = to
+	return nil
+}
+
+// Record a webhook event, reporting false if it was already processed
+func RecordEvent(tx *gorm.DB, provider string, e *payments.Event) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&PaymentEvent{
+		Provider:  provider,
+		ID:        e.ID,
+		Type:      e.Type,
+		PaymentID: e.PaymentID,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func GetPayoutAccount(db *gorm.DB, userID string) (*PayoutAccount, error) {
+	account, err := persist.GetRecordByField[PayoutAccount](db, "user_id", userID)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return account, nil
+}
+
+// Create or replace the payout account of a user
+func SavePayoutAccount(db *gorm.DB, userID, provider, accountID string) (*PayoutAccount, error) {
+	account := &PayoutAccount{UserID: userID, Provider: provider, AccountID: accountID}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		existing, err := GetPayoutAccount(tx, userID)
+		if err == gorm.ErrRecordNotFound {
+			return tx.Create(account).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		account = existing
+		account.Provider = provider
+		account.AccountID = accountID
+		return tx.Model(account).Updates(map[string]interface{}{
+			"provider":   provider,
+			"account_id": accountID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
@@ -0,0 +1,106 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Fake is an in-process provider for local development and tests. Payments
+// are authorized as soon as they are created and webhook events are plain,
+// unsigned JSON encoded Events.
+type Fake struct {
+	mu       sync.Mutex
+	payments map[string]*Payment
+	refs     map[string]string
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		payments: make(map[string]*Payment),
+		refs:     make(map[string]string),
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) CreatePayment(ctx context.Context, intent Intent) (*Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id, ok := f.refs[intent.Reference]; ok {
+		p := *f.payments[id]
+		return &p, nil
+	}
+
+	p := &Payment{
+		ID:           "fake_pay_" + uuid.NewString(),
+		Status:       StatusAuthorized,
+		ClientSecret: "fake_secret_" + uuid.NewString(),
+	}
+	f.payments[p.ID] = p
+	f.refs[intent.Reference] = p.ID
+
+	created := *p
+	return &created, nil
+}
+
+// Move a payment between statuses, repeating a transition is a no-op
+func (f *Fake) transition(paymentID string, to Status, from ...Status) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return fmt.Errorf("fake payment %s not found", paymentID)
+	}
+	if p.Status == to {
+		return nil
+	}
+	for _, status := range from {
+		if p.Status == status {
+			p.Status = to
+			return nil
+		}
+	}
+	return fmt.Errorf("fake payment %s is %s", paymentID, p.Status)
+}
+
+func (f *Fake) Capture(ctx context.Context, paymentID string) error {
+	return f.transition(paymentID, StatusCaptured, StatusAuthorized)
+}
+
+func (f *Fake) Cancel(ctx context.Context, paymentID string) error {
+	return f.transition(paymentID, StatusCanceled, StatusRequiresAction, StatusAuthorized)
+}
+
+func (f *Fake) Refund(ctx context.Context, paymentID string, reference string) error {
+	return f.transition(paymentID, StatusRefunded, StatusCaptured, StatusDisputed)
+}
+
+func (f *Fake) Release(ctx context.Context, payout Payout) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.payments[payout.PaymentID]; !ok {
+		return "", fmt.Errorf("fake payment %s not found", payout.PaymentID)
+	}
+	return "fake_tr_" + payout.Reference, nil
+}
+
+func (f *Fake) ParseEvent(payload []byte, header http.Header) (*Event, error) {
+	var e Event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, fmt.Errorf("error parsing fake payment event: %v", err)
+	}
+	if e.ID == "" || e.PaymentID == "" || e.Status == "" {
+		return nil, ErrUnknownEvent
+	}
+	return &e, nil
+}
@@ -0,0 +1,77 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrUnknownEvent     = errors.New("unhandled payment event")
+)
+
+// Status of a payment at the provider
+type Status string
+
+const (
+	// Waiting for the buyer to confirm the payment method
+	StatusRequiresAction Status = "requires_action"
+	// Funds are held on the buyer's payment method
+	StatusAuthorized Status = "authorized"
+	StatusCaptured   Status = "captured"
+	StatusCanceled   Status = "canceled"
+	StatusRefunded   Status = "refunded"
+	StatusDisputed   Status = "disputed"
+)
+
+// Intent to take a payment, authorized first and captured later
+type Intent struct {
+	// Minor currency units, e.g. cents
+	Amount   int64
+	Currency string
+	// Reference of the payment on our side, used as idempotency key
+	Reference   string
+	Description string
+}
+
+// Payment created at the provider
+type Payment struct {
+	ID     string
+	Status Status
+	// Secret the client confirms the payment with, not stored
+	ClientSecret string
+}
+
+// Payout releasing held funds to a seller's account at the provider
+type Payout struct {
+	PaymentID   string
+	Destination string
+	Amount      int64
+	Currency    string
+	Reference   string
+}
+
+// Event is a payment status change reported by the provider's webhooks
+type Event struct {
+	// Provider event ID, events are only processed once
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	PaymentID string `json:"payment_id"`
+	Status    Status `json:"status"`
+}
+
+// Provider takes, holds and releases payments. Implementations must be safe
+// to retry with the same reference.
+type Provider interface {
+	Name() string
+	CreatePayment(ctx context.Context, intent Intent) (*Payment, error)
+	Capture(ctx context.Context, paymentID string) error
+	Cancel(ctx context.Context, paymentID string) error
+	// Refund the full amount of a captured payment
+	Refund(ctx context.Context, paymentID string, reference string) error
+	Release(ctx context.Context, payout Payout) (string, error)
+	// Verify and parse a webhook request body, returning ErrUnknownEvent for
+	// events without a payment status change
+	ParseEvent(payload []byte, header http.Header) (*Event, error)
+}
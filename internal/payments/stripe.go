@@ -0,0 +1,240 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultStripeURL = "https://api.stripe.com/v1"
+
+	stripeSignatureHeader = "Stripe-Signature"
+	stripeSignatureMaxAge = 5 * time.Minute
+	stripeRequestTimeout  = 15 * time.Second
+)
+
+// Stripe is a provider for the Stripe API and compatible services. Payments
+// are created with manual capture so funds are held until captured, and
+// released to sellers through transfers to their connected accounts.
+type Stripe struct {
+	baseURL       string
+	secretKey     string
+	webhookSecret string
+	client        *http.Client
+}
+
+func NewStripe(baseURL, secretKey, webhookSecret string) *Stripe {
+	if baseURL == "" {
+		baseURL = DefaultStripeURL
+	}
+	return &Stripe{
+		baseURL:       strings.TrimSuffix(baseURL, "/"),
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		client:        &http.Client{Timeout: stripeRequestTimeout},
+	}
+}
+
+func (s *Stripe) Name() string {
+	return "stripe"
+}
+
+type stripeError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// POST a form to the API, decoding the response into v. Requests with the
+// same idempotency key are only executed once by the API.
+func (s *Stripe) post(ctx context.Context, path, idempotencyKey string, form url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.secretKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling stripe %s: %v", path, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("error reading stripe %s response: %v", path, err)
+	}
+	if res.StatusCode >= 300 {
+		var e stripeError
+		if err := json.Unmarshal(body, &e); err != nil || e.Error.Message == "" {
+			return fmt.Errorf("stripe %s failed with status %d", path, res.StatusCode)
+		}
+		return fmt.Errorf("stripe %s failed: %s (%s)", path, e.Error.Message, e.Error.Type)
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+type stripePaymentIntent struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	ClientSecret string `json:"client_secret"`
+}
+
+func stripeStatus(status string) Status {
+	switch status {
+	case "requires_capture":
+		return StatusAuthorized
+	case "succeeded":
+		return StatusCaptured
+	case "canceled":
+		return StatusCanceled
+	default:
+		return StatusRequiresAction
+	}
+}
+
+func (s *Stripe) CreatePayment(ctx context.Context, intent Intent) (*Payment, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(intent.Amount, 10))
+	form.Set("currency", strings.ToLower(intent.Currency))
+	form.Set("capture_method", "manual")
+	form.Set("description", intent.Description)
+	form.Set("metadata[reference]", intent.Reference)
+
+	var pi stripePaymentIntent
+	if err := s.post(ctx, "/payment_intents", "create-"+intent.Reference, form, &pi); err != nil {
+		return nil, err
+	}
+	return &Payment{ID: pi.ID, Status: stripeStatus(pi.Status), ClientSecret: pi.ClientSecret}, nil
+}
+
+func (s *Stripe) Capture(ctx context.Context, paymentID string) error {
+	return s.post(ctx, "/payment_intents/"+url.PathEscape(paymentID)+"/capture", "capture-"+paymentID, url.Values{}, nil)
+}
+
+func (s *Stripe) Cancel(ctx context.Context, paymentID string) error {
+	return s.post(ctx, "/payment_intents/"+url.PathEscape(paymentID)+"/cancel", "cancel-"+paymentID, url.Values{}, nil)
+}
+
+func (s *Stripe) Refund(ctx context.Context, paymentID string, reference string) error {
+	form := url.Values{}
+	form.Set("payment_intent", paymentID)
+	form.Set("metadata[reference]", reference)
+	return s.post(ctx, "/refunds", "refund-"+reference, form, nil)
+}
+
+func (s *Stripe) Release(ctx context.Context, payout Payout) (string, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(payout.Amount, 10))
+	form.Set("currency", strings.ToLower(payout.Currency))
+	form.Set("destination", payout.Destination)
+	form.Set("transfer_group", payout.Reference)
+	form.Set("metadata[payment_intent]", payout.PaymentID)
+
+	var transfer struct {
+		ID string `json:"id"`
+	}
+	if err := s.post(ctx, "/transfers", "release-"+payout.Reference, form, &transfer); err != nil {
+		return "", err
+	}
+	return transfer.ID, nil
+}
+
+// Verify the Stripe-Signature header, an HMAC of the timestamp and payload
+// signed with the endpoint secret
+func (s *Stripe) verify(payload []byte, header string) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(ts, 0)) > stripeSignatureMaxAge {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.webhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	for _, sig := range signatures {
+		decoded, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object struct {
+			ID            string `json:"id"`
+			PaymentIntent string `json:"payment_intent"`
+		} `json:"object"`
+	} `json:"data"`
+}
+
+func (s *Stripe) ParseEvent(payload []byte, header http.Header) (*Event, error) {
+	if err := s.verify(payload, header.Get(stripeSignatureHeader)); err != nil {
+		return nil, err
+	}
+
+	var e stripeEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, fmt.Errorf("error parsing stripe event: %v", err)
+	}
+
+	event := &Event{ID: e.ID, Type: e.Type, PaymentID: e.Data.Object.ID}
+	switch e.Type {
+	case "payment_intent.amount_capturable_updated":
+		event.Status = StatusAuthorized
+	case "payment_intent.succeeded":
+		event.Status = StatusCaptured
+	case "payment_intent.canceled":
+		event.Status = StatusCanceled
+	case "payment_intent.payment_failed":
+		// A failed attempt leaves the intent open for the buyer to retry,
+		// only payment_intent.canceled ends it
+		event.Status = StatusRequiresAction
+	case "charge.refunded":
+		event.Status = StatusRefunded
+		event.PaymentID = e.Data.Object.PaymentIntent
+	case "charge.dispute.created":
+		event.Status = StatusDisputed
+		event.PaymentID = e.Data.Object.PaymentIntent
+	default:
+		return nil, ErrUnknownEvent
+	}
+	return event, nil
+}
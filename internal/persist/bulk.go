@@ -0,0 +1,306 @@
+package persist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// RowError is the failure of a single row in a bulk operation
+type RowError struct {
+	Index int    `json:"index"`
+	ID    string `json:"id,omitempty"`
+	Err   error  `json:"-"`
+}
+
+func (e RowError) Error() string {
+	if e.ID != "" {
+		return fmt.Sprintf("row %d (%s): %v", e.Index, e.ID, e.Err)
+	}
+	return fmt.Sprintf("row %d: %v", e.Index, e.Err)
+}
+
+func (e RowError) MarshalJSON() ([]byte, error) {
+	type alias RowError
+	return json.Marshal(struct {
+		alias
+		Error string `json:"error"`
+	}{alias(e), e.Err.Error()})
+}
+
+type BulkResult struct {
+	Affected int64      `json:"affected"`
+	Errors   []RowError `json:"errors,omitempty"`
+}
+
+// Insert records, updating the given columns of rows that conflict on the
+// conflict columns. All columns are updated when no update columns are given.
+// If the batch fails each row is retried on its own so the caller gets the
+// rows that succeeded and an error per failed row. The batch runs under a
+// savepoint so a failure doesn't abort a transaction db is already in.
+func Upsert[T any](db *gorm.DB, records []T, conflictColumns []string, updateColumns []string) (BulkResult, error) {
+	var result BulkResult
+	if len(records) == 0 {
+		return result, nil
+	}
+
+	onConflict := clause.OnConflict{UpdateAll: true}
+	for _, c := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: c})
+	}
+	if len(updateColumns) > 0 {
+		onConflict = clause.OnConflict{
+			Columns:   onConflict.Columns,
+			DoUpdates: clause.AssignmentColumns(updateColumns),
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.SavePoint("upsert_batch").Error; err != nil {
+			return err
+		}
+		batch := tx.Clauses(onConflict).Create(&records)
+		if batch.Error == nil {
+			result.Affected = batch.RowsAffected
+			return nil
+		}
+		if err := tx.RollbackTo("upsert_batch").Error; err != nil {
+			return err
+		}
+
+		// Retry row by row, rolling back to a savepoint on each failure
+		for i := range records {
+			if err := tx.SavePoint("upsert_row").Error; err != nil {
+				return err
+			}
+
+			row := tx.Clauses(onConflict).Create(&records[i])
+			if row.Error != nil {
+				if err := tx.RollbackTo("upsert_row").Error; err != nil {
+					return err
+				}
+				result.Errors = append(result.Errors, RowError{Index: i, Err: row.Error})
+				continue
+			}
+			result.Affected += row.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return BulkResult{}, err
+	}
+
+	return result, nil
+}
+
+// IDs from the list that have no matching record, reported as row errors
+func missingIDs[T any](db *gorm.DB, ids []string) ([]RowError, error) {
+	var found []string
+	if err := db.Model(new(T)).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+
+	var errs []RowError
+	for i, id := range ids {
+		if !exists[id] {
+			errs = append(errs, RowError{Index: i, ID: id, Err: gorm.ErrRecordNotFound})
+		}
+	}
+	return errs, nil
+}
+
+// Apply the same updates to every record with the given IDs
+func BulkUpdate[T any, U any](db *gorm.DB, ids []string, updates U) (BulkResult, error) {
+	var result BulkResult
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		errs, err := missingIDs[T](tx, ids)
+		if err != nil {
+			return err
+		}
+		result.Errors = errs
+
+		update := tx.Model(new(T)).Where("id IN ?", ids).Updates(updates)
+		if update.Error != nil {
+			return update.Error
+		}
+		result.Affected = update.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return BulkResult{}, err
+	}
+
+	return result, nil
+}
+
+// Delete every record with the given IDs, soft deleting models that embed Base
+func BulkDelete[T any](db *gorm.DB, ids []string) (BulkResult, error) {
+	var result BulkResult
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		errs, err := missingIDs[T](tx, ids)
+		if err != nil {
+			return err
+		}
+		result.Errors = errs
+
+		del := tx.Where("id IN ?", ids).Delete(new(T))
+		if del.Error != nil {
+			return del.Error
+		}
+		result.Affected = del.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return BulkResult{}, err
+	}
+
+	return result, nil
+}
+
+// Create callbacks that still apply to rows loaded with COPY, run with the
+// copied records as the statement's model
+var copyCallbacks = []string{"audit:after_create", "cache:invalidate_create"}
+
+// Load records with postgres COPY for large imports. COPY is all or nothing
+// and bypasses gorm callbacks, so BeforeCreate hooks are run and IDs,
+// timestamps and versions filled in here, then written back into records.
+// It runs on the connection of the transaction db is in, or of its own, and
+// the records are audited and their cached queries invalidated like created
+// ones.
+func CopyFrom[T any](ctx context.Context, db *gorm.DB, records []T) (int64, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	var copied int64
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		t, ok := tx.Statement.ConnPool.(*Tx)
+		if !ok {
+			return fmt.Errorf("copy requires a transaction begun by persist.Pool, got %T", tx.Statement.ConnPool)
+		}
+
+		stmt := tx.Statement
+		if err := stmt.Parse(&records); err != nil {
+			return fmt.Errorf("error parsing model: %v", err)
+		}
+
+		var columns []string
+		for _, f := range stmt.Schema.Fields {
+			if f.DBName != "" && f.Creatable {
+				columns = append(columns, f.DBName)
+			}
+		}
+
+		// Model hooks fill in the ID and the principal of Base models
+		for i := range records {
+			if h, ok := any(&records[i]).(interface{ BeforeCreate(*gorm.DB) error }); ok {
+				if err := h.BeforeCreate(tx); err != nil {
+					return err
+				}
+			}
+		}
+
+		rows, err := copyRows(ctx, stmt.Schema.Fields, records)
+		if err != nil {
+			return err
+		}
+
+		err = t.conn.Raw(func(driverConn any) error {
+			c, ok := driverConn.(*stdlib.Conn)
+			if !ok {
+				return fmt.Errorf("copy requires a pgx connection, got %T", driverConn)
+			}
+
+			copied, err = c.Conn().CopyFrom(ctx, pgx.Identifier{stmt.Schema.Table}, columns, pgx.CopyFromRows(rows))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error copying into %s: %v", stmt.Schema.Table, err)
+		}
+
+		stmt.Dest, stmt.Model = &records, &records
+		stmt.ReflectValue = reflect.ValueOf(records)
+		stmt.RowsAffected = copied
+		for _, name := range copyCallbacks {
+			if fn := tx.Callback().Create().Get(name); fn != nil {
+				fn(tx)
+			}
+		}
+		return tx.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return copied, nil
+}
+
+// Values of the creatable columns of every record, generating the values
+// gorm would and setting them on the records
+func copyRows[T any](ctx context.Context, fields []*schema.Field, records []T) ([][]interface{}, error) {
+	now := time.Now()
+	rows := make([][]interface{}, 0, len(records))
+	for i := range records {
+		rv := reflect.ValueOf(&records[i]).Elem()
+
+		if v, ok := any(&records[i]).(Versioned); ok && v.GetVersion() == 0 {
+			v.SetVersion(1)
+		}
+
+		row := make([]interface{}, 0, len(fields))
+		for _, f := range fields {
+			if f.DBName == "" || !f.Creatable {
+				continue
+			}
+
+			value, zero := f.ValueOf(ctx, rv)
+			switch {
+			case zero && f.PrimaryKey && f.FieldType.Kind() == reflect.String:
+				id, err := uuid.NewV7()
+				if err != nil {
+					return nil, fmt.Errorf("error generating id: %v", err)
+				}
+				value = id.String()
+				if err := f.Set(ctx, rv, value); err != nil {
+					return nil, err
+				}
+			case zero && (f.AutoCreateTime > 0 || f.AutoUpdateTime > 0):
+				value = now
+				if err := f.Set(ctx, rv, value); err != nil {
+					return nil, err
+				}
+			case f.Serializer != nil:
+				b, err := json.Marshal(value)
+				if err != nil {
+					return nil, fmt.Errorf("error serializing %s: %v", f.DBName, err)
+				}
+				value = string(b)
+			}
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,80 @@
+package persist
+
+import (
+	"context"
+	"x/core/internal/cache"
+
+	"gorm.io/gorm"
+)
+
+type pageQuery struct {
+	Op         string                 `json:"op"`
+	ID         string                 `json:"id,omitempty"`
+	Page       int                    `json:"page,omitempty"`
+	PageSize   int                    `json:"page_size,omitempty"`
+	Conditions map[string]interface{} `json:"conditions,omitempty"`
+}
+
+type pageResult[T any] struct {
+	Records    []T `json:"records"`
+	TotalPages int `json:"total_pages"`
+}
+
+// Table name of the model, used as the cache namespace
+func tableName[T any](db *gorm.DB) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return "", err
+	}
+	return stmt.Schema.Table, nil
+}
+
+// GetRecordByID served from the cache, invalidated on writes to the model
+func GetRecordByIDCached[T any](ctx context.Context, c *cache.Cache, db *gorm.DB, id string) (*T, error) {
+	model, err := tableName[T](db)
+	if err != nil {
+		return nil, err
+	}
+
+	return cache.Fetch(ctx, c, model, pageQuery{Op: "by_id", ID: id}, func() (*T, error) {
+		return GetRecordByID[T](db.WithContext(ctx), id)
+	})
+}
+
+// GetAllRecords served from the cache, invalidated on writes to the model
+func GetAllRecordsCached[T any](ctx context.Context, c *cache.Cache, db *gorm.DB, page, pageSize int) ([]T, int, error) {
+	model, err := tableName[T](db)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, err := cache.Fetch(ctx, c, model, pageQuery{Op: "all", Page: page, PageSize: pageSize}, func() (pageResult[T], error) {
+		records, totalPages, err := GetAllRecords[T](db.WithContext(ctx), page, pageSize)
+		return pageResult[T]{Records: records, TotalPages: totalPages}, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return result.Records, result.TotalPages, nil
+}
+
+// GetFilteredPaginatedRecords served from the cache, invalidated on writes to
+// the model. Conditions are part of the key, so they must encode to JSON.
+func GetFilteredPaginatedRecordsCached[T any](ctx context.Context, c *cache.Cache, db *gorm.DB, page, pageSize int, conditions map[string]interface{}) ([]T, int, error) {
+	model, err := tableName[T](db)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := pageQuery{Op: "filtered", Page: page, PageSize: pageSize, Conditions: conditions}
+	result, err := cache.Fetch(ctx, c, model, query, func() (pageResult[T], error) {
+		records, totalPages, err := GetFilteredPaginatedRecords[T](db.WithContext(ctx), page, pageSize, conditions)
+		return pageResult[T]{Records: records, TotalPages: totalPages}, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return result.Records, result.TotalPages, nil
+}
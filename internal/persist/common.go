@@ -121,11 +121,37 @@ func GetFilteredPaginatedRecords[T any](db *gorm.DB, page, pageSize int, conditi
 	query := db.Model(new(T)) // Apply model to the query for proper counting
 
 	// Apply conditions dynamically
+	query, err := ApplyFilters(query, conditions)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Count total number of records after applying conditions
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Calculate total pages
+	totalPages := int((totalRecords + int64(pageSize) - 1) / int64(pageSize))
+
+	// Apply pagination
+	offset := (page - 1) * pageSize
+	result := query.Offset(offset).Limit(pageSize).Find(&records)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return records, totalPages, nil
+}
+
+// Apply filter conditions to a query. Values for price and pct_remaining may
+// end with "+" or "-" for lower and upper bounds, everything else is equality.
+func ApplyFilters(query *gorm.DB, conditions map[string]interface{}) (*gorm.DB, error) {
 	for field, value := range conditions {
 		if field == "price" {
 			str, ok := value.(string)
 			if !ok {
-				return nil, 0, fmt.Errorf("value (price) is not a string")
+				return nil, fmt.Errorf("value (price) is not a string")
 			}
 
 			// Handle "+" and "-" at the end of the string
@@ -134,7 +160,7 @@ func GetFilteredPaginatedRecords[T any](db *gorm.DB, page, pageSize int, conditi
 
 			price, err := strconv.Atoi(priceStr)
 			if err != nil {
-				return nil, 0, fmt.Errorf("invalid price value: %v", err)
+				return nil, fmt.Errorf("invalid price value: %v", err)
 			}
 
 			if string(lastChar) == "-" {
@@ -151,7 +177,7 @@ func GetFilteredPaginatedRecords[T any](db *gorm.DB, page, pageSize int, conditi
 		if field == "pct_remaining" {
 			str, ok := value.(string)
 			if !ok {
-				return nil, 0, fmt.Errorf("value (pct_remaining) is not a string")
+				return nil, fmt.Errorf("value (pct_remaining) is not a string")
 			}
 
 			// Handle "+" and "-" at the end of the string
@@ -160,7 +186,7 @@ func GetFilteredPaginatedRecords[T any](db *gorm.DB, page, pageSize int, conditi
 
 			pctRemaining, err := strconv.ParseFloat(pctStr, 64)
 			if err != nil {
-				return nil, 0, fmt.Errorf("invalid pct_remaining value: %v", err)
+				return nil, fmt.Errorf("invalid pct_remaining value: %v", err)
 			}
 
 			if string(lastChar) == "-" {
@@ -178,22 +204,7 @@ func GetFilteredPaginatedRecords[T any](db *gorm.DB, page, pageSize int, conditi
 		query = query.Where(field+" = ?", value)
 	}
 
-	// Count total number of records after applying conditions
-	if err := query.Count(&totalRecords).Error; err != nil {
-		return nil, 0, err
-	}
-
-	// Calculate total pages
-	totalPages := int((totalRecords + int64(pageSize) - 1) / int64(pageSize))
-
-	// Apply pagination
-	offset := (page - 1) * pageSize
-	result := query.Offset(offset).Limit(pageSize).Find(&records)
-	if result.Error != nil {
-		return nil, 0, result.Error
-	}
-
-	return records, totalPages, nil
+	return query, nil
 }
 
 func UpdateRecordByID[T any, U any](db *gorm.DB, id string, updates U) error {
@@ -213,3 +224,33 @@ func DeleteRecordByID[T any](db *gorm.DB, id string) error {
 	}
 	return nil
 }
+
+// Apply updates only if the record is still at the expected version. The
+// version is incremented on success, ErrConflict is returned if the record
+// has been modified since and gorm.ErrRecordNotFound if it doesn't exist.
+func UpdateRecordByIDIfVersion[T any, U any](db *gorm.DB, id string, version int64, updates U) error {
+	record := new(T)
+	versioned, ok := any(record).(Versioned)
+	if !ok {
+		return ErrNotVersioned
+	}
+	versioned.SetVersion(version)
+
+	result := db.Model(record).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := db.Model(new(T)).Where("id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return ErrConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package persist
+
+import "errors"
+
+var (
+	// Returned when a conditional update finds the record was modified since
+	// the version the caller read
+	ErrConflict = errors.New("record was modified by another request")
+
+	// Returned when a model does not support the requested operation
+	ErrNotVersioned = errors.New("model does not support versioning")
+
+	// Returned when a model without a DeletedAt column would be hard deleted
+	ErrNotSoftDeletable = errors.New("model does not support soft deletion")
+)
@@ -0,0 +1,74 @@
+package persist
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+	"x/core/internal/auth"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/plugin/optimisticlock"
+)
+
+// Base model embedded by application models. Rows are soft deleted through
+// DeletedAt and track the principal that created and last updated them.
+type Base struct {
+	ID        string         `gorm:"type:uuid;primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	CreatedBy string         `json:"created_by,omitempty"`
+	UpdatedBy string         `json:"updated_by,omitempty"`
+
+	// Incremented on every update, used for optimistic concurrency control
+	Version optimisticlock.Version `gorm:"not null;default:1" json:"version"`
+}
+
+// Models implement Versioned to support conditional updates
+type Versioned interface {
+	GetVersion() int64
+	SetVersion(version int64)
+}
+
+func (b *Base) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == "" {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return fmt.Errorf("error generating id: %v", err)
+		}
+		b.ID = id.String()
+	}
+
+	if p, ok := auth.FromContext(tx.Statement.Context); ok {
+		if b.CreatedBy == "" {
+			b.CreatedBy = p.UserID
+		}
+		b.UpdatedBy = p.UserID
+	}
+
+	return nil
+}
+
+func (b *Base) BeforeUpdate(tx *gorm.DB) error {
+	p, ok := auth.FromContext(tx.Statement.Context)
+	if !ok {
+		return nil
+	}
+
+	// SetColumn can only write into map updates or updates of the model itself
+	dest := reflect.Indirect(reflect.ValueOf(tx.Statement.Dest))
+	if dest.Kind() == reflect.Map || dest.Type() == tx.Statement.ReflectValue.Type() {
+		tx.Statement.SetColumn("updated_by", p.UserID)
+	}
+
+	return nil
+}
+
+func (b Base) GetVersion() int64 {
+	return b.Version.Int64
+}
+
+func (b *Base) SetVersion(version int64) {
+	b.Version = optimisticlock.Version{Int64: version, Valid: true}
+}
@@ -1,18 +1,22 @@
 package persist
 
 import (
+	"x/core/internal/cache"
+
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 )
 
 type PGStore struct {
-	DB *gorm.DB
-	z  *zerolog.Logger
+	DB    *gorm.DB
+	Cache *cache.Cache
+	z     *zerolog.Logger
 }
 
-func NewPGStore(db *gorm.DB, log *zerolog.Logger) *PGStore {
+func NewPGStore(db *gorm.DB, c *cache.Cache, log *zerolog.Logger) *PGStore {
 	return &PGStore{
-		DB: db,
-		z:  log,
+		DB:    db,
+		Cache: c,
+		z:     log,
 	}
 }
@@ -0,0 +1,86 @@
+package persist
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Pool is the gorm connection pool of the primary database. Transactions are
+// begun on a dedicated connection, so COPY can run inside them, and run
+// their after commit hooks once committed.
+type Pool struct {
+	*sql.DB
+}
+
+func NewPool(db *sql.DB) *Pool {
+	return &Pool{DB: db}
+}
+
+// Underlying database, for gorm's DB()
+func (p *Pool) GetDBConn() (*sql.DB, error) {
+	return p.DB, nil
+}
+
+func (p *Pool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	conn, err := p.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := conn.BeginTx(ctx, opts)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Tx{Tx: tx, db: p.DB, conn: conn}, nil
+}
+
+// Tx is a transaction begun by a Pool
+type Tx struct {
+	*sql.Tx
+	db   *sql.DB
+	conn *sql.Conn
+
+	mu          sync.Mutex
+	afterCommit []func()
+}
+
+func (t *Tx) GetDBConn() (*sql.DB, error) {
+	return t.db, nil
+}
+
+// Run fn once the transaction commits, it is dropped on rollback
+func (t *Tx) AfterCommit(fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.afterCommit = append(t.afterCommit, fn)
+}
+
+func (t *Tx) Commit() error {
+	err := t.Tx.Commit()
+	t.conn.Close()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	hooks := t.afterCommit
+	t.afterCommit = nil
+	t.mu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+	return nil
+}
+
+func (t *Tx) Rollback() error {
+	err := t.Tx.Rollback()
+	t.conn.Close()
+
+	t.mu.Lock()
+	t.afterCommit = nil
+	t.mu.Unlock()
+	return err
+}
@@ -0,0 +1,144 @@
+package persist
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+
+// Whether the model is soft deleted, embedding Base or a gorm.DeletedAt
+// DeletedAt field. Deleting other models would remove their rows for good.
+func softDeletable(model interface{}) bool {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	f, ok := t.FieldByName("DeletedAt")
+	return ok && f.Type == deletedAtType
+}
+
+// Soft delete a record, the model must embed Base or gorm.DeletedAt
+func SoftDelete[T any](db *gorm.DB, id string) error {
+	if !softDeletable(new(T)) {
+		return ErrNotSoftDeletable
+	}
+	result := db.Where("id = ?", id).Delete(new(T))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Restore a soft deleted record
+func Restore[T any](db *gorm.DB, id string) error {
+	if !softDeletable(new(T)) {
+		return ErrNotSoftDeletable
+	}
+	result := db.Unscoped().Model(new(T)).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// List soft deleted records, most recently deleted first
+func ListDeleted[T any](db *gorm.DB, page, pageSize int) ([]T, int, error) {
+	if !softDeletable(new(T)) {
+		return nil, 0, ErrNotSoftDeletable
+	}
+
+	var records []T
+	var totalRecords int64
+
+	query := db.Unscoped().Model(new(T)).Where("deleted_at IS NOT NULL")
+
+	// Count total number of deleted records
+	if err := query.Count(&totalRecords).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Apply pagination
+	offset := (page - 1) * pageSize
+	result := query.Order("deleted_at DESC").Offset(offset).Limit(pageSize).Find(&records)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	// Calculate the total number of pages
+	totalPages := int((totalRecords + int64(pageSize) - 1) / int64(pageSize))
+
+	return records, totalPages, nil
+}
+
+// Permanently delete records soft deleted longer than the given age
+func PurgeOlderThan[T any](db *gorm.DB, age time.Duration) (int64, error) {
+	if !softDeletable(new(T)) {
+		return 0, ErrNotSoftDeletable
+	}
+	return purge(db, new(T), time.Now().Add(-age))
+}
+
+func purge(db *gorm.DB, model interface{}, before time.Time) (int64, error) {
+	result := db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Delete(model)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// Retainer is implemented by models kept for another period than the
+// default retention once soft deleted, zero keeps them
+type Retainer interface {
+	Retention() time.Duration
+}
+
+// Purge rows soft deleted longer than the retention period for every soft
+// deletable model, the model's own when it is a Retainer. A retention of
+// zero leaves them untouched.
+func (s *PGStore) PurgeDeleted(ctx context.Context, models []interface{}, defaultRetention time.Duration) error {
+	for _, model := range models {
+		if !softDeletable(model) {
+			continue
+		}
+
+		retention := defaultRetention
+		if r, ok := model.(Retainer); ok {
+			retention = r.Retention()
+		}
+		if retention <= 0 {
+			continue
+		}
+
+		purged, err := purge(s.DB.WithContext(ctx), model, time.Now().Add(-retention))
+		if err != nil {
+			return fmt.Errorf("error purging %s: %v", reflect.TypeOf(model).Elem().Name(), err)
+		}
+		if purged > 0 {
+			s.z.Info().
+				Str("model", reflect.TypeOf(model).Elem().Name()).
+				Int64("purged", purged).
+				Dur("retention", retention).
+				Msg("purged soft deleted records")
+		}
+	}
+
+	return nil
+}
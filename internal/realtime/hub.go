@@ -0,0 +1,208 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"x/core/internal/events"
+	"x/core/internal/metrics"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// Most connections a user can hold open at once, e.g. browser tabs
+	MaxConnectionsPerUser = 5
+	// Most resources a connection can follow public events of
+	MaxResources = 50
+)
+
+var (
+	ErrClosed             = errors.New("realtime gateway is shutting down")
+	ErrLagging            = errors.New("connection fell too far behind")
+	ErrTooManyConnections = fmt.Errorf("at most %d realtime connections per user", MaxConnectionsPerUser)
+	ErrTooManyResources   = fmt.Errorf("at most %d resources can be followed", MaxResources)
+)
+
+// Client is one connection of a user. It receives the events addressed to
+// the user and the public events of the resources it follows.
+type Client struct {
+	UserID    string
+	resources []string
+
+	events chan events.Event
+	done   chan struct{}
+	once   sync.Once
+	err    error
+}
+
+// Events to deliver to the connection, buffered up to the hub's buffer size
+func (c *Client) Events() <-chan events.Event {
+	return c.events
+}
+
+// Closed when the hub drops the connection, Err tells why
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) Err() error {
+	<-c.done
+	return c.err
+}
+
+func (c *Client) close(err error) {
+	c.once.Do(func() {
+		c.err = err
+		close(c.done)
+	})
+}
+
+// Hub fans the events of the bus out to connected clients. Events are never
+// waited on: a client whose buffer is full is dropped so it reconnects and
+// refetches, instead of slowing down publishers or other clients.
+type Hub struct {
+	z          *zerolog.Logger
+	bufferSize int
+	// Event types delivered to followers of their resource rather than to
+	// the users they are addressed to
+	public []events.Type
+
+	mu        sync.RWMutex
+	closed    bool
+	users     map[string]map[*Client]struct{}
+	resources map[string]map[*Client]struct{}
+	wg        sync.WaitGroup
+}
+
+func NewHub(logger *zerolog.Logger, bufferSize int, public ...events.Type) *Hub {
+	return &Hub{
+		z:          logger,
+		bufferSize: bufferSize,
+		public:     public,
+		users:      make(map[string]map[*Client]struct{}),
+		resources:  make(map[string]map[*Client]struct{}),
+	}
+}
+
+// Deliver an event to the clients it concerns, subscribed to the event bus
+func (h *Hub) Publish(ctx context.Context, e events.Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(e.UserIDs) > 0 {
+		for _, userID := range e.UserIDs {
+			for c := range h.users[userID] {
+				h.offer(c, e)
+			}
+		}
+		return
+	}
+	if slices.Contains(h.public, e.Type) {
+		for c := range h.resources[e.ResourceID] {
+			h.offer(c, e)
+		}
+	}
+}
+
+func (h *Hub) offer(c *Client, e events.Event) {
+	select {
+	case c.events <- e:
+	case <-c.done:
+	default:
+		h.z.Warn().Str("user", c.UserID).Str("event", string(e.Type)).Msg("realtime client lagging, disconnecting")
+		metrics.RealtimeDisconnects.WithLabelValues("lagging").Inc()
+		c.close(ErrLagging)
+	}
+}
+
+// Connect a client of the user following the public events of the resources
+func (h *Hub) Register(userID string, resources []string) (*Client, error) {
+	if len(resources) > MaxResources {
+		return nil, ErrTooManyResources
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrClosed
+	}
+	if len(h.users[userID]) >= MaxConnectionsPerUser {
+		return nil, ErrTooManyConnections
+	}
+
+	c := &Client{
+		UserID:    userID,
+		resources: resources,
+		events:    make(chan events.Event, h.bufferSize),
+		done:      make(chan struct{}),
+	}
+	add(h.users, userID, c)
+	for _, id := range resources {
+		add(h.resources, id, c)
+	}
+	h.wg.Add(1)
+	return c, nil
+}
+
+// Disconnect a client once its connection is done with it
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.users[c.UserID][c]; !ok {
+		return
+	}
+	remove(h.users, c.UserID, c)
+	for _, id := range c.resources {
+		remove(h.resources, id, c)
+	}
+	c.close(nil)
+	h.wg.Done()
+}
+
+// Stop accepting clients and tell connected ones to close
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, clients := range h.users {
+		for c := range clients {
+			c.close(ErrClosed)
+		}
+	}
+}
+
+// Wait for connected clients to unregister, or the context to end
+func (h *Hub) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func add(index map[string]map[*Client]struct{}, key string, c *Client) {
+	if index[key] == nil {
+		index[key] = make(map[*Client]struct{})
+	}
+	index[key][c] = struct{}{}
+}
+
+func remove(index map[string]map[*Client]struct{}, key string, c *Client) {
+	delete(index[key], c)
+	if len(index[key]) == 0 {
+		delete(index, key)
+	}
+}
@@ -0,0 +1,22 @@
+package reqctx
+
+import "context"
+
+// Meta is request metadata carried through the context into the service
+// and persistence layers
+type Meta struct {
+	RequestID string
+	ClientIP  string
+	UserAgent string
+}
+
+type metaKey struct{}
+
+func WithMeta(ctx context.Context, m Meta) context.Context {
+	return context.WithValue(ctx, metaKey{}, m)
+}
+
+func FromContext(ctx context.Context) (Meta, bool) {
+	m, ok := ctx.Value(metaKey{}).(Meta)
+	return m, ok
+}
@@ -0,0 +1,105 @@
+package reviews
+
+import (
+	"errors"
+	"math"
+	"time"
+	"x/core/internal/events"
+	"x/core/internal/persist"
+)
+
+var (
+	ErrNotReviewable    = errors.New("order can't be reviewed")
+	ErrAlreadyReviewed  = errors.New("order was already reviewed")
+	ErrNotAuthor        = errors.New("review belongs to another user")
+	ErrEditWindowClosed = errors.New("review can no longer be edited")
+	ErrAuthenticity     = errors.New("only buyers confirm authenticity")
+)
+
+// Side of the order the reviewer was on
+type Role string
+
+const (
+	RoleBuyer  Role = "buyer"
+	RoleSeller Role = "seller"
+)
+
+const (
+	EventCreated events.Type = "review.created"
+	EventUpdated events.Type = "review.updated"
+)
+
+const resourceType = "review"
+
+// Review is left by the buyer or the seller of a completed order about the
+// other party, once per side
+type Review struct {
+	persist.Base
+	OrderID    string `gorm:"type:uuid;not null;uniqueIndex:idx_review_order_role,where:deleted_at IS NULL" json:"order_id"`
+	Role       Role   `gorm:"not null;uniqueIndex:idx_review_order_role,where:deleted_at IS NULL" json:"role"`
+	ListingID  string `gorm:"type:uuid;not null;index" json:"listing_id"`
+	ReviewerID string `gorm:"not null;index" json:"reviewer_id"`
+	RevieweeID string `gorm:"not null;index" json:"reviewee_id"`
+
+	Rating int    `gorm:"not null;check:chk_review_rating,rating BETWEEN 1 AND 5" json:"rating"`
+	Text   string `json:"text,omitempty"`
+	// Buyer's confirmation the item was authentic, unset for seller reviews
+	Authentic *bool `json:"authentic,omitempty"`
+
+	EditableUntil time.Time `json:"editable_until"`
+}
+
+func (r *Review) Event(t events.Type) events.Event {
+	return events.New(t, resourceType, r.ID, r, r.RevieweeID)
+}
+
+// ReviewInput creates or replaces the reviewer's rating of the other party
+type ReviewInput struct {
+	Rating    int    `json:"rating" validate:"required,min=1,max=5"`
+	Text      string `json:"text,omitempty" validate:"max=2000"`
+	Authentic *bool  `json:"authentic,omitempty" doc:"Buyers confirm the item was authentic"`
+}
+
+// Policy bounds when reviews are left and edited, and how reputation decays
+type Policy struct {
+	// How long after an order completes it can be reviewed
+	ReviewWindow time.Duration
+	// How long after being left a review can be edited
+	EditWindow time.Duration
+	// Age at which a review counts half as much towards reputation
+	HalfLife time.Duration
+}
+
+// Prior pulling scores of users with few reviews towards the average, worth
+// this many fully weighted reviews
+const priorWeight = 5
+
+// Prior mean when there are no reviews at all yet
+const defaultPrior = 4.0
+
+// Reputation aggregates the reviews a user received
+type Reputation struct {
+	UserID string `json:"user_id"`
+	// Bayesian average of time decayed ratings, from 1 to 5
+	Score   float64 `json:"score"`
+	Count   int64   `json:"count"`
+	Average float64 `json:"average"`
+	// Reviews received as the seller and as the buyer of orders
+	AsSeller int64 `json:"as_seller"`
+	AsBuyer  int64 `json:"as_buyer"`
+	// Buyers who confirmed the items they bought were authentic
+	AuthenticConfirmations int64 `json:"authentic_confirmations"`
+	// Number of reviews of each rating, 1 to 5
+	Distribution map[int]int64 `json:"distribution"`
+}
+
+// Bayesian average of decayed ratings: the prior mean counts as priorWeight
+// reviews, so scores settle on the user's own ratings as reviews accumulate
+func score(weightedSum, weight, prior float64) float64 {
+	return round((priorWeight*prior + weightedSum) / (priorWeight + weight))
+}
+
+// Round ratings to two decimals
+func round(rating float64) float64 {
+	return math.Round(rating*100) / 100
+}
@@ -0,0 +1,181 @@
+package reviews
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"x/core/internal/orders"
+	"x/core/internal/persist"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Reviews left on an order, at most one per side
+func ListByOrder(db *gorm.DB, orderID string) ([]Review, error) {
+	var records []Review
+	err := db.Where("order_id = ?", orderID).Order("created_at").Find(&records).Error
+	return records, err
+}
+
+// Role of the reviewer on a completed order still open to reviews, the side
+// reviewing the other
+func reviewerRole(o *orders.Order, userID string, window time.Duration, now time.Time) (Role, error) {
+	role := RoleBuyer
+	switch userID {
+	case o.BuyerID:
+	case o.SellerID:
+		role = RoleSeller
+	default:
+		return "", orders.ErrNotParty
+	}
+
+	completed := o.ReleasedAt
+	if o.Status == orders.StatusRefunded {
+		completed = o.RefundedAt
+	}
+	if (o.Status != orders.StatusReleased && o.Status != orders.StatusRefunded) || completed == nil {
+		return "", fmt.Errorf("%w: order is %s", ErrNotReviewable, o.Status)
+	}
+	if now.After(completed.Add(window)) {
+		return "", fmt.Errorf("%w: reviews closed %s", ErrNotReviewable, completed.Add(window).Format(time.RFC3339))
+	}
+	return role, nil
+}
+
+// Review an order as its buyer or seller
+func Create(db *gorm.DB, o *orders.Order, userID string, in ReviewInput, policy Policy) (*Review, error) {
+	now := time.Now()
+	role, err := reviewerRole(o, userID, policy.ReviewWindow, now)
+	if err != nil {
+		return nil, err
+	}
+	if in.Authentic != nil && role != RoleBuyer {
+		return nil, ErrAuthenticity
+	}
+
+	r := &Review{
+		OrderID:       o.ID,
+		Role:          role,
+		ListingID:     o.ListingID,
+		ReviewerID:    userID,
+		RevieweeID:    o.SellerID,
+		Rating:        in.Rating,
+		Text:          in.Text,
+		Authentic:     in.Authentic,
+		EditableUntil: now.Add(policy.EditWindow),
+	}
+	if role == RoleSeller {
+		r.RevieweeID = o.BuyerID
+	}
+
+	if err := db.Create(r).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrAlreadyReviewed
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// Replace the rating of a review by its author while it is editable and still
+// at the expected version
+func Update(db *gorm.DB, userID, id string, version int64, in ReviewInput) (*Review, error) {
+	r, err := persist.GetRecordByID[Review](db, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.ReviewerID != userID {
+		return nil, ErrNotAuthor
+	}
+	if time.Now().After(r.EditableUntil) {
+		return nil, ErrEditWindowClosed
+	}
+	if in.Authentic != nil && r.Role != RoleBuyer {
+		return nil, ErrAuthenticity
+	}
+
+	// Map updates so cleared fields are written too
+	err = persist.UpdateRecordByIDIfVersion[Review](db, id, version, map[string]interface{}{
+		"rating":    in.Rating,
+		"text":      in.Text,
+		"authentic": in.Authentic,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return persist.GetRecordByID[Review](db, id)
+}
+
+type aggregate struct {
+	Count       int64
+	Average     float64
+	WeightedSum float64
+	Weight      float64
+	AsSeller    int64
+	AsBuyer     int64
+	Authentic   int64
+}
+
+type bucket struct {
+	Rating int
+	Count  int64
+}
+
+// Reputation of a user from the reviews they received. Each rating is
+// weighted by half for every half life of its age, and the weighted mean is
+// pulled towards the marketplace average until enough reviews accumulate.
+func GetReputation(db *gorm.DB, userID string, halfLife time.Duration) (*Reputation, error) {
+	var prior struct{ Average *float64 }
+	if err := db.Model(&Review{}).Select("AVG(rating) AS average").Scan(&prior).Error; err != nil {
+		return nil, err
+	}
+
+	var agg aggregate
+	weight := "POWER(0.5, EXTRACT(EPOCH FROM (NOW() - created_at)) / ?)"
+	err := db.Model(&Review{}).
+		Select(
+			"COUNT(*) AS count, COALESCE(AVG(rating), 0) AS average, "+
+				"COALESCE(SUM(rating * "+weight+"), 0) AS weighted_sum, "+
+				"COALESCE(SUM("+weight+"), 0) AS weight, "+
+				"COUNT(*) FILTER (WHERE role = ?) AS as_seller, "+
+				"COUNT(*) FILTER (WHERE role = ?) AS as_buyer, "+
+				"COUNT(*) FILTER (WHERE authentic) AS authentic",
+			halfLife.Seconds(), halfLife.Seconds(), RoleBuyer, RoleSeller,
+		).
+		Where("reviewee_id = ?", userID).
+		Scan(&agg).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []bucket
+	err = db.Model(&Review{}).
+		Select("rating, COUNT(*) AS count").
+		Where("reviewee_id = ?", userID).
+		Group("rating").
+		Scan(&buckets).Error
+	if err != nil {
+		return nil, err
+	}
+
+	mean := defaultPrior
+	if prior.Average != nil {
+		mean = *prior.Average
+	}
+	rep := &Reputation{
+		UserID:                 userID,
+		Score:                  score(agg.WeightedSum, agg.Weight, mean),
+		Count:                  agg.Count,
+		Average:                round(agg.Average),
+		AsSeller:               agg.AsSeller,
+		AsBuyer:                agg.AsBuyer,
+		AuthenticConfirmations: agg.Authentic,
+		Distribution:           map[int]int64{1: 0, 2: 0, 3: 0, 4: 0, 5: 0},
+	}
+	for _, b := range buckets {
+		rep.Distribution[b.Rating] = b.Count
+	}
+	return rep, nil
+}
@@ -0,0 +1,361 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"x/core/internal/persist"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+type Weight string
+
+const (
+	WeightA Weight = "A"
+	WeightB Weight = "B"
+	WeightC Weight = "C"
+	WeightD Weight = "D"
+)
+
+const (
+	vectorColumn        = "search_vector"
+	defaultLanguage     = "english"
+	defaultSimilarity   = 0.3
+	defaultFacetLimit   = 20
+	defaultSnippetStyle = "StartSel=<mark>,StopSel=</mark>,MaxWords=25,MinWords=10,MaxFragments=2"
+)
+
+// Field contributes to the search vector. Expr is used instead of the column
+// when set, e.g. to flatten an array of notes.
+type Field struct {
+	Column string
+	Expr   string
+	Weight Weight
+}
+
+type PriceBucket struct {
+	Label string `json:"label"`
+	Min   int    `json:"min"`
+	Max   int    `json:"max,omitempty"` // exclusive, 0 for unbounded
+}
+
+// Index describes how a table is searched
+type Index struct {
+	Table    string
+	Language string
+
+	// Weighted fields of the generated search vector
+	Fields []Field
+
+	// Columns matched by trigram similarity for typo tolerance
+	TrigramColumns []string
+
+	// Column highlighted in result snippets
+	SnippetColumn string
+
+	// Columns returned in hits
+	ResultColumns []string
+
+	// Columns counted per distinct value
+	Facets []string
+
+	// Column and buckets for price range counts, counted per currency when
+	// the currency column is set
+	PriceColumn    string
+	PriceBuckets   []PriceBucket
+	CurrencyColumn string
+
+	// Conditions always applied, e.g. only active listings
+	Scope map[string]interface{}
+
+	// Skip soft deleted rows
+	ExcludeDeleted bool
+}
+
+type Query struct {
+	Text     string
+	Filters  map[string]interface{}
+	Page     int
+	PageSize int
+}
+
+type Hit struct {
+	Record  map[string]interface{} `json:"record"`
+	Rank    float64                `json:"rank"`
+	Snippet string                 `json:"snippet,omitempty"`
+}
+
+type FacetCount struct {
+	Value interface{} `json:"value"`
+	Count int64       `json:"count"`
+}
+
+type Result struct {
+	Hits       []Hit                   `json:"hits"`
+	TotalPages int                     `json:"total_pages"`
+	Facets     map[string][]FacetCount `json:"facets"`
+	// Price range counts by currency
+	PriceRanges map[string][]FacetCount `json:"price_ranges,omitempty"`
+}
+
+func (ix Index) language() string {
+	if ix.Language == "" {
+		return defaultLanguage
+	}
+	return ix.Language
+}
+
+// Expression building the weighted search vector
+func (ix Index) vectorExpr() string {
+	var parts []string
+	for _, f := range ix.Fields {
+		expr := f.Expr
+		if expr == "" {
+			expr = fmt.Sprintf("coalesce(%s::text, '')", f.Column)
+		}
+		parts = append(parts, fmt.Sprintf("setweight(to_tsvector('%s', %s), '%s')", ix.language(), expr, f.Weight))
+	}
+	return strings.Join(parts, " || ")
+}
+
+// Add the generated search vector column and indexes. Tables that don't
+// exist yet are skipped.
+func (ix Index) Migrate(db *gorm.DB) error {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		return fmt.Errorf("error creating pg_trgm extension: %v", err)
+	}
+
+	if !db.Migrator().HasTable(ix.Table) {
+		return nil
+	}
+
+	statements := []string{
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s tsvector GENERATED ALWAYS AS (%s) STORED",
+			ix.Table, vectorColumn, ix.vectorExpr()),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s ON %s USING GIN (%s)",
+			ix.Table, vectorColumn, ix.Table, vectorColumn),
+	}
+	for _, column := range ix.TrigramColumns {
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s_trgm ON %s USING GIN (%s gin_trgm_ops)",
+			ix.Table, column, ix.Table, column))
+	}
+
+	for _, sql := range statements {
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("error migrating search index for %s: %v", ix.Table, err)
+		}
+	}
+	return nil
+}
+
+func (ix Index) tsquery() string {
+	return fmt.Sprintf("websearch_to_tsquery('%s', ?)", ix.language())
+}
+
+// Rows matching the text either through the search vector or by trigram
+// similarity, with the scope and filters applied. The <% operator can use
+// the trigram indexes, its threshold is pg_trgm.word_similarity_threshold.
+func (ix Index) matching(db *gorm.DB, q Query) (*gorm.DB, error) {
+	query := db.Table(ix.Table)
+
+	if q.Text != "" {
+		conditions := []string{fmt.Sprintf("%s @@ %s", vectorColumn, ix.tsquery())}
+		vars := []interface{}{q.Text}
+		for _, column := range ix.TrigramColumns {
+			conditions = append(conditions, fmt.Sprintf("? <%% %s", column))
+			vars = append(vars, q.Text)
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", vars...)
+	}
+
+	for field, value := range ix.Scope {
+		query = query.Where(field+" = ?", value)
+	}
+	if ix.ExcludeDeleted {
+		query = query.Where("deleted_at IS NULL")
+	}
+
+	return persist.ApplyFilters(query, q.Filters)
+}
+
+// Relevance of a row, full text rank boosted by trigram similarity
+func (ix Index) rankExpr(text string) clause.Expr {
+	sql := fmt.Sprintf("ts_rank_cd(%s, %s)", vectorColumn, ix.tsquery())
+	vars := []interface{}{text}
+	for _, column := range ix.TrigramColumns {
+		sql += fmt.Sprintf(" + word_similarity(?, %s)", column)
+		vars = append(vars, text)
+	}
+	return clause.Expr{SQL: sql, Vars: vars}
+}
+
+// Search the index, returning a page of ranked hits with highlighted
+// snippets, facet counts and price range counts. The queries run in a read
+// transaction on a replica so the similarity threshold is only set for them.
+func Search(db *gorm.DB, ix Index, q Query) (*Result, error) {
+	var result *Result
+	err := db.Clauses(dbresolver.Read).Transaction(func(tx *gorm.DB) error {
+		if q.Text != "" && len(ix.TrigramColumns) > 0 {
+			threshold := strconv.FormatFloat(defaultSimilarity, 'f', -1, 64)
+			if err := tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)", threshold).Error; err != nil {
+				return fmt.Errorf("error setting similarity threshold: %v", err)
+			}
+		}
+
+		var err error
+		result, err = search(tx, ix, q)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func search(db *gorm.DB, ix Index, q Query) (*Result, error) {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 {
+		q.PageSize = 20
+	}
+
+	query, err := ix.matching(db, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var totalRecords int64
+	if err := query.Session(&gorm.Session{}).Count(&totalRecords).Error; err != nil {
+		return nil, err
+	}
+
+	columns := "*"
+	if len(ix.ResultColumns) > 0 {
+		columns = strings.Join(ix.ResultColumns, ", ")
+	}
+
+	// Rank and highlight only apply to text searches
+	var vars []interface{}
+	if q.Text != "" {
+		rank := ix.rankExpr(q.Text)
+		columns += ", (" + rank.SQL + ") AS search_rank"
+		vars = append(vars, rank.Vars...)
+
+		if ix.SnippetColumn != "" {
+			columns += fmt.Sprintf(", ts_headline('%s', coalesce(%s::text, ''), %s, '%s') AS search_snippet",
+				ix.language(), ix.SnippetColumn, ix.tsquery(), defaultSnippetStyle)
+			vars = append(vars, q.Text)
+		}
+	}
+
+	page := query.Session(&gorm.Session{}).Select(columns, vars...)
+	if q.Text != "" {
+		page = page.Order("search_rank DESC")
+	}
+
+	var rows []map[string]interface{}
+	offset := (q.Page - 1) * q.PageSize
+	if err := page.Offset(offset).Limit(q.PageSize).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Hits:       make([]Hit, 0, len(rows)),
+		TotalPages: int((totalRecords + int64(q.PageSize) - 1) / int64(q.PageSize)),
+		Facets:     make(map[string][]FacetCount),
+	}
+	for _, row := range rows {
+		hit := Hit{Record: row}
+		if rank, ok := row["search_rank"].(float64); ok {
+			hit.Rank = rank
+		} else if rank, ok := row["search_rank"].(float32); ok {
+			hit.Rank = float64(rank)
+		}
+		if snippet, ok := row["search_snippet"].(string); ok {
+			hit.Snippet = snippet
+		}
+		delete(row, "search_rank")
+		delete(row, "search_snippet")
+		delete(row, vectorColumn)
+		result.Hits = append(result.Hits, hit)
+	}
+
+	for _, facet := range ix.Facets {
+		var counts []FacetCount
+		if err := query.Session(&gorm.Session{}).
+			Select(facet + " AS value, count(*) AS count").
+			Group(facet).
+			Order("count DESC").
+			Limit(defaultFacetLimit).
+			Scan(&counts).Error; err != nil {
+			return nil, fmt.Errorf("error counting %s facet: %v", facet, err)
+		}
+		result.Facets[facet] = counts
+	}
+
+	if ix.PriceColumn != "" && len(ix.PriceBuckets) > 0 {
+		ranges, err := ix.priceRanges(query)
+		if err != nil {
+			return nil, err
+		}
+		result.PriceRanges = ranges
+	}
+
+	return result, nil
+}
+
+// Counts of the price buckets, by currency when the index has a currency
+// column and under "" otherwise
+func (ix Index) priceRanges(query *gorm.DB) (map[string][]FacetCount, error) {
+	var cases []string
+	var vars []interface{}
+	for _, b := range ix.PriceBuckets {
+		if b.Max > 0 {
+			cases = append(cases, fmt.Sprintf("WHEN %s >= ? AND %s < ? THEN ?", ix.PriceColumn, ix.PriceColumn))
+			vars = append(vars, b.Min, b.Max, b.Label)
+		} else {
+			cases = append(cases, fmt.Sprintf("WHEN %s >= ? THEN ?", ix.PriceColumn))
+			vars = append(vars, b.Min, b.Label)
+		}
+	}
+
+	currency := "''"
+	if ix.CurrencyColumn != "" {
+		currency = ix.CurrencyColumn
+	}
+
+	var counts []struct {
+		Currency string
+		Value    string
+		Count    int64
+	}
+	err := query.Session(&gorm.Session{}).
+		Select(currency+" AS currency, CASE "+strings.Join(cases, " ")+" END AS value, count(*) AS count", vars...).
+		Group("currency").
+		Group("value").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("error counting price ranges: %v", err)
+	}
+
+	byLabel := make(map[string]map[string]int64)
+	for _, c := range counts {
+		if byLabel[c.Currency] == nil {
+			byLabel[c.Currency] = make(map[string]int64)
+		}
+		byLabel[c.Currency][c.Value] = c.Count
+	}
+
+	// Keep the bucket order rather than the database order
+	ranges := make(map[string][]FacetCount, len(byLabel))
+	for currency, labels := range byLabel {
+		for _, b := range ix.PriceBuckets {
+			ranges[currency] = append(ranges[currency], FacetCount{Value: b.Label, Count: labels[b.Label]})
+		}
+	}
+	return ranges, nil
+}
@@ -0,0 +1,10 @@
+package service
+
+import (
+	"context"
+	"x/core/internal/audit"
+)
+
+func (s *Service) AuditHistory(ctx context.Context, filter audit.Filter, page, pageSize int) ([]audit.Entry, int, error) {
+	return audit.Query(s.p.DB.WithContext(ctx), filter, page, pageSize)
+}
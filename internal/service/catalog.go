@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"x/core/internal/catalog"
+	"x/core/internal/listings"
+	"x/core/internal/persist"
+	"x/core/internal/watchlists"
+)
+
+// Models referencing fragrances by fragrance_id, moved over when duplicates
+// are merged
+var fragranceReferences = []catalog.Reference{
+	{Model: &listings.Listing{}, Describe: listings.Details},
+	{Model: &watchlists.Watch{}, UniqueWith: "user_id"},
+}
+
+func (s *Service) ListBrands(ctx context.Context, page, pageSize int) ([]catalog.Brand, int, error) {
+	return persist.GetAllRecordsCached[catalog.Brand](ctx, s.p.Cache, s.p.DB, page, pageSize)
+}
+
+func (s *Service) GetBrand(ctx context.Context, id string) (*catalog.Brand, error) {
+	return persist.GetRecordByIDCached[catalog.Brand](ctx, s.p.Cache, s.p.DB, id)
+}
+
+func (s *Service) CreateBrand(ctx context.Context, in catalog.BrandInput) (*catalog.Brand, error) {
+	b, err := persist.InsertRecord(s.p.DB.WithContext(ctx), catalog.Brand{
+		Name:        in.Name,
+		Country:     in.Country,
+		Website:     in.Website,
+		Description: in.Description,
+	})
+	if err != nil {
+		return nil, catalog.DuplicateError(err)
+	}
+
+	s.z.Info().Str("brand", b.ID).Str("name", b.Name).Msg("brand created")
+	return b, nil
+}
+
+func (s *Service) UpdateBrand(ctx context.Context, id string, version int64, in catalog.BrandInput) (*catalog.Brand, error) {
+	db := s.p.DB.WithContext(ctx)
+	err := persist.UpdateRecordByIDIfVersion[catalog.Brand](db, id, version, map[string]interface{}{
+		"name":        in.Name,
+		"slug":        catalog.Slugify(in.Name),
+		"country":     in.Country,
+		"website":     in.Website,
+		"description": in.Description,
+	})
+	if err != nil {
+		return nil, catalog.DuplicateError(err)
+	}
+
+	return persist.GetRecordByID[catalog.Brand](db, id)
+}
+
+func (s *Service) DeleteBrand(ctx context.Context, id string) error {
+	if err := persist.SoftDelete[catalog.Brand](s.p.DB.WithContext(ctx), id); err != nil {
+		return err
+	}
+
+	s.z.Info().Str("brand", id).Msg("brand deleted")
+	return nil
+}
+
+func (s *Service) ListNotes(ctx context.Context, family string, page, pageSize int) ([]catalog.Note, int, error) {
+	conditions := map[string]interface{}{}
+	if family != "" {
+		conditions["family"] = family
+	}
+	return persist.GetFilteredPaginatedRecords[catalog.Note](s.p.DB.WithContext(ctx), page, pageSize, conditions)
+}
+
+func (s *Service) GetNote(ctx context.Context, id string) (*catalog.Note, error) {
+	return persist.GetRecordByIDCached[catalog.Note](ctx, s.p.Cache, s.p.DB, id)
+}
+
+func (s *Service) CreateNote(ctx context.Context, in catalog.NoteInput) (*catalog.Note, error) {
+	n, err := persist.InsertRecord(s.p.DB.WithContext(ctx), catalog.Note{
+		Name:   in.Name,
+		Family: in.Family,
+	})
+	if err != nil {
+		return nil, catalog.DuplicateError(err)
+	}
+	return n, nil
+}
+
+func (s *Service) UpdateNote(ctx context.Context, id string, version int64, in catalog.NoteInput) (*catalog.Note, error) {
+	db := s.p.DB.WithContext(ctx)
+	err := persist.UpdateRecordByIDIfVersion[catalog.Note](db, id, version, map[string]interface{}{
+		"name":   in.Name,
+		"slug":   catalog.Slugify(in.Name),
+		"family": in.Family,
+	})
+	if err != nil {
+		return nil, catalog.DuplicateError(err)
+	}
+
+	return persist.GetRecordByID[catalog.Note](db, id)
+}
+
+func (s *Service) DeleteNote(ctx context.Context, id string) error {
+	return persist.SoftDelete[catalog.Note](s.p.DB.WithContext(ctx), id)
+}
+
+func (s *Service) ListFragrances(ctx context.Context, filter catalog.FragranceFilter, page, pageSize int) ([]catalog.Fragrance, int, error) {
+	return catalog.ListFragrances(s.p.DB.WithContext(ctx), filter, page, pageSize)
+}
+
+func (s *Service) GetFragrance(ctx context.Context, id string) (*catalog.Fragrance, error) {
+	return catalog.GetFragrance(s.p.DB.WithContext(ctx), id)
+}
+
+func (s *Service) CreateFragrance(ctx context.Context, in catalog.FragranceInput) (*catalog.Fragrance, error) {
+	f, err := catalog.CreateFragrance(s.p.DB.WithContext(ctx), in)
+	if err != nil {
+		return nil, err
+	}
+
+	s.z.Info().Str("fragrance", f.ID).Str("name", f.Name).Msg("fragrance created")
+	return f, nil
+}
+
+func (s *Service) UpdateFragrance(ctx context.Context, id string, version int64, in catalog.FragranceInput) (*catalog.Fragrance, error) {
+	return catalog.UpdateFragrance(s.p.DB.WithContext(ctx), id, version, in)
+}
+
+func (s *Service) DeleteFragrance(ctx context.Context, id string) error {
+	if err := persist.SoftDelete[catalog.Fragrance](s.p.DB.WithContext(ctx), id); err != nil {
+		return err
+	}
+
+	s.z.Info().Str("fragrance", id).Msg("fragrance deleted")
+	return nil
+}
+
+func (s *Service) FragranceDuplicates(ctx context.Context, threshold float64, limit int) ([]catalog.DuplicateCandidate, error) {
+	if threshold <= 0 {
+		threshold = catalog.DefaultDuplicateSimilarity
+	}
+	return catalog.FindDuplicates(s.p.DB.WithContext(ctx), threshold, limit)
+}
+
+// Merge a duplicate fragrance into the canonical entry
+func (s *Service) MergeFragrance(ctx context.Context, duplicateID, canonicalID string) (*catalog.Fragrance, error) {
+	f, err := catalog.Merge(s.p.DB.WithContext(ctx), duplicateID, canonicalID, fragranceReferences)
+	if err != nil {
+		return nil, err
+	}
+
+	s.z.Info().Str("fragrance", canonicalID).Str("duplicate", duplicateID).Msg("duplicate fragrance merged")
+	return f, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"x/core/internal/flags"
+)
+
+func (s *Service) FeatureEnabled(ctx context.Context, key string) bool {
+	return s.flags.Enabled(ctx, key)
+}
+
+func (s *Service) FeatureVariant(ctx context.Context, key string) string {
+	return s.flags.Variant(ctx, key)
+}
+
+func (s *Service) EvaluateFlags(ctx context.Context) ([]flags.Evaluation, error) {
+	return s.flags.EvaluateAll(ctx)
+}
+
+func (s *Service) ListFlags(ctx context.Context) ([]flags.Flag, error) {
+	return s.flags.All(ctx)
+}
+
+func (s *Service) SaveFlag(ctx context.Context, flag flags.Flag) (*flags.Flag, error) {
+	f, err := s.flags.Save(ctx, flag)
+	if err != nil {
+		return nil, err
+	}
+
+	s.z.Info().Str("flag", f.Key).Bool("enabled", f.Enabled).Msg("feature flag saved")
+	return f, nil
+}
+
+func (s *Service) ToggleFlag(ctx context.Context, key string, enabled bool) (*flags.Flag, error) {
+	f, err := s.flags.Toggle(ctx, key, enabled)
+	if err != nil {
+		return nil, err
+	}
+
+	s.z.Info().Str("flag", key).Bool("enabled", enabled).Msg("feature flag toggled")
+	return f, nil
+}
+
+func (s *Service) DeleteFlag(ctx context.Context, key string) error {
+	if err := s.flags.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	s.z.Info().Str("flag", key).Msg("feature flag deleted")
+	return nil
+}
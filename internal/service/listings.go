@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+	"x/core/internal/auth"
+	"x/core/internal/catalog"
+	"x/core/internal/listings"
+	"x/core/internal/offers"
+	"x/core/internal/orders"
+	"x/core/internal/persist"
+
+	"gorm.io/gorm"
+)
+
+// Filters accepted on listing lists, mapped onto persist.ApplyFilters
+var ListingFilters = []string{"brand", "concentration", "condition", "fragrance_id", "seller_id", "price", "pct_remaining", "split"}
+
+// Transitions sellers make on their own listings, publishing a draft and
+// withdrawing. Reserving and selling are left to the offer and order flows,
+// expiry to the system.
+var sellerTransitions = map[listings.Status][]listings.Status{
+	listings.StatusDraft:    {listings.StatusActive, listings.StatusWithdrawn},
+	listings.StatusActive:   {listings.StatusWithdrawn},
+	listings.StatusReserved: {listings.StatusWithdrawn},
+}
+
+const expiryBatchSize = 100
+
+// Page of active listings matching the filters. Served from the cache,
+// decants are only written along with their listing so they are invalidated
+// with it.
+func (s *Service) ListListings(ctx context.Context, filters map[string]interface{}, page, pageSize int) ([]listings.Listing, int, error) {
+	conditions := map[string]interface{}{"status": listings.StatusActive}
+	for field, value := range filters {
+		conditions[field] = value
+	}
+	return persist.GetFilteredPaginatedRecordsCached[listings.Listing](ctx, s.p.Cache, listings.WithDecants(s.p.DB), page, pageSize, conditions)
+}
+
+// Listing as seen by buyers, drafts are only visible to their seller
+func (s *Service) GetListing(ctx context.Context, id string) (*listings.Listing, error) {
+	l, err := persist.GetRecordByIDCached[listings.Listing](ctx, s.p.Cache, listings.WithDecants(s.p.DB), id)
+	if err != nil {
+		return nil, err
+	}
+	if l.Status == listings.StatusDraft {
+		if p, ok := auth.FromContext(ctx); !ok || p.UserID != l.SellerID {
+			return nil, gorm.ErrRecordNotFound
+		}
+	}
+	return l, nil
+}
+
+// Page of the caller's own listings, optionally of a single status
+func (s *Service) ListSellerListings(ctx context.Context, status listings.Status, page, pageSize int) ([]listings.Listing, int, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conditions := map[string]interface{}{"seller_id": p.UserID}
+	if status != "" {
+		conditions["status"] = status
+	}
+	return persist.GetFilteredPaginatedRecords[listings.Listing](listings.WithDecants(s.p.DB.WithContext(ctx)), page, pageSize, conditions)
+}
+
+// One of the caller's listings with its status history
+func (s *Service) GetSellerListing(ctx context.Context, id string) (*listings.Listing, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := listings.GetListingWithTransitions(s.p.DB.WithContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if l.SellerID != p.UserID {
+		return nil, listings.ErrNotOwner
+	}
+	return l, nil
+}
+
+// Create a draft listing owned by the caller
+func (s *Service) CreateListing(ctx context.Context, in listings.ListingInput) (*listings.Listing, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	f, err := catalog.GetFragrance(db, in.FragranceID)
+	if err != nil {
+		return nil, fmt.Errorf("fragrance %s: %w", in.FragranceID, err)
+	}
+
+	l, err := listings.CreateListing(db, p.UserID, in, f)
+	if err != nil {
+		return nil, err
+	}
+
+	s.z.Info().Str("listing", l.ID).Str("seller", p.UserID).Msg("listing created")
+	return l, nil
+}
+
+// Locks a listing of the caller for the rest of the transaction
+func lockOwnListing(tx *gorm.DB, id string, p auth.Principal) (*listings.Listing, error) {
+	l, err := listings.Lock(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if l.SellerID != p.UserID {
+		return nil, listings.ErrNotOwner
+	}
+	return l, nil
+}
+
+// Replace the details of one of the caller's listings if it is still at the
+// expected version
+func (s *Service) UpdateListing(ctx context.Context, id string, version int64, in listings.ListingInput) (*listings.Listing, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	var change *listings.PriceChange
+	err = db.Transaction(func(tx *gorm.DB) error {
+		l, err := lockOwnListing(tx, id, p)
+		if err != nil {
+			return err
+		}
+		f, err := catalog.GetFragrance(tx, in.FragranceID)
+		if err != nil {
+			return fmt.Errorf("fragrance %s: %w", in.FragranceID, err)
+		}
+
+		// Drafts aren't public, their price changes go unannounced
+		if l.Status == listings.StatusActive && l.Price != in.Price {
+			change = &listings.PriceChange{ListingID: l.ID, Previous: l.Price, Price: in.Price}
+		}
+		return listings.UpdateListing(tx, l, version, in, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := listings.GetListing(db, id)
+	if err != nil {
+		return nil, err
+	}
+	if change != nil {
+		change.Currency = l.Currency
+		s.events.Publish(ctx, change.Event())
+	}
+	return l, nil
+}
+
+// Move one of the caller's listings to another status
+func (s *Service) TransitionListing(ctx context.Context, id string, to listings.Status, reason string) (*listings.Listing, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		l, err := lockOwnListing(tx, id, p)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(sellerTransitions[l.Status], to) {
+			return fmt.Errorf("%w: sellers cannot move a %s listing to %s", listings.ErrInvalidTransition, l.Status, to)
+		}
+		if to == listings.StatusWithdrawn {
+			if err := checkWithdrawable(tx, l.ID); err != nil {
+				return err
+			}
+		}
+		return listings.SetStatus(tx, l, to, listings.ActorFrom(p), reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.z.Info().Str("listing", id).Str("status", string(to)).Msg("listing status changed")
+	return listings.GetListingWithTransitions(db, id)
+}
+
+// Statuses of orders still to be settled, a split listing can be withdrawn
+// once its decant orders are released or refunded
+var inFlightOrders = []orders.Status{
+	orders.StatusPending,
+	orders.StatusAuthorized,
+	orders.StatusCaptured,
+	orders.StatusDisputed,
+}
+
+// Listings promised to a buyer through an accepted offer or an order still
+// in flight can't be withdrawn
+func checkWithdrawable(tx *gorm.DB, id string) error {
+	var accepted int64
+	if err := tx.Model(&offers.Offer{}).Where("listing_id = ? AND status = ?", id, offers.StatusAccepted).Count(&accepted).Error; err != nil {
+		return err
+	}
+	if accepted > 0 {
+		return fmt.Errorf("%w: listing has an accepted offer", listings.ErrInvalidTransition)
+	}
+
+	var ordered int64
+	if err := tx.Model(&orders.Order{}).Where("listing_id = ? AND status IN ?", id, inFlightOrders).Count(&ordered).Error; err != nil {
+		return err
+	}
+	if ordered > 0 {
+		return fmt.Errorf("%w: listing has open orders", listings.ErrInvalidTransition)
+	}
+	return nil
+}
+
+// Expire active listings published longer than the ttl ago, returning how
+// many were expired
+func (s *Service) ExpireListings(ctx context.Context, ttl time.Duration) (int, error) {
+	db := s.p.DB.WithContext(ctx)
+	ids, err := listings.StaleListingIDs(db, time.Now().Add(-ttl), expiryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			l, err := listings.Lock(tx, id)
+			if err != nil {
+				return err
+			}
+			// Reserved or withdrawn since it was selected
+			if l.Status != listings.StatusActive {
+				return nil
+			}
+			if err := listings.SetStatus(tx, l, listings.StatusExpired, listings.System, "listing expired"); err != nil {
+				return err
+			}
+			expired++
+			return nil
+		})
+		if err != nil {
+			return expired, fmt.Errorf("error expiring listing %s: %v", id, err)
+		}
+	}
+
+	if expired > 0 {
+		s.z.Info().Int("count", expired).Msg("listings expired")
+	}
+	return expired, nil
+}
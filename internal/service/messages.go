@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"x/core/internal/auth"
+	"x/core/internal/listings"
+	"x/core/internal/messages"
+	"x/core/internal/orders"
+
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/admin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Image formats accepted as message attachments
+const attachmentFormats = "jpg,jpeg,png,webp,heic"
+
+// Add a check every message is moderated with, after the default ones
+func (s *Service) AddModerationCheck(check messages.Check) {
+	s.moderation = append(s.moderation, check)
+}
+
+// Refuse messaging between users when either blocked the other
+func (s *Service) checkBlocked(db *gorm.DB, a, b string) error {
+	blocked, err := messages.IsBlocked(db, a, b)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return messages.ErrBlocked
+	}
+	return nil
+}
+
+// Start a conversation about a listing with its seller, or about an order
+// with its other party. Starting it again returns the existing one.
+func (s *Service) StartConversation(ctx context.Context, in messages.ConversationInput) (*messages.Conversation, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	c := &messages.Conversation{}
+	switch {
+	case in.OrderID != "":
+		o, err := orders.GetOrder(db, in.OrderID)
+		if err != nil {
+			return nil, err
+		}
+		if !o.IsParty(p.UserID) {
+			return nil, orders.ErrNotParty
+		}
+		c.ListingID, c.OrderID, c.BuyerID, c.SellerID = o.ListingID, &o.ID, o.BuyerID, o.SellerID
+	case in.ListingID != "":
+		l, err := listings.GetListing(db, in.ListingID)
+		if err != nil {
+			return nil, err
+		}
+		if l.SellerID == p.UserID {
+			return nil, messages.ErrOwnListing
+		}
+		if l.Status == listings.StatusDraft {
+			return nil, gorm.ErrRecordNotFound
+		}
+		c.ListingID, c.BuyerID, c.SellerID = l.ID, p.UserID, l.SellerID
+	default:
+		return nil, errors.New("listing_id or order_id is required")
+	}
+
+	if err := s.checkBlocked(db, c.BuyerID, c.SellerID); err != nil {
+		return nil, err
+	}
+	return messages.FindOrCreate(db, c)
+}
+
+func (s *Service) ListConversations(ctx context.Context, page, pageSize int) ([]messages.Conversation, int, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return messages.ListConversations(s.p.DB.WithContext(ctx), p.UserID, page, pageSize)
+}
+
+func (s *Service) GetConversation(ctx context.Context, id string) (*messages.Conversation, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return messages.GetConversation(s.p.DB.WithContext(ctx), p.UserID, id)
+}
+
+// Messages of one of the caller's conversations, newest first, older than
+// the before cursor when it is set
+func (s *Service) ListMessages(ctx context.Context, conversationID, before string, limit int) ([]messages.Message, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	if _, err := messages.GetConversation(db, p.UserID, conversationID); err != nil {
+		return nil, err
+	}
+	return messages.ListMessages(db, conversationID, before, limit)
+}
+
+// Sign a direct image upload to Cloudinary for one of the caller's
+// conversations. The image can then be attached to a message by its public
+// id.
+func (s *Service) CreateUploadTicket(ctx context.Context, conversationID string) (*messages.UploadTicket, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	c, err := messages.GetConversation(db, p.UserID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBlocked(db, c.BuyerID, c.SellerID); err != nil {
+		return nil, err
+	}
+
+	cloud := s.cld.Config.Cloud
+	ticket := &messages.UploadTicket{
+		URL:            fmt.Sprintf("%s/%s/image/upload", api.BaseURL(s.cld.Config.API.UploadPrefix, ""), cloud.CloudName),
+		APIKey:         cloud.APIKey,
+		PublicID:       messages.AttachmentFolder(c.ID) + "/" + uuid.NewString(),
+		Timestamp:      time.Now().Unix(),
+		AllowedFormats: attachmentFormats,
+	}
+	ticket.Signature, err = api.SignParameters(url.Values{
+		"public_id":       {ticket.PublicID},
+		"timestamp":       {strconv.FormatInt(ticket.Timestamp, 10)},
+		"allowed_formats": {ticket.AllowedFormats},
+	}, cloud.APISecret)
+	if err != nil {
+		return nil, fmt.Errorf("error signing upload: %v", err)
+	}
+	return ticket, nil
+}
+
+// Look up images uploaded to a conversation's folder in Cloudinary
+func (s *Service) attachments(ctx context.Context, conversationID string, publicIDs []string) ([]messages.Attachment, error) {
+	if len(publicIDs) > messages.MaxAttachments {
+		return nil, messages.ErrTooManyImages
+	}
+
+	attachments := make([]messages.Attachment, 0, len(publicIDs))
+	for _, id := range publicIDs {
+		if !strings.HasPrefix(id, messages.AttachmentFolder(conversationID)+"/") {
+			return nil, fmt.Errorf("%w: %s", messages.ErrInvalidAttachment, id)
+		}
+
+		asset, err := s.cld.Admin.Asset(ctx, admin.AssetParams{AssetType: api.Image, DeliveryType: api.Upload, PublicID: id})
+		if err != nil {
+			return nil, fmt.Errorf("error looking up attachment: %v", err)
+		}
+		if asset.Error.Message != "" || asset.SecureURL == "" {
+			return nil, fmt.Errorf("%w: %s", messages.ErrInvalidAttachment, id)
+		}
+		attachments = append(attachments, messages.Attachment{
+			PublicID: id,
+			URL:      asset.SecureURL,
+			Format:   asset.Format,
+			Width:    asset.Width,
+			Height:   asset.Height,
+			Bytes:    asset.Bytes,
+		})
+	}
+	return attachments, nil
+}
+
+// Send a message in one of the caller's conversations. Messages matching a
+// moderation check are still delivered, and flagged for moderators.
+func (s *Service) SendMessage(ctx context.Context, conversationID string, in messages.MessageInput) (*messages.Message, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body := strings.TrimSpace(in.Body)
+	if body == "" && len(in.Attachments) == 0 {
+		return nil, messages.ErrEmptyMessage
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	c, err := messages.GetConversation(db, p.UserID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBlocked(db, c.BuyerID, c.SellerID); err != nil {
+		return nil, err
+	}
+	attachments, err := s.attachments(ctx, c.ID, in.Attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &messages.Message{
+		ConversationID: c.ID,
+		SenderID:       p.UserID,
+		RecipientID:    c.Other(p.UserID),
+		Body:           body,
+		Attachments:    attachments,
+		FlagReasons:    messages.Moderate(s.moderation, body),
+	}
+	m.Flagged = len(m.FlagReasons) > 0
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Serializes messages of the conversation so last_message_at only
+		// moves forward
+		locked, err := messages.Lock(tx, c.ID)
+		if err != nil {
+			return err
+		}
+		return messages.CreateMessage(tx, locked, m)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, m.Event(messages.EventMessageCreated))
+	if m.Flagged {
+		flagged := &messages.FlaggedMessage{Message: *m, Flagged: true, FlagReasons: m.FlagReasons}
+		s.events.Publish(ctx, flagged.Event())
+		s.z.Warn().Str("message", m.ID).Str("sender", m.SenderID).Strs("reasons", m.FlagReasons).Msg("message flagged")
+	}
+	return m, nil
+}
+
+// Mark the messages the caller received in a conversation as read, sending
+// a receipt to the other participant
+func (s *Service) MarkConversationRead(ctx context.Context, conversationID string) (*messages.Receipt, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	c, err := messages.GetConversation(db, p.UserID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &messages.Receipt{ConversationID: c.ID, ReaderID: p.UserID, ReadAt: time.Now()}
+	if receipt.Count, err = messages.MarkRead(db, c.ID, p.UserID, receipt.ReadAt); err != nil {
+		return nil, err
+	}
+	if receipt.Count > 0 {
+		s.events.Publish(ctx, receipt.Event(c.Other(p.UserID)))
+	}
+	return receipt, nil
+}
+
+func (s *Service) ListBlocks(ctx context.Context) ([]messages.Block, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return messages.ListBlocks(s.p.DB.WithContext(ctx), p.UserID)
+}
+
+func (s *Service) BlockUser(ctx context.Context, userID string) (*messages.Block, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return messages.CreateBlock(s.p.DB.WithContext(ctx), p.UserID, userID)
+}
+
+func (s *Service) UnblockUser(ctx context.Context, userID string) error {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return err
+	}
+	return messages.DeleteBlock(s.p.DB.WithContext(ctx), p.UserID, userID)
+}
+
+// Messages flagged by moderation checks, for admins
+func (s *Service) ListFlaggedMessages(ctx context.Context, page, pageSize int) ([]messages.FlaggedMessage, int, error) {
+	return messages.ListFlagged(s.p.DB.WithContext(ctx), page, pageSize)
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"x/core/internal/auth"
+	"x/core/internal/events"
+	"x/core/internal/listings"
+	"x/core/internal/offers"
+	"x/core/internal/persist"
+
+	"gorm.io/gorm"
+)
+
+// Offers of the caller as buyer or seller
+type OfferRole string
+
+const (
+	OfferRoleBuyer  OfferRole = "buyer"
+	OfferRoleSeller OfferRole = "seller"
+)
+
+// Make an offer on an active listing as the buyer
+func (s *Service) MakeOffer(ctx context.Context, listingID string, in offers.OfferInput) (*offers.Offer, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var o *offers.Offer
+	err = s.p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Serializes with acceptance, which reserves the listing
+		l, err := listings.Lock(tx, listingID)
+		if err != nil {
+			return err
+		}
+		o, err = offers.Create(tx, l, p.UserID, in)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, o.Event(offers.EventCreated))
+	return o, nil
+}
+
+// Offer visible to the buyer and the seller only
+func (s *Service) GetOffer(ctx context.Context, id string) (*offers.Offer, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := offers.GetOffer(s.p.DB.WithContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if !o.IsParty(p.UserID) {
+		return nil, offers.ErrNotParty
+	}
+	return o, nil
+}
+
+// Offers on a listing, all of them for its seller and their own for buyers
+func (s *Service) ListListingOffers(ctx context.Context, listingID string) ([]offers.Offer, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	l, err := listings.GetListing(db, listingID)
+	if err != nil {
+		return nil, err
+	}
+
+	buyerID := p.UserID
+	if l.SellerID == p.UserID {
+		buyerID = ""
+	}
+	return offers.ListForListing(db, listingID, buyerID)
+}
+
+// Page of the caller's offers as buyer or seller, optionally of one status
+func (s *Service) ListOffers(ctx context.Context, role OfferRole, status offers.Status, page, pageSize int) ([]offers.Offer, int, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conditions := map[string]interface{}{"buyer_id": p.UserID}
+	if role == OfferRoleSeller {
+		conditions = map[string]interface{}{"seller_id": p.UserID}
+	}
+	if status != "" {
+		conditions["status"] = status
+	}
+	return persist.GetFilteredPaginatedRecords[offers.Offer](s.p.DB.WithContext(ctx), page, pageSize, conditions)
+}
+
+// Answer an offer made to the caller with a counter-offer
+func (s *Service) CounterOffer(ctx context.Context, id string, in offers.OfferInput) (*offers.Offer, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	current, err := offers.GetOffer(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var counter *offers.Offer
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Listing first, the lock order of AcceptOffer
+		l, err := listings.Lock(tx, current.ListingID)
+		if err != nil {
+			return err
+		}
+		o, err := offers.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+		counter, err = offers.Counter(tx, o, l, p.UserID, in)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, counter.Event(offers.EventCountered))
+	return counter, nil
+}
+
+// Accept an offer made to the caller. The listing is reserved for the buyer
+// and competing offers are declined in the same transaction.
+func (s *Service) AcceptOffer(ctx context.Context, id string) (*offers.Offer, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	current, err := offers.GetOffer(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var accepted *offers.Offer
+	var declined []offers.Offer
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Listing first, the lock order of every offer mutation on it
+		l, err := listings.Lock(tx, current.ListingID)
+		if err != nil {
+			return err
+		}
+		accepted, err = offers.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+		declined, err = offers.Accept(tx, accepted, l, listings.ActorFrom(p))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	published := []events.Event{accepted.Event(offers.EventAccepted)}
+	for i := range declined {
+		published = append(published, declined[i].Event(offers.EventDeclined))
+	}
+	s.events.Publish(ctx, published...)
+
+	s.z.Info().Str("offer", id).Str("listing", accepted.ListingID).Int("declined", len(declined)).Msg("offer accepted")
+	return accepted, nil
+}
+
+func (s *Service) DeclineOffer(ctx context.Context, id string) (*offers.Offer, error) {
+	return s.closeOffer(ctx, id, offers.Decline, offers.EventDeclined)
+}
+
+func (s *Service) WithdrawOffer(ctx context.Context, id string) (*offers.Offer, error) {
+	return s.closeOffer(ctx, id, offers.Withdraw, offers.EventWithdrawn)
+}
+
+func (s *Service) closeOffer(ctx context.Context, id string, answer func(*gorm.DB, *offers.Offer, string) error, event events.Type) (*offers.Offer, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var o *offers.Offer
+	err = s.p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		o, err = offers.Lock(tx, id)
+		if err != nil {
+			return err
+		}
+		return answer(tx, o, p.UserID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, o.Event(event))
+	return o, nil
+}
+
+// Expire pending offers past their expiry, returning how many were expired
+func (s *Service) ExpireOffers(ctx context.Context) (int, error) {
+	db := s.p.DB.WithContext(ctx)
+	now := time.Now()
+	ids, err := offers.ExpiredOfferIDs(db, now, expiryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var expired []events.Event
+	defer func() { s.events.Publish(ctx, expired...) }()
+
+	for _, id := range ids {
+		var o *offers.Offer
+		var ok bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			o, err = offers.Lock(tx, id)
+			if err != nil {
+				return err
+			}
+			ok, err = offers.Expire(tx, o, now)
+			return err
+		})
+		if err != nil {
+			return len(expired), fmt.Errorf("error expiring offer %s: %v", id, err)
+		}
+		if ok {
+			expired = append(expired, o.Event(offers.EventExpired))
+		}
+	}
+
+	if len(expired) > 0 {
+		s.z.Info().Int("count", len(expired)).Msg("offers expired")
+	}
+	return len(expired), nil
+}
@@ -0,0 +1,545 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"x/core/internal/auth"
+	"x/core/internal/events"
+	"x/core/internal/listings"
+	"x/core/internal/offers"
+	"x/core/internal/orders"
+	"x/core/internal/payments"
+	"x/core/internal/persist"
+	"x/core/internal/shipping"
+
+	"gorm.io/gorm"
+)
+
+// Orders of the caller as buyer or seller
+type OrderRole string
+
+const (
+	OrderRoleBuyer  OrderRole = "buyer"
+	OrderRoleSeller OrderRole = "seller"
+)
+
+// Resolutions of a disputed order
+type DisputeOutcome string
+
+const (
+	DisputeRelease DisputeOutcome = "release"
+	DisputeRefund  DisputeOutcome = "refund"
+)
+
+// Order statuses payment statuses reported by the provider move orders to
+var paymentStatuses = map[payments.Status]orders.Status{
+	payments.StatusAuthorized: orders.StatusAuthorized,
+	payments.StatusCaptured:   orders.StatusCaptured,
+	payments.StatusCanceled:   orders.StatusCanceled,
+	payments.StatusRefunded:   orders.StatusRefunded,
+	payments.StatusDisputed:   orders.StatusDisputed,
+}
+
+// Place an order for an accepted offer or at the listing price. The listing
+// is reserved for the buyer and a payment is created at the provider, to be
+// confirmed by the client with the returned secret.
+func (s *Service) PlaceOrder(ctx context.Context, in orders.OrderInput) (*orders.Order, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if (in.OfferID == "") == (in.ListingID == "") {
+		return nil, fmt.Errorf("%w: either an offer or a listing is required", orders.ErrNotPurchasable)
+	}
+	if in.DecantSizeML > 0 && in.ListingID == "" {
+		return nil, fmt.Errorf("%w: decants are ordered from a listing", orders.ErrNotPurchasable)
+	}
+
+	o := &orders.Order{BuyerID: p.UserID, Provider: s.payments.Name()}
+	err = s.p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		listingID := in.ListingID
+		var offer *offers.Offer
+		if in.OfferID != "" {
+			offer, err = offers.GetOffer(tx, in.OfferID)
+			if err != nil {
+				return err
+			}
+			if offer.BuyerID != p.UserID {
+				return offers.ErrNotParty
+			}
+			if offer.Status != offers.StatusAccepted {
+				return fmt.Errorf("%w: offer is %s", orders.ErrNotPurchasable, offer.Status)
+			}
+			listingID = offer.ListingID
+		}
+
+		l, err := listings.Lock(tx, listingID)
+		if err != nil {
+			return err
+		}
+
+		if offer != nil {
+			// Accepting the offer reserved the listing for the buyer
+			if l.Status != listings.StatusReserved {
+				return fmt.Errorf("%w: listing is %s", orders.ErrNotPurchasable, l.Status)
+			}
+			o.OfferID = &offer.ID
+			o.Subtotal = orders.MinorUnits(offer.Amount)
+		} else {
+			if l.SellerID == p.UserID {
+				return fmt.Errorf("%w: cannot buy your own listing", orders.ErrNotPurchasable)
+			}
+			if l.Status != listings.StatusActive {
+				return fmt.Errorf("%w: listing is %s", orders.ErrNotPurchasable, l.Status)
+			}
+			if err := s.reserve(tx, o, l, in, listings.ActorFrom(p)); err != nil {
+				return err
+			}
+		}
+
+		if o.ShippingAddressID, err = shippingAddress(tx, p.UserID, in.AddressID); err != nil {
+			return err
+		}
+
+		o.ListingID = l.ID
+		o.SellerID = l.SellerID
+		o.Currency = l.Currency
+		return orders.Create(tx, o, s.fees)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.events.Publish(ctx, o.Event(orders.EventCreated))
+
+	payment, err := s.payments.CreatePayment(ctx, payments.Intent{
+		Amount:      o.Total,
+		Currency:    o.Currency,
+		Reference:   o.ID,
+		Description: "Order " + o.ID,
+	})
+	if err != nil {
+		s.z.Error().Err(err).Str("order", o.ID).Msg("error creating payment")
+		if _, cancelErr := s.setOrderStatus(ctx, o.ID, orders.StatusCanceled, listings.System, nil); cancelErr != nil {
+			s.z.Error().Err(cancelErr).Str("order", o.ID).Msg("error canceling order")
+		}
+		return nil, fmt.Errorf("error creating payment: %v", err)
+	}
+	if err := orders.SetPayment(s.p.DB.WithContext(ctx), o, payment.ID); err != nil {
+		return nil, err
+	}
+
+	// Providers may authorize right away, e.g. the fake provider
+	if payment.Status != payments.StatusRequiresAction {
+		var published []events.Event
+		err := s.p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			published, err = s.applyPaymentStatus(ctx, tx, o.ID, payment.Status)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.events.Publish(ctx, published...)
+	}
+
+	placed, err := orders.GetOrder(s.p.DB.WithContext(ctx), o.ID)
+	if err != nil {
+		return nil, err
+	}
+	placed.ClientSecret = payment.ClientSecret
+
+	s.z.Info().Str("order", o.ID).Str("listing", o.ListingID).Int64("total", o.Total).Msg("order placed")
+	return placed, nil
+}
+
+// Address of the buyer an order ships to, the given one or their default
+func shippingAddress(tx *gorm.DB, buyerID, addressID string) (*string, error) {
+	if addressID == "" {
+		a, err := shipping.GetDefaultAddress(tx, buyerID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &a.ID, nil
+	}
+
+	a, err := shipping.GetAddress(tx, buyerID, addressID)
+	if err != nil {
+		return nil, fmt.Errorf("address %s: %w", addressID, err)
+	}
+	return &a.ID, nil
+}
+
+// Reserve a locked active listing for an order, the whole bottle or the
+// volume of the decants ordered from a split listing
+func (s *Service) reserve(tx *gorm.DB, o *orders.Order, l *listings.Listing, in orders.OrderInput, actor listings.Actor) error {
+	if in.DecantSizeML == 0 {
+		if l.Split {
+			return fmt.Errorf("%w: listing is sold as decants", orders.ErrNotPurchasable)
+		}
+		o.Subtotal = orders.MinorUnits(l.Price)
+		return listings.SetStatus(tx, l, listings.StatusReserved, actor, "order placed")
+	}
+
+	d, err := listings.GetDecant(tx, l.ID, in.DecantSizeML)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: no %d ml decants", orders.ErrNotPurchasable, in.DecantSizeML)
+	}
+	if err != nil {
+		return err
+	}
+
+	o.DecantSizeML = d.SizeML
+	o.Quantity = max(in.Quantity, 1)
+	o.Subtotal = orders.MinorUnits(d.Price * o.Quantity)
+	return listings.Allocate(tx, l, o.VolumeML(), actor)
+}
+
+// Order visible to the buyer and the seller only
+func (s *Service) GetOrder(ctx context.Context, id string) (*orders.Order, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := orders.GetOrder(s.p.DB.WithContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if !o.IsParty(p.UserID) && !p.IsAdmin() {
+		return nil, orders.ErrNotParty
+	}
+	return o, nil
+}
+
+// Page of the caller's orders as buyer or seller, optionally of one status
+func (s *Service) ListOrders(ctx context.Context, role OrderRole, status orders.Status, page, pageSize int) ([]orders.Order, int, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conditions := map[string]interface{}{"buyer_id": p.UserID}
+	if role == OrderRoleSeller {
+		conditions = map[string]interface{}{"seller_id": p.UserID}
+	}
+	if status != "" {
+		conditions["status"] = status
+	}
+	return persist.GetFilteredPaginatedRecords[orders.Order](s.p.DB.WithContext(ctx), page, pageSize, conditions)
+}
+
+// Move an order to another status in a transaction, keeping its listing in
+// step. The check runs on the locked order before anything is changed and
+// may call the provider, which is safe as provider calls are idempotent.
+func (s *Service) setOrderStatus(ctx context.Context, id string, to orders.Status, actor listings.Actor, check func(tx *gorm.DB, o *orders.Order) (map[string]interface{}, error)) (*orders.Order, error) {
+	var o *orders.Order
+	err := s.p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		o, err = s.transitionOrder(tx, id, to, actor, check)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if e, ok := o.StatusEvent(); ok {
+		s.events.Publish(ctx, e)
+	}
+	return o, nil
+}
+
+// Lock an order and move it to the status within the transaction, its event
+// is left to the caller to publish after commit
+func (s *Service) transitionOrder(tx *gorm.DB, id string, to orders.Status, actor listings.Actor, check func(tx *gorm.DB, o *orders.Order) (map[string]interface{}, error)) (*orders.Order, error) {
+	o, err := orders.Lock(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates map[string]interface{}
+	if check != nil {
+		if updates, err = check(tx, o); err != nil {
+			return nil, err
+		}
+	}
+	if err := orders.SetStatus(tx, o, to, updates); err != nil {
+		return nil, err
+	}
+	if err := s.syncListing(tx, o, actor); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Captured orders sell their listing, canceled ones make it available again
+func (s *Service) syncListing(tx *gorm.DB, o *orders.Order, actor listings.Actor) error {
+	if o.Status != orders.StatusCaptured && o.Status != orders.StatusCanceled {
+		return nil
+	}
+
+	l, err := listings.Lock(tx, o.ListingID)
+	if err != nil {
+		return err
+	}
+	if o.DecantSizeML > 0 {
+		return syncDecants(tx, o, l, actor)
+	}
+	if l.Status != listings.StatusReserved {
+		return nil
+	}
+
+	to := listings.StatusSold
+	if o.Status == orders.StatusCanceled {
+		to = listings.StatusActive
+	}
+	return listings.SetStatus(tx, l, to, actor, fmt.Sprintf("order %s %s", o.ID, o.Status))
+}
+
+// Canceled decant orders return their volume to the listing, a sold out
+// listing is sold once the last of its decant orders is paid for
+func syncDecants(tx *gorm.DB, o *orders.Order, l *listings.Listing, actor listings.Actor) error {
+	if o.Status == orders.StatusCanceled {
+		return listings.Release(tx, l, o.VolumeML(), actor)
+	}
+
+	unpaid, err := orders.CountUnpaidDecants(tx, l.ID)
+	if err != nil || unpaid > 0 {
+		return err
+	}
+	return listings.CloseSoldOut(tx, l, actor)
+}
+
+// Apply a payment status reported by the provider to the order it pays for
+// within the transaction, returning the events to publish after commit.
+// Statuses the order already reached or moved past are ignored, so delayed
+// and repeated reports are harmless. Authorized payments are captured right
+// away, the marketplace holds the funds until the buyer confirms delivery.
+func (s *Service) applyPaymentStatus(ctx context.Context, tx *gorm.DB, id string, status payments.Status) ([]events.Event, error) {
+	to, ok := paymentStatuses[status]
+	if !ok {
+		return nil, nil
+	}
+
+	o, err := s.transitionOrder(tx, id, to, listings.System, nil)
+	if errors.Is(err, orders.ErrInvalidTransition) {
+		s.z.Debug().Str("order", id).Str("payment_status", string(status)).Msg("stale payment status ignored")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var published []events.Event
+	if e, ok := o.StatusEvent(); ok {
+		published = append(published, e)
+	}
+	if o.Status != orders.StatusAuthorized {
+		return published, nil
+	}
+
+	if err := s.payments.Capture(ctx, *o.PaymentID); err != nil {
+		return nil, fmt.Errorf("error capturing payment of order %s: %v", o.ID, err)
+	}
+	captured, err := s.applyPaymentStatus(ctx, tx, o.ID, payments.StatusCaptured)
+	if err != nil {
+		return nil, err
+	}
+	return append(published, captured...), nil
+}
+
+// Process a payment webhook. Events are recorded in the transaction that
+// applies them, so redelivered events are acknowledged without effect and
+// events that failed to apply are processed again on redelivery.
+func (s *Service) HandlePaymentWebhook(ctx context.Context, payload []byte, header http.Header) error {
+	e, err := s.payments.ParseEvent(payload, header)
+	if errors.Is(err, payments.ErrUnknownEvent) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	provider := s.payments.Name()
+	var orderID string
+	var published []events.Event
+	err = s.p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		first, err := orders.RecordEvent(tx, provider, e)
+		if err != nil || !first {
+			return err
+		}
+		o, err := orders.LockByPayment(tx, provider, e.PaymentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.z.Warn().Str("payment", e.PaymentID).Str("event", e.Type).Msg("payment event for unknown order")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		orderID = o.ID
+		published, err = s.applyPaymentStatus(ctx, tx, o.ID, e.Status)
+		return err
+	})
+	if err != nil || orderID == "" {
+		return err
+	}
+
+	s.z.Info().Str("order", orderID).Str("event", e.Type).Str("payment_status", string(e.Status)).Msg("payment event applied")
+	s.events.Publish(ctx, published...)
+	return nil
+}
+
+// Cancel an order before its payment was captured, by the buyer or seller
+func (s *Service) CancelOrder(ctx context.Context, id string) (*orders.Order, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.setOrderStatus(ctx, id, orders.StatusCanceled, listings.ActorFrom(p), func(tx *gorm.DB, o *orders.Order) (map[string]interface{}, error) {
+		if !o.IsParty(p.UserID) {
+			return nil, orders.ErrNotParty
+		}
+		if !o.Status.CanTransition(orders.StatusCanceled) {
+			return nil, fmt.Errorf("%w: order is %s", orders.ErrInvalidTransition, o.Status)
+		}
+		if o.PaymentID != nil {
+			if err := s.payments.Cancel(ctx, *o.PaymentID); err != nil {
+				return nil, fmt.Errorf("error canceling payment: %v", err)
+			}
+		}
+		return nil, nil
+	})
+}
+
+// Confirm delivery as the buyer, releasing the held funds to the seller
+func (s *Service) ConfirmDelivery(ctx context.Context, id string) (*orders.Order, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.setOrderStatus(ctx, id, orders.StatusReleased, listings.ActorFrom(p), func(tx *gorm.DB, o *orders.Order) (map[string]interface{}, error) {
+		if o.BuyerID != p.UserID {
+			return nil, orders.ErrNotParty
+		}
+		return s.release(ctx, tx, o)
+	})
+}
+
+// Pay the seller's share of a captured or disputed order out to their account
+func (s *Service) release(ctx context.Context, tx *gorm.DB, o *orders.Order) (map[string]interface{}, error) {
+	if !o.Status.CanTransition(orders.StatusReleased) {
+		return nil, fmt.Errorf("%w: order is %s", orders.ErrInvalidTransition, o.Status)
+	}
+
+	account, err := orders.GetPayoutAccount(tx, o.SellerID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, orders.ErrNoPayoutAccount
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	transferID, err := s.payments.Release(ctx, payments.Payout{
+		PaymentID:   *o.PaymentID,
+		Destination: account.AccountID,
+		Amount:      o.SellerPayout,
+		Currency:    o.Currency,
+		Reference:   o.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error releasing funds: %v", err)
+	}
+	return map[string]interface{}{"transfer_id": transferID}, nil
+}
+
+// Refund the buyer of a captured or disputed order in full
+func (s *Service) refund(ctx context.Context, o *orders.Order) (map[string]interface{}, error) {
+	if !o.Status.CanTransition(orders.StatusRefunded) {
+		return nil, fmt.Errorf("%w: order is %s", orders.ErrInvalidTransition, o.Status)
+	}
+	if err := s.payments.Refund(ctx, *o.PaymentID, o.ID); err != nil {
+		return nil, fmt.Errorf("error refunding payment: %v", err)
+	}
+	return nil, nil
+}
+
+// Dispute a captured order as the buyer, holding the funds until resolved
+func (s *Service) DisputeOrder(ctx context.Context, id string, reason string) (*orders.Order, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.setOrderStatus(ctx, id, orders.StatusDisputed, listings.ActorFrom(p), func(tx *gorm.DB, o *orders.Order) (map[string]interface{}, error) {
+		if o.BuyerID != p.UserID {
+			return nil, orders.ErrNotParty
+		}
+		return map[string]interface{}{"dispute_reason": reason}, nil
+	})
+}
+
+// Refund a captured or disputed order, an admin operation
+func (s *Service) RefundOrder(ctx context.Context, id string) (*orders.Order, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.setOrderStatus(ctx, id, orders.StatusRefunded, listings.ActorFrom(p), func(tx *gorm.DB, o *orders.Order) (map[string]interface{}, error) {
+		return s.refund(ctx, o)
+	})
+}
+
+// Settle a disputed order by releasing the funds or refunding the buyer, an
+// admin operation
+func (s *Service) ResolveDispute(ctx context.Context, id string, outcome DisputeOutcome) (*orders.Order, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	check := func(tx *gorm.DB, o *orders.Order) (map[string]interface{}, error) {
+		if o.Status != orders.StatusDisputed {
+			return nil, fmt.Errorf("%w: order is %s", orders.ErrInvalidTransition, o.Status)
+		}
+		if outcome == DisputeRelease {
+			return s.release(ctx, tx, o)
+		}
+		return s.refund(ctx, o)
+	}
+
+	to := orders.StatusRefunded
+	if outcome == DisputeRelease {
+		to = orders.StatusReleased
+	}
+	o, err := s.setOrderStatus(ctx, id, to, listings.ActorFrom(p), check)
+	if err != nil {
+		return nil, err
+	}
+
+	s.z.Info().Str("order", id).Str("outcome", string(outcome)).Msg("order dispute resolved")
+	return o, nil
+}
+
+func (s *Service) GetPayoutAccount(ctx context.Context) (*orders.PayoutAccount, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return orders.GetPayoutAccount(s.p.DB.WithContext(ctx), p.UserID)
+}
+
+// Set the account at the payment provider the caller's sales are paid to
+func (s *Service) SavePayoutAccount(ctx context.Context, accountID string) (*orders.PayoutAccount, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return orders.SavePayoutAccount(s.p.DB.WithContext(ctx), p.UserID, s.payments.Name(), accountID)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"x/core/internal/auth"
+	"x/core/internal/orders"
+	"x/core/internal/persist"
+	"x/core/internal/reviews"
+)
+
+// Review a completed order as its buyer or seller
+func (s *Service) CreateReview(ctx context.Context, orderID string, in reviews.ReviewInput, policy reviews.Policy) (*reviews.Review, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	o, err := orders.GetOrder(db, orderID)
+	if err != nil {
+		return nil, err
+	}
+	r, err := reviews.Create(db, o, p.UserID, in, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, r.Event(reviews.EventCreated))
+	s.z.Info().Str("order", orderID).Str("role", string(r.Role)).Int("rating", r.Rating).Msg("order reviewed")
+	return r, nil
+}
+
+// Replace one of the caller's reviews while it is editable and still at the
+// expected version
+func (s *Service) UpdateReview(ctx context.Context, id string, version int64, in reviews.ReviewInput) (*reviews.Review, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := reviews.Update(s.p.DB.WithContext(ctx), p.UserID, id, version, in)
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, r.Event(reviews.EventUpdated))
+	return r, nil
+}
+
+// Reviews of an order, visible to the buyer and the seller
+func (s *Service) ListOrderReviews(ctx context.Context, orderID string) ([]reviews.Review, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	o, err := orders.GetOrder(db, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !o.IsParty(p.UserID) && !p.IsAdmin() {
+		return nil, orders.ErrNotParty
+	}
+	return reviews.ListByOrder(db, orderID)
+}
+
+// Reviews a user received, newest first, optionally only those received as
+// the seller or the buyer of orders
+func (s *Service) ListUserReviews(ctx context.Context, userID string, as OrderRole, page, pageSize int) ([]reviews.Review, int, error) {
+	conditions := map[string]interface{}{"reviewee_id": userID}
+	switch as {
+	case OrderRoleSeller:
+		conditions["role"] = reviews.RoleBuyer
+	case OrderRoleBuyer:
+		conditions["role"] = reviews.RoleSeller
+	}
+	db := s.p.DB.WithContext(ctx).Order("created_at DESC")
+	return persist.GetFilteredPaginatedRecords[reviews.Review](db, page, pageSize, conditions)
+}
+
+func (s *Service) GetReputation(ctx context.Context, userID string, policy reviews.Policy) (*reviews.Reputation, error) {
+	return reviews.GetReputation(s.p.DB.WithContext(ctx), userID, policy.HalfLife)
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"x/core/internal/search"
+)
+
+// Listings searchable by fragrance name, brand and notes
+var ListingsIndex = search.Index{
+	Table: "listings",
+	Fields: []search.Field{
+		{Column: "name", Weight: search.WeightA},
+		{Column: "brand", Weight: search.WeightB},
+		{Column: "notes", Weight: search.WeightC},
+		{Column: "description", Weight: search.WeightD},
+	},
+	TrigramColumns: []string{"name", "brand"},
+	SnippetColumn:  "description",
+	Facets:         []string{"brand", "concentration", "currency"},
+	PriceColumn:    "price",
+	CurrencyColumn: "currency",
+	PriceBuckets: []search.PriceBucket{
+		{Label: "0-50", Min: 0, Max: 50},
+		{Label: "50-100", Min: 50, Max: 100},
+		{Label: "100-200", Min: 100, Max: 200},
+		{Label: "200-500", Min: 200, Max: 500},
+		{Label: "500+", Min: 500},
+	},
+	Scope:          map[string]interface{}{"status": "active"},
+	ExcludeDeleted: true,
+}
+
+func (s *Service) SearchListings(ctx context.Context, q search.Query) (*search.Result, error) {
+	return search.Search(s.p.DB.WithContext(ctx), ListingsIndex, q)
+}
@@ -1,26 +1,54 @@
 package service
 
 import (
+	"x/core/internal/events"
+	"x/core/internal/flags"
+	"x/core/internal/messages"
+	"x/core/internal/orders"
+	"x/core/internal/payments"
 	"x/core/internal/persist"
+	"x/core/internal/shipping"
 
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/rs/zerolog"
 )
 
 type Service struct {
-	p   *persist.PGStore
-	z   *zerolog.Logger
-	cld *cloudinary.Cloudinary
+	p      *persist.PGStore
+	z      *zerolog.Logger
+	cld    *cloudinary.Cloudinary
+	flags  *flags.Registry
+	events *events.Bus
+
+	payments payments.Provider
+	fees     orders.FeePolicy
+	shipping shipping.Provider
+
+	// Checks every message is moderated with
+	moderation []messages.Check
 }
 
 func NewService(
 	store *persist.PGStore,
 	logger *zerolog.Logger,
 	cloud *cloudinary.Cloudinary,
+	flagRegistry *flags.Registry,
+	bus *events.Bus,
+	provider payments.Provider,
+	fees orders.FeePolicy,
+	carrier shipping.Provider,
 ) *Service {
 	return &Service{
-		p:   store,
-		z:   logger,
-		cld: cloud,
+		p:      store,
+		z:      logger,
+		cld:    cloud,
+		flags:  flagRegistry,
+		events: bus,
+
+		payments: provider,
+		fees:     fees,
+		shipping: carrier,
+
+		moderation: append([]messages.Check{}, messages.DefaultChecks...),
 	}
 }
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	"x/core/internal/auth"
+	"x/core/internal/listings"
+	"x/core/internal/orders"
+	"x/core/internal/persist"
+	"x/core/internal/shipping"
+
+	"gorm.io/gorm"
+)
+
+const trackingBatchSize = 100
+
+func (s *Service) ListAddresses(ctx context.Context) ([]shipping.Address, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return shipping.ListAddresses(s.p.DB.WithContext(ctx), p.UserID)
+}
+
+func (s *Service) CreateAddress(ctx context.Context, in shipping.AddressInput) (*shipping.Address, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return shipping.CreateAddress(s.p.DB.WithContext(ctx), p.UserID, in)
+}
+
+// Replace one of the caller's addresses if it is still at the expected
+// version
+func (s *Service) UpdateAddress(ctx context.Context, id string, version int64, in shipping.AddressInput) (*shipping.Address, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return shipping.UpdateAddress(s.p.DB.WithContext(ctx), p.UserID, id, version, in)
+}
+
+func (s *Service) DeleteAddress(ctx context.Context, id string) error {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return err
+	}
+	return shipping.DeleteAddress(s.p.DB.WithContext(ctx), p.UserID, id)
+}
+
+// Parcel of a paid order of the caller's, from the seller's default address
+// to the address the buyer chose
+func orderParcel(db *gorm.DB, o *orders.Order, sellerID string) (shipping.Parcel, error) {
+	if o.SellerID != sellerID {
+		return shipping.Parcel{}, orders.ErrNotParty
+	}
+	if o.Status != orders.StatusCaptured {
+		return shipping.Parcel{}, fmt.Errorf("%w: order is %s, not paid", orders.ErrInvalidTransition, o.Status)
+	}
+
+	from, err := shipping.GetDefaultAddress(db, o.SellerID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return shipping.Parcel{}, fmt.Errorf("%w: seller has no default address", shipping.ErrNoAddress)
+	}
+	if err != nil {
+		return shipping.Parcel{}, err
+	}
+
+	// Orders keep their address if the buyer deletes it afterwards
+	var to *shipping.Address
+	if o.ShippingAddressID != nil {
+		to, err = shipping.GetAddress(db.Unscoped(), o.BuyerID, *o.ShippingAddressID)
+	} else {
+		to, err = shipping.GetDefaultAddress(db, o.BuyerID)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return shipping.Parcel{}, fmt.Errorf("%w: buyer has no address", shipping.ErrNoAddress)
+	}
+	if err != nil {
+		return shipping.Parcel{}, err
+	}
+
+	weight := shipping.EstimateWeight(o.DecantSizeML, o.Quantity)
+	if o.DecantSizeML == 0 {
+		l, err := listings.GetListing(db, o.ListingID)
+		if err != nil {
+			return shipping.Parcel{}, err
+		}
+		weight = shipping.EstimateWeight(l.BottleSizeML, 1)
+	}
+
+	return shipping.Parcel{
+		From:      *from,
+		To:        *to,
+		WeightG:   weight,
+		Hazmat:    shipping.FragranceHazmat,
+		Reference: o.ID,
+		Currency:  o.Currency,
+	}, nil
+}
+
+// Rates the parcel of an order can ship with. Labels are paid out of the
+// seller's payout, so only rates in the order currency are offered.
+func (s *Service) quote(ctx context.Context, parcel shipping.Parcel) ([]shipping.Rate, error) {
+	rates, err := s.shipping.Quote(ctx, parcel)
+	if err != nil {
+		return nil, fmt.Errorf("error quoting shipping: %v", err)
+	}
+	rates = slices.DeleteFunc(rates, func(r shipping.Rate) bool {
+		return r.Currency != parcel.Currency
+	})
+	return shipping.Allowed(parcel, rates)
+}
+
+// Shipping rates for a paid order, quoted to its seller
+func (s *Service) QuoteShipping(ctx context.Context, orderID string) ([]shipping.Rate, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	o, err := orders.GetOrder(db, orderID)
+	if err != nil {
+		return nil, err
+	}
+	parcel, err := orderParcel(db, o, p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return s.quote(ctx, parcel)
+}
+
+// Buy the label of a quoted rate for a paid order as its seller. Rates are
+// quoted again so only rates still allowed for the parcel can be bought. The
+// seller pays for the label, its cost is deducted from their payout.
+func (s *Service) ShipOrder(ctx context.Context, orderID string, in shipping.ShipmentInput) (*shipping.Shipment, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var shipment *shipping.Shipment
+	err = s.p.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Serializes label purchases for the order
+		o, err := orders.Lock(tx, orderID)
+		if err != nil {
+			return err
+		}
+		if _, err := shipping.GetShipmentByOrder(tx, o.ID); err == nil {
+			return shipping.ErrAlreadyShipped
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		parcel, err := orderParcel(tx, o, p.UserID)
+		if err != nil {
+			return err
+		}
+		rates, err := s.quote(ctx, parcel)
+		if err != nil {
+			return err
+		}
+		var rate *shipping.Rate
+		for i := range rates {
+			if rates[i].ID == in.RateID {
+				rate = &rates[i]
+			}
+		}
+		if rate == nil {
+			return fmt.Errorf("%w: %s", shipping.ErrRateUnavailable, in.RateID)
+		}
+		if rate.Amount > o.SellerPayout {
+			return fmt.Errorf("%w: %s costs more than the seller payout", shipping.ErrRateUnavailable, in.RateID)
+		}
+
+		// The parcel reference is the order ID, the provider's idempotency
+		// key. A retry after this transaction failed gets the label already
+		// bought for the order instead of a second one.
+		label, err := s.shipping.CreateLabel(ctx, parcel, rate.ID)
+		if err != nil {
+			return fmt.Errorf("error creating shipping label: %v", err)
+		}
+		if err := orders.ChargeShipping(tx, o, rate.Amount); err != nil {
+			return err
+		}
+
+		shipment = &shipping.Shipment{
+			OrderID:        o.ID,
+			BuyerID:        o.BuyerID,
+			SellerID:       o.SellerID,
+			Provider:       s.shipping.Name(),
+			Carrier:        label.Carrier,
+			Service:        label.Service,
+			Cost:           rate.Amount,
+			Currency:       rate.Currency,
+			Hazmat:         parcel.Hazmat,
+			WeightG:        parcel.WeightG,
+			TrackingNumber: label.TrackingNumber,
+			LabelURL:       label.URL,
+			Status:         shipping.StatusLabelCreated,
+			From:           parcel.From,
+			To:             parcel.To,
+		}
+		return tx.Create(shipment).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, shipment.Event(shipping.EventCreated))
+	s.z.Info().Str("order", orderID).Str("tracking", shipment.TrackingNumber).Msg("order shipped")
+	return shipment, nil
+}
+
+// Shipment of an order, visible to the buyer and the seller
+func (s *Service) GetShipment(ctx context.Context, orderID string) (*shipping.Shipment, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	shipment, err := shipping.GetShipmentByOrder(s.p.DB.WithContext(ctx), orderID)
+	if err != nil {
+		return nil, err
+	}
+	if p.UserID != shipment.BuyerID && p.UserID != shipment.SellerID && !p.IsAdmin() {
+		return nil, orders.ErrNotParty
+	}
+	return shipment, nil
+}
+
+// Poll the carrier for shipments in progress not checked for the interval,
+// returning how many changed status. Carrier errors are logged and the
+// shipment is checked again on the next run.
+func (s *Service) TrackShipments(ctx context.Context, interval time.Duration) (int, error) {
+	db := s.p.DB.WithContext(ctx)
+	ids, err := shipping.StaleShipmentIDs(db, time.Now().Add(-interval), trackingBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, id := range ids {
+		// The carrier is asked outside the transaction, the status is applied
+		// to the locked row
+		current, err := persist.GetRecordByID[shipping.Shipment](db, id)
+		if err != nil {
+			return updated, err
+		}
+		t, err := s.shipping.Track(ctx, current.Carrier, current.TrackingNumber)
+		if err != nil {
+			s.z.Warn().Err(err).Str("shipment", id).Msg("error tracking shipment")
+			continue
+		}
+
+		var shipment *shipping.Shipment
+		changed := false
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if shipment, err = shipping.Lock(tx, id); err != nil {
+				return err
+			}
+			changed, err = shipping.SetTracking(tx, shipment, t)
+			return err
+		})
+		if err != nil {
+			return updated, fmt.Errorf("error tracking shipment %s: %v", id, err)
+		}
+
+		if changed {
+			updated++
+			s.events.Publish(ctx, shipment.Event(shipping.EventUpdated))
+		}
+	}
+
+	if updated > 0 {
+		s.z.Info().Int("count", updated).Msg("shipments updated")
+	}
+	return updated, nil
+}
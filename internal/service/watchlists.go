@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"x/core/internal/auth"
+	"x/core/internal/catalog"
+	"x/core/internal/listings"
+	"x/core/internal/metrics"
+	"x/core/internal/watchlists"
+
+	"gorm.io/gorm"
+)
+
+const (
+	matchBatchSize       = 200
+	savedSearchBatchSize = 500
+	// Listing updates younger than this are left to the next run, so updates
+	// committed after a later one aren't skipped
+	matchSettleDelay = 5 * time.Second
+)
+
+func (s *Service) ListWatches(ctx context.Context, page, pageSize int) ([]watchlists.Watch, int, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return watchlists.ListWatches(s.p.DB.WithContext(ctx), p.UserID, page, pageSize)
+}
+
+// Watch a fragrance or an active listing. Watching it again replaces the
+// price limit.
+func (s *Service) SaveWatch(ctx context.Context, in watchlists.WatchInput) (*watchlists.Watch, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.p.DB.WithContext(ctx)
+	var l *listings.Listing
+	switch {
+	case in.ListingID != "":
+		if l, err = s.GetListing(ctx, in.ListingID); err != nil {
+			return nil, err
+		}
+	case in.FragranceID != "":
+		if _, err := catalog.GetFragrance(db, in.FragranceID); err != nil {
+			return nil, fmt.Errorf("fragrance %s: %w", in.FragranceID, err)
+		}
+	}
+
+	w, err := watchlists.NewWatch(p.UserID, in, l)
+	if err != nil {
+		return nil, err
+	}
+	return watchlists.SaveWatch(db, w)
+}
+
+func (s *Service) DeleteWatch(ctx context.Context, id string) error {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return err
+	}
+	return watchlists.DeleteWatch(s.p.DB.WithContext(ctx), p.UserID, id)
+}
+
+func (s *Service) ListSavedSearches(ctx context.Context) ([]watchlists.SavedSearch, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return watchlists.ListSavedSearches(s.p.DB.WithContext(ctx), p.UserID)
+}
+
+// Save the filters of a listing list to be alerted of new results
+func (s *Service) CreateSavedSearch(ctx context.Context, in watchlists.SavedSearchInput) (*watchlists.SavedSearch, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := in.Filters.Validate(ListingFilters); err != nil {
+		return nil, err
+	}
+	return watchlists.CreateSavedSearch(s.p.DB.WithContext(ctx), p.UserID, in)
+}
+
+// Replace one of the caller's saved searches if it is still at the expected
+// version
+func (s *Service) UpdateSavedSearch(ctx context.Context, id string, version int64, in watchlists.SavedSearchInput) (*watchlists.SavedSearch, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := in.Filters.Validate(ListingFilters); err != nil {
+		return nil, err
+	}
+	return watchlists.UpdateSavedSearch(s.p.DB.WithContext(ctx), p.UserID, id, version, in)
+}
+
+func (s *Service) DeleteSavedSearch(ctx context.Context, id string) error {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return err
+	}
+	return watchlists.DeleteSavedSearch(s.p.DB.WithContext(ctx), p.UserID, id)
+}
+
+func (s *Service) ListAlerts(ctx context.Context, page, pageSize int) ([]watchlists.Alert, int, error) {
+	p, err := auth.Require(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return watchlists.ListAlerts(s.p.DB.WithContext(ctx), p.UserID, page, pageSize)
+}
+
+// Match active listings updated after the cursor against watches and saved
+// searches, alerting their users. Returns the cursor to continue from and
+// how many alerts were raised.
+func (s *Service) MatchListings(ctx context.Context, cursor watchlists.Cursor, limit watchlists.RateLimit) (watchlists.Cursor, int, error) {
+	db := s.p.DB.WithContext(ctx)
+	a := &alerter{
+		s:        s,
+		db:       db,
+		limit:    limit,
+		since:    time.Now().Add(-limit.Window),
+		seen:     make(map[string]bool),
+		notified: make(map[string]int),
+	}
+
+	until := time.Now().Add(-matchSettleDelay)
+	for {
+		batch, err := listings.UpdatedSince(db, cursor.UpdatedAt, cursor.ID, until, matchBatchSize)
+		if err != nil {
+			return cursor, a.raised, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		if err := a.matchWatches(ctx, batch); err != nil {
+			return cursor, a.raised, err
+		}
+		if err := a.matchSearches(ctx, batch); err != nil {
+			return cursor, a.raised, err
+		}
+
+		last := batch[len(batch)-1]
+		cursor = watchlists.Cursor{UpdatedAt: last.UpdatedAt, ID: last.ID}
+		if len(batch) < matchBatchSize {
+			break
+		}
+	}
+
+	if a.raised > 0 {
+		s.z.Info().Int("count", a.raised).Msg("watchlist alerts raised")
+	}
+	return cursor, a.raised, nil
+}
+
+// alerter raises the alerts of a matcher run, at most one per user and
+// listing, notifying users until they reach their rate limit
+type alerter struct {
+	s     *Service
+	db    *gorm.DB
+	limit watchlists.RateLimit
+	since time.Time
+
+	seen     map[string]bool
+	notified map[string]int
+	raised   int
+
+	// Saved searches, loaded once per run on the first batch of listings
+	searches []watchlists.SavedSearch
+	loaded   bool
+}
+
+func (a *alerter) matchWatches(ctx context.Context, batch []listings.Listing) error {
+	for i := range batch {
+		l := &batch[i]
+		watches, err := watchlists.WatchesFor(a.db, l)
+		if err != nil {
+			return err
+		}
+		for _, w := range watches {
+			if !w.Accepts(l.Price, l.Currency) {
+				continue
+			}
+			// Listing watches only alert of drops from the watched price
+			if err := a.raise(ctx, l, w.UserID, watchlists.SourceWatch, w.ID, w.Price); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *alerter) matchSearches(ctx context.Context, batch []listings.Listing) error {
+	if !a.loaded {
+		err := watchlists.EachSavedSearchBatch(a.db, savedSearchBatchSize, func(searches []watchlists.SavedSearch) error {
+			a.searches = append(a.searches, searches...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		a.loaded = true
+	}
+
+	for _, search := range a.searches {
+		for i := range batch {
+			l := &batch[i]
+			if l.SellerID == search.UserID || !search.Filters.Matches(l) {
+				continue
+			}
+			if err := a.raise(ctx, l, search.UserID, watchlists.SourceSearch, search.ID, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Alert the user of the listing unless it isn't cheaper than the price the
+// source last alerted of, or than the starting price when given
+func (a *alerter) raise(ctx context.Context, l *listings.Listing, userID string, source watchlists.Source, sourceID string, previous *int) error {
+	key := userID + "/" + l.ID
+	if a.seen[key] {
+		return nil
+	}
+
+	last, err := watchlists.LastAlertedPrice(a.db, sourceID, l.ID)
+	if err != nil {
+		return err
+	}
+	if last != nil && (previous == nil || *last < *previous) {
+		previous = last
+	}
+	if previous != nil && l.Price >= *previous {
+		return nil
+	}
+
+	notify, err := a.allow(userID)
+	if err != nil {
+		return err
+	}
+
+	alert := &watchlists.Alert{
+		UserID:    userID,
+		Source:    source,
+		SourceID:  sourceID,
+		ListingID: l.ID,
+		Name:      l.Name,
+		Price:     l.Price,
+		Currency:  l.Currency,
+		Previous:  previous,
+		Notified:  notify,
+	}
+	created, err := watchlists.CreateAlert(a.db, alert)
+	if err != nil || !created {
+		return err
+	}
+	a.seen[key] = true
+	a.raised++
+
+	if !notify {
+		metrics.Alerts.WithLabelValues("rate_limited").Inc()
+		return nil
+	}
+	a.notified[userID]++
+	metrics.Alerts.WithLabelValues("notified").Inc()
+	a.s.events.Publish(ctx, alert.Event())
+	return nil
+}
+
+// Whether the user is still under their rate limit, counting the alerts
+// they were notified of during the window once per run
+func (a *alerter) allow(userID string) (bool, error) {
+	count, ok := a.notified[userID]
+	if !ok {
+		var err error
+		if count, err = watchlists.CountNotified(a.db, userID, a.since); err != nil {
+			return false, err
+		}
+		a.notified[userID] = count
+	}
+	return count < a.limit.Max, nil
+}
@@ -0,0 +1,192 @@
+package shipping
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const carrierRequestTimeout = 15 * time.Second
+
+// Carrier is an adapter for multi-carrier shipping APIs exposing rates,
+// labels and tracking as JSON resources:
+//
+//	POST {base}/rates                    parcel -> {"rates": [...]}
+//	POST {base}/labels                   parcel and rate_id -> label
+//	GET  {base}/tracking/{carrier}/{no}  -> {"status": ..., "detail": ...}
+type Carrier struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+func NewCarrier(baseURL, apiKey string) *Carrier {
+	return &Carrier{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: carrierRequestTimeout},
+	}
+}
+
+func (c *Carrier) Name() string {
+	return "carrier"
+}
+
+type carrierAddress struct {
+	Name       string `json:"name"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	Phone      string `json:"phone,omitempty"`
+}
+
+func toCarrierAddress(a Address) carrierAddress {
+	return carrierAddress{
+		Name:       a.Name,
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		Region:     a.Region,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+		Phone:      a.Phone,
+	}
+}
+
+type carrierParcel struct {
+	From    carrierAddress `json:"from"`
+	To      carrierAddress `json:"to"`
+	WeightG int            `json:"weight_g"`
+	// Declared as limited quantity UN1266 so carriers route it by ground
+	Hazmat    bool   `json:"hazmat"`
+	HazmatID  string `json:"hazmat_id,omitempty"`
+	Reference string `json:"reference"`
+	Currency  string `json:"currency,omitempty"`
+	RateID    string `json:"rate_id,omitempty"`
+}
+
+func toCarrierParcel(p Parcel) carrierParcel {
+	cp := carrierParcel{
+		From:      toCarrierAddress(p.From),
+		To:        toCarrierAddress(p.To),
+		WeightG:   p.WeightG,
+		Hazmat:    p.Hazmat,
+		Reference: p.Reference,
+		Currency:  p.Currency,
+	}
+	if p.Hazmat {
+		cp.HazmatID = "UN1266"
+	}
+	return cp
+}
+
+// Send a request to the API, decoding the response into v
+func (c *Carrier) do(ctx context.Context, method, path, idempotencyKey string, body, v any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling carrier %s: %v", path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: carrier %s not found", ErrUnknownShipment, path)
+	}
+	if res.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Message == "" {
+			return fmt.Errorf("carrier %s failed with status %d", path, res.StatusCode)
+		}
+		return fmt.Errorf("carrier %s failed: %s", path, e.Message)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (c *Carrier) Quote(ctx context.Context, p Parcel) ([]Rate, error) {
+	var res struct {
+		Rates []Rate `json:"rates"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/rates", "", toCarrierParcel(p), &res); err != nil {
+		return nil, err
+	}
+	return res.Rates, nil
+}
+
+func (c *Carrier) CreateLabel(ctx context.Context, p Parcel, rateID string) (*Label, error) {
+	req := toCarrierParcel(p)
+	req.RateID = rateID
+
+	var res struct {
+		Carrier        string `json:"carrier"`
+		Service        string `json:"service"`
+		TrackingNumber string `json:"tracking_number"`
+		LabelURL       string `json:"label_url"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/labels", "label-"+p.Reference, req, &res); err != nil {
+		return nil, err
+	}
+	return &Label{Carrier: res.Carrier, Service: res.Service, TrackingNumber: res.TrackingNumber, URL: res.LabelURL}, nil
+}
+
+// Carrier statuses mapped onto shipment statuses, anything else is in transit
+var carrierStatuses = map[string]Status{
+	"pre_transit":      StatusLabelCreated,
+	"label_created":    StatusLabelCreated,
+	"in_transit":       StatusInTransit,
+	"out_for_delivery": StatusOutForDelivery,
+	"delivered":        StatusDelivered,
+	"failure":          StatusException,
+	"exception":        StatusException,
+	"return_to_sender": StatusReturned,
+	"returned":         StatusReturned,
+}
+
+func (c *Carrier) Track(ctx context.Context, carrier, trackingNumber string) (*Tracking, error) {
+	var res struct {
+		Status string `json:"status"`
+		Detail string `json:"detail"`
+	}
+	path := "/tracking/" + url.PathEscape(carrier) + "/" + url.PathEscape(trackingNumber)
+	if err := c.do(ctx, http.MethodGet, path, "", nil, &res); err != nil {
+		return nil, err
+	}
+
+	status, ok := carrierStatuses[res.Status]
+	if !ok {
+		status = StatusInTransit
+	}
+	return &Tracking{Status: status, Detail: res.Detail}, nil
+}
@@ -0,0 +1,71 @@
+package shipping
+
+import "context"
+
+// Parcel to quote or ship between two addresses
+type Parcel struct {
+	From    Address
+	To      Address
+	WeightG int
+	// Flammable contents, carriers only accept them on ground services
+	Hazmat bool
+	// Reference of the parcel on our side, used as idempotency key
+	Reference string
+	// Currency of the order, rates are quoted in it
+	Currency string
+}
+
+// Rate quoted by a carrier for a parcel
+type Rate struct {
+	ID      string `json:"id"`
+	Carrier string `json:"carrier"`
+	Service string `json:"service"`
+	// Minor currency units
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	EstimatedDays int    `json:"estimated_days"`
+	// Air services don't accept hazardous goods
+	Air bool `json:"air"`
+}
+
+// Label bought for a quoted rate
+type Label struct {
+	Carrier        string
+	Service        string
+	TrackingNumber string
+	URL            string
+}
+
+// Tracking status of a parcel at the carrier
+type Tracking struct {
+	Status Status
+	Detail string
+}
+
+// Provider quotes rates, buys labels and tracks parcels. Implementations must
+// be safe to retry with the same parcel reference.
+type Provider interface {
+	Name() string
+	Quote(ctx context.Context, p Parcel) ([]Rate, error)
+	CreateLabel(ctx context.Context, p Parcel, rateID string) (*Label, error)
+	Track(ctx context.Context, carrier, trackingNumber string) (*Tracking, error)
+}
+
+// Rates a parcel can ship with, hazardous parcels only travel by ground on
+// routes that accept them
+func Allowed(p Parcel, rates []Rate) ([]Rate, error) {
+	if !p.Hazmat {
+		return rates, nil
+	}
+	if !HazmatRoute(p.From.Country, p.To.Country) {
+		return nil, ErrHazmatRestricted
+	}
+
+	allowed := make([]Rate, 0, len(rates))
+	for _, r := range rates {
+		if !r.Air {
+			allowed = append(allowed, r)
+		}
+	}
+	return allowed, nil
+}
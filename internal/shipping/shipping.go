@@ -0,0 +1,217 @@
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"x/core/internal/events"
+	"x/core/internal/persist"
+)
+
+var (
+	ErrInvalidAddress     = errors.New("invalid address")
+	ErrUnsupportedCountry = errors.New("country is not supported")
+	ErrNotOwner           = errors.New("address belongs to another user")
+	ErrNoAddress          = errors.New("no shipping address")
+	ErrRateUnavailable    = errors.New("shipping rate is not available")
+	ErrHazmatRestricted   = errors.New("route does not accept flammable goods")
+	ErrAlreadyShipped     = errors.New("order already has a shipment")
+	ErrUnknownShipment    = errors.New("unknown shipment")
+)
+
+// Postal code formats of the countries shipped to, after normalization
+var postalCodes = map[string]*regexp.Regexp{
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] \d[A-Z]\d$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`),
+	"IE": regexp.MustCompile(`^[A-Z\d]{3} [A-Z\d]{4}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} [A-Z]{2}$`),
+	"BE": regexp.MustCompile(`^\d{4}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+}
+
+// Countries whose postal codes are written with a space before their last
+// characters, and how many characters follow it
+var inwardCodes = map[string]int{"CA": 3, "GB": 3, "IE": 4, "NL": 2}
+
+// Countries flammable goods can be shipped between by ground, besides
+// domestic routes
+var hazmatZones = [][]string{
+	{"DE", "FR", "ES", "IT", "NL", "BE", "IE"},
+	{"US", "CA"},
+}
+
+// Address is an entry of a user's address book
+type Address struct {
+	persist.Base
+	UserID     string `gorm:"not null;index" json:"user_id"`
+	Name       string `gorm:"not null" json:"name" audit:"redact"`
+	Line1      string `gorm:"not null" json:"line1" audit:"redact"`
+	Line2      string `json:"line2,omitempty" audit:"redact"`
+	City       string `gorm:"not null" json:"city"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `gorm:"not null" json:"postal_code" audit:"redact"`
+	// ISO 3166-1 alpha-2 code
+	Country string `gorm:"not null" json:"country"`
+	Phone   string `json:"phone,omitempty" audit:"redact"`
+	// Used for orders placed without an address and as the origin of sales
+	IsDefault bool `gorm:"not null;default:false" json:"is_default"`
+}
+
+// Orders keep shipping to addresses deleted from the address book, so they
+// are never purged
+func (*Address) Retention() time.Duration {
+	return 0
+}
+
+// AddressInput creates or replaces an address
+type AddressInput struct {
+	Name       string `json:"name" validate:"required,max=120"`
+	Line1      string `json:"line1" validate:"required,max=200"`
+	Line2      string `json:"line2,omitempty" validate:"max=200"`
+	City       string `json:"city" validate:"required,max=120"`
+	Region     string `json:"region,omitempty" validate:"max=120" doc:"State or province, required for US and CA"`
+	PostalCode string `json:"postal_code" validate:"required,max=20"`
+	Country    string `json:"country" validate:"required,min=2,max=2" doc:"ISO 3166-1 alpha-2 country code"`
+	Phone      string `json:"phone,omitempty" validate:"max=40" doc:"Required by carriers for international shipments"`
+	IsDefault  bool   `json:"is_default,omitempty"`
+}
+
+var (
+	spaces     = regexp.MustCompile(`\s+`)
+	phoneChars = regexp.MustCompile(`[^\d+]`)
+)
+
+func clean(s string) string {
+	return spaces.ReplaceAllString(strings.TrimSpace(s), " ")
+}
+
+// Normalize the input into an address, rejecting postal codes that don't
+// match the country's format
+func (in AddressInput) Normalize() (*Address, error) {
+	a := &Address{
+		Name:       clean(in.Name),
+		Line1:      clean(in.Line1),
+		Line2:      clean(in.Line2),
+		City:       clean(in.City),
+		Region:     clean(in.Region),
+		PostalCode: strings.ToUpper(strings.ReplaceAll(in.PostalCode, " ", "")),
+		Country:    strings.ToUpper(clean(in.Country)),
+		Phone:      phoneChars.ReplaceAllString(in.Phone, ""),
+		IsDefault:  in.IsDefault,
+	}
+
+	format, ok := postalCodes[a.Country]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCountry, a.Country)
+	}
+	if n, ok := inwardCodes[a.Country]; ok && len(a.PostalCode) > n {
+		a.PostalCode = a.PostalCode[:len(a.PostalCode)-n] + " " + a.PostalCode[len(a.PostalCode)-n:]
+	}
+	if !format.MatchString(a.PostalCode) {
+		return nil, fmt.Errorf("%w: postal code %s is not valid for %s", ErrInvalidAddress, in.PostalCode, a.Country)
+	}
+
+	if a.Country == "US" || a.Country == "CA" {
+		a.Region = strings.ToUpper(a.Region)
+		if len(a.Region) != 2 {
+			return nil, fmt.Errorf("%w: region must be a two letter state or province code", ErrInvalidAddress)
+		}
+	}
+	if a.Line1 == "" || a.City == "" || a.Name == "" {
+		return nil, fmt.Errorf("%w: name, line1 and city are required", ErrInvalidAddress)
+	}
+	return a, nil
+}
+
+// Whether flammable goods can travel by ground between the countries
+func HazmatRoute(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, zone := range hazmatZones {
+		if slices.Contains(zone, from) && slices.Contains(zone, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fragrances are alcohol based and ship as flammable liquids (UN1266) in
+// limited quantities, restricted to ground services
+const FragranceHazmat = true
+
+// Upper bound of the weight in grams of a parcel of full glass containers,
+// the glass weighing about as much as the liquid it holds
+func EstimateWeight(containerML, containers int) int {
+	const packaging = 150
+	return packaging + 2*containerML*containers
+}
+
+type Status string
+
+const (
+	StatusLabelCreated   Status = "label_created"
+	StatusInTransit      Status = "in_transit"
+	StatusOutForDelivery Status = "out_for_delivery"
+	StatusDelivered      Status = "delivered"
+	StatusException      Status = "exception"
+	StatusReturned       Status = "returned"
+)
+
+// Delivered and returned shipments are no longer tracked
+func (s Status) Final() bool {
+	return s == StatusDelivered || s == StatusReturned
+}
+
+const (
+	EventCreated events.Type = "shipment.created"
+	EventUpdated events.Type = "shipment.updated"
+)
+
+const resourceType = "shipment"
+
+// Shipment is the parcel of an order sent by the seller. Addresses are
+// copied so later address book changes don't affect it.
+type Shipment struct {
+	persist.Base
+	OrderID  string `gorm:"type:uuid;not null;uniqueIndex:idx_shipment_order,where:deleted_at IS NULL" json:"order_id"`
+	BuyerID  string `gorm:"not null;index" json:"buyer_id"`
+	SellerID string `gorm:"not null;index" json:"seller_id"`
+
+	Provider string `gorm:"not null" json:"provider"`
+	Carrier  string `gorm:"not null" json:"carrier"`
+	Service  string `gorm:"not null" json:"service"`
+	// Minor currency units
+	Cost     int64  `gorm:"not null" json:"cost"`
+	Currency string `gorm:"not null" json:"currency"`
+	Hazmat   bool   `gorm:"not null" json:"hazmat"`
+	WeightG  int    `gorm:"not null" json:"weight_g"`
+
+	TrackingNumber string `gorm:"not null;index" json:"tracking_number"`
+	LabelURL       string `json:"label_url,omitempty"`
+	Status         Status `gorm:"not null;index;default:label_created" json:"status"`
+	StatusDetail   string `json:"status_detail,omitempty"`
+
+	From Address `gorm:"serializer:json" json:"from"`
+	To   Address `gorm:"serializer:json" json:"to"`
+
+	CheckedAt   *time.Time `gorm:"index" json:"checked_at,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+func (s *Shipment) Event(t events.Type) events.Event {
+	return events.New(t, resourceType, s.ID, s, s.BuyerID, s.SellerID)
+}
+
+// ShipmentInput buys the label of a quoted rate for an order
+type ShipmentInput struct {
+	RateID string `json:"rate_id" validate:"required,max=200"`
+}
@@ -0,0 +1,196 @@
+package shipping
+
+import (
+	"time"
+	"x/core/internal/persist"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Addresses of a user, the default first
+func ListAddresses(db *gorm.DB, userID string) ([]Address, error) {
+	var records []Address
+	err := db.Where("user_id = ?", userID).Order("is_default DESC, created_at").Find(&records).Error
+	return records, err
+}
+
+// Address of a user. Deleted addresses still referenced by orders are found
+// with an unscoped db.
+func GetAddress(db *gorm.DB, userID, id string) (*Address, error) {
+	var a Address
+	if err := db.Where("id = ?", id).First(&a).Error; err != nil {
+		return nil, err
+	}
+	if a.UserID != userID {
+		return nil, ErrNotOwner
+	}
+	return &a, nil
+}
+
+// Default address of a user, gorm.ErrRecordNotFound if there is none
+func GetDefaultAddress(db *gorm.DB, userID string) (*Address, error) {
+	var a Address
+	if err := db.Where("user_id = ? AND is_default", userID).First(&a).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// The first address of a user is their default, whatever the input says
+func resolveDefault(tx *gorm.DB, a *Address) error {
+	if a.IsDefault {
+		return nil
+	}
+
+	query := tx.Model(&Address{}).Where("user_id = ?", a.UserID)
+	if a.ID != "" {
+		query = query.Where("id <> ?", a.ID)
+	}
+	var others int64
+	if err := query.Count(&others).Error; err != nil {
+		return err
+	}
+	a.IsDefault = others == 0
+	return nil
+}
+
+// Clear the default flag of the user's other addresses
+func clearDefault(tx *gorm.DB, a *Address) error {
+	if !a.IsDefault {
+		return nil
+	}
+	return tx.Model(&Address{}).
+		Where("user_id = ? AND id <> ? AND is_default", a.UserID, a.ID).
+		Update("is_default", false).Error
+}
+
+func CreateAddress(db *gorm.DB, userID string, in AddressInput) (*Address, error) {
+	a, err := in.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	a.UserID = userID
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := resolveDefault(tx, a); err != nil {
+			return err
+		}
+		if err := tx.Create(a).Error; err != nil {
+			return err
+		}
+		return clearDefault(tx, a)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Replace an address of the user if it is still at the expected version
+func UpdateAddress(db *gorm.DB, userID, id string, version int64, in AddressInput) (*Address, error) {
+	next, err := in.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		a, err := GetAddress(tx, userID, id)
+		if err != nil {
+			return err
+		}
+		next.ID, next.UserID = a.ID, a.UserID
+		if err := resolveDefault(tx, next); err != nil {
+			return err
+		}
+
+		// Map updates so cleared fields are written too
+		err = persist.UpdateRecordByIDIfVersion[Address](tx, id, version, map[string]interface{}{
+			"name":        next.Name,
+			"line1":       next.Line1,
+			"line2":       next.Line2,
+			"city":        next.City,
+			"region":      next.Region,
+			"postal_code": next.PostalCode,
+			"country":     next.Country,
+			"phone":       next.Phone,
+			"is_default":  next.IsDefault,
+		})
+		if err != nil {
+			return err
+		}
+		return clearDefault(tx, next)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetAddress(db, userID, id)
+}
+
+func DeleteAddress(db *gorm.DB, userID, id string) error {
+	if _, err := GetAddress(db, userID, id); err != nil {
+		return err
+	}
+	return persist.DeleteRecordByID[Address](db, id)
+}
+
+func GetShipmentByOrder(db *gorm.DB, orderID string) (*Shipment, error) {
+	var s Shipment
+	if err := db.Where("order_id = ?", orderID).First(&s).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Load a shipment and lock its row until the transaction ends
+func Lock(tx *gorm.DB, id string) (*Shipment, error) {
+	var s Shipment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&s).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Record the tracking status of a locked shipment, reporting whether it
+// changed
+func SetTracking(tx *gorm.DB, s *Shipment, t *Tracking) (bool, error) {
+	now := time.Now()
+	updates := map[string]interface{}{"checked_at": now}
+	changed := t.Status != s.Status || t.Detail != s.StatusDetail
+	if changed {
+		updates["status"] = t.Status
+		updates["status_detail"] = t.Detail
+		if t.Status == StatusDelivered {
+			updates["delivered_at"] = now
+		}
+	}
+
+	result := tx.Model(s).Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, persist.ErrConflict
+	}
+
+	s.CheckedAt = &now
+	if changed {
+		s.Status, s.StatusDetail = t.Status, t.Detail
+		if t.Status == StatusDelivered {
+			s.DeliveredAt = &now
+		}
+	}
+	return changed, nil
+}
+
+// IDs of shipments in progress not checked since the cutoff, least recently
+// checked first
+func StaleShipmentIDs(db *gorm.DB, checkedBefore time.Time, limit int) ([]string, error) {
+	var ids []string
+	err := db.Model(&Shipment{}).
+		Where("status NOT IN ? AND (checked_at IS NULL OR checked_at < ?)", []Status{StatusDelivered, StatusReturned}, checkedBefore).
+		Order("checked_at NULLS FIRST").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,113 @@
+package watchlists
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"x/core/internal/listings"
+)
+
+// Filters of a listing list request by field. They are evaluated against
+// single listings the way persist.ApplyFilters applies them to queries: price
+// and pct_remaining may end with "+" or "-" for lower and upper bounds,
+// everything else is equality.
+type Filters map[string]string
+
+// Listing values the filters are evaluated against
+var fields = map[string]func(l *listings.Listing) string{
+	"brand":         func(l *listings.Listing) string { return l.Brand },
+	"concentration": func(l *listings.Listing) string { return l.Concentration },
+	"condition":     func(l *listings.Listing) string { return string(l.Condition) },
+	"fragrance_id":  func(l *listings.Listing) string { return l.FragranceID },
+	"seller_id":     func(l *listings.Listing) string { return l.SellerID },
+	"split":         func(l *listings.Listing) string { return strconv.FormatBool(l.Split) },
+}
+
+// Bound on a numeric field, op is "+", "-" or empty for equality
+type bound struct {
+	value float64
+	op    byte
+}
+
+func parseBound(s string) (bound, error) {
+	var b bound
+	if last := s[len(s)-1]; last == '+' || last == '-' {
+		b.op, s = last, s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return b, err
+	}
+	b.value = v
+	return b, nil
+}
+
+func (b bound) matches(v float64) bool {
+	switch b.op {
+	case '+':
+		return v >= b.value
+	case '-':
+		return v <= b.value
+	default:
+		return v == b.value
+	}
+}
+
+// Check the filters only use the allowed fields, with values they can be
+// evaluated with
+func (f Filters) Validate(allowed []string) error {
+	if len(f) == 0 {
+		return fmt.Errorf("%w: at least one filter is required", ErrInvalidFilter)
+	}
+	for field, value := range f {
+		if !slices.Contains(allowed, field) {
+			return fmt.Errorf("%w: unknown field %s", ErrInvalidFilter, field)
+		}
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%w: %s is empty", ErrInvalidFilter, field)
+		}
+		switch field {
+		case "price", "pct_remaining":
+			b, err := parseBound(value)
+			if err != nil || (field == "price" && b.value != float64(int(b.value))) {
+				return fmt.Errorf("%w: %s must be a number, optionally ending with + or -", ErrInvalidFilter, field)
+			}
+		case "split":
+			if value != "true" && value != "false" {
+				return fmt.Errorf("%w: split must be true or false", ErrInvalidFilter)
+			}
+		default:
+			if _, ok := fields[field]; !ok {
+				return fmt.Errorf("%w: %s can't be saved", ErrInvalidFilter, field)
+			}
+		}
+	}
+	return nil
+}
+
+// Whether the listing is in the results of the filters
+func (f Filters) Matches(l *listings.Listing) bool {
+	for field, value := range f {
+		switch field {
+		case "price", "pct_remaining":
+			b, err := parseBound(value)
+			if err != nil {
+				return false
+			}
+			v := l.PctRemaining
+			if field == "price" {
+				v = float64(l.Price)
+			}
+			if !b.matches(v) {
+				return false
+			}
+		default:
+			get, ok := fields[field]
+			if !ok || get(l) != value {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,215 @@
+package watchlists
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+	"x/core/internal/listings"
+	"x/core/internal/persist"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Page of the user's watches, most recent first
+func ListWatches(db *gorm.DB, userID string, page, pageSize int) ([]Watch, int, error) {
+	query := db.Model(&Watch{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+
+	var records []Watch
+	err := query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&records).Error
+	return records, totalPages, err
+}
+
+// Watch of the user on the same fragrance or listing as w
+func findWatch(db *gorm.DB, w *Watch) (*Watch, error) {
+	query := db.Where("user_id = ?", w.UserID)
+	if w.ListingID != nil {
+		query = query.Where("listing_id = ?", *w.ListingID)
+	} else {
+		query = query.Where("fragrance_id = ?", *w.FragranceID)
+	}
+
+	var existing Watch
+	if err := query.First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Create the watch, or update the price limit of the user's existing watch
+// on the same fragrance or listing
+func SaveWatch(db *gorm.DB, w *Watch) (*Watch, error) {
+	existing, err := findWatch(db, w)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var count int64
+		if err := db.Model(&Watch{}).Where("user_id = ?", w.UserID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count >= MaxWatches {
+			return nil, ErrTooManyWatches
+		}
+
+		err = db.Create(w).Error
+		if err == nil {
+			return w, nil
+		}
+		// Created concurrently, update it instead
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+			return nil, err
+		}
+		existing, err = findWatch(db, w)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Model(existing).Updates(map[string]interface{}{"max_price": w.MaxPrice, "currency": w.Currency}).Error
+	if err != nil {
+		return nil, err
+	}
+	existing.MaxPrice, existing.Currency = w.MaxPrice, w.Currency
+	return existing, nil
+}
+
+func DeleteWatch(db *gorm.DB, userID, id string) error {
+	result := db.Where("id = ? AND user_id = ?", id, userID).Delete(&Watch{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Watches of other users than the seller on the listing or its fragrance
+func WatchesFor(db *gorm.DB, l *listings.Listing) ([]Watch, error) {
+	var records []Watch
+	err := db.Where("(listing_id = ? OR fragrance_id = ?) AND user_id <> ?", l.ID, l.FragranceID, l.SellerID).
+		Find(&records).Error
+	return records, err
+}
+
+func ListSavedSearches(db *gorm.DB, userID string) ([]SavedSearch, error) {
+	var records []SavedSearch
+	err := db.Where("user_id = ?", userID).Order("created_at").Find(&records).Error
+	return records, err
+}
+
+func GetSavedSearch(db *gorm.DB, userID, id string) (*SavedSearch, error) {
+	var s SavedSearch
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&s).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func CreateSavedSearch(db *gorm.DB, userID string, in SavedSearchInput) (*SavedSearch, error) {
+	var count int64
+	if err := db.Model(&SavedSearch{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count >= MaxSavedSearches {
+		return nil, ErrTooManySearches
+	}
+
+	s := &SavedSearch{UserID: userID, Name: in.Name, Filters: in.Filters}
+	if err := db.Create(s).Error; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Replace the name and filters of one of the user's saved searches if it is
+// still at the expected version
+func UpdateSavedSearch(db *gorm.DB, userID, id string, version int64, in SavedSearchInput) (*SavedSearch, error) {
+	if _, err := GetSavedSearch(db, userID, id); err != nil {
+		return nil, err
+	}
+
+	filters, err := json.Marshal(in.Filters)
+	if err != nil {
+		return nil, err
+	}
+	err = persist.UpdateRecordByIDIfVersion[SavedSearch](db, id, version, map[string]interface{}{
+		"name":    in.Name,
+		"filters": string(filters),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetSavedSearch(db, userID, id)
+}
+
+func DeleteSavedSearch(db *gorm.DB, userID, id string) error {
+	result := db.Where("id = ? AND user_id = ?", id, userID).Delete(&SavedSearch{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Go through every saved search in batches
+func EachSavedSearchBatch(db *gorm.DB, batchSize int, fn func([]SavedSearch) error) error {
+	var batch []SavedSearch
+	return db.FindInBatches(&batch, batchSize, func(*gorm.DB, int) error {
+		return fn(batch)
+	}).Error
+}
+
+// Lowest price the source already alerted of for the listing, nil if none
+func LastAlertedPrice(db *gorm.DB, sourceID, listingID string) (*int, error) {
+	var price sql.NullInt64
+	err := db.Model(&Alert{}).
+		Where("source_id = ? AND listing_id = ?", sourceID, listingID).
+		Select("MIN(price)").
+		Row().Scan(&price)
+	if err != nil || !price.Valid {
+		return nil, err
+	}
+	p := int(price.Int64)
+	return &p, nil
+}
+
+// Record an alert, false when the same match was already recorded
+func CreateAlert(db *gorm.DB, a *Alert) (bool, error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(a)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Alerts the user was notified of since the time
+func CountNotified(db *gorm.DB, userID string, since time.Time) (int, error) {
+	var count int64
+	err := db.Model(&Alert{}).Where("user_id = ? AND notified AND created_at > ?", userID, since).Count(&count).Error
+	return int(count), err
+}
+
+// Page of the user's alerts, most recent first
+func ListAlerts(db *gorm.DB, userID string, page, pageSize int) ([]Alert, int, error) {
+	query := db.Model(&Alert{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+
+	var records []Alert
+	err := query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&records).Error
+	return records, totalPages, err
+}
@@ -0,0 +1,158 @@
+package watchlists
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"x/core/internal/events"
+	"x/core/internal/listings"
+	"x/core/internal/persist"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Most fragrances and listings a user can watch
+	MaxWatches = 200
+	// Most searches a user can save
+	MaxSavedSearches = 20
+)
+
+var (
+	ErrTooManyWatches   = fmt.Errorf("at most %d watches per user", MaxWatches)
+	ErrTooManySearches  = fmt.Errorf("at most %d saved searches per user", MaxSavedSearches)
+	ErrInvalidWatch     = errors.New("exactly one of fragrance_id or listing_id is required")
+	ErrInvalidFilter    = errors.New("invalid saved search filter")
+	ErrListingNotActive = errors.New("only active listings can be watched")
+)
+
+const EventAlert events.Type = "watchlist.alert"
+
+const alertResource = "alert"
+
+// Watch follows a fragrance, alerting of its listings at or below the max
+// price, or a single listing, alerting when its price drops
+type Watch struct {
+	persist.Base
+	UserID      string  `gorm:"not null;index;uniqueIndex:idx_watch_fragrance,where:fragrance_id IS NOT NULL AND deleted_at IS NULL;uniqueIndex:idx_watch_listing,where:listing_id IS NOT NULL AND deleted_at IS NULL" json:"user_id"`
+	FragranceID *string `gorm:"type:uuid;index;uniqueIndex:idx_watch_fragrance,where:fragrance_id IS NOT NULL AND deleted_at IS NULL" json:"fragrance_id,omitempty"`
+	ListingID   *string `gorm:"type:uuid;index;uniqueIndex:idx_watch_listing,where:listing_id IS NOT NULL AND deleted_at IS NULL" json:"listing_id,omitempty"`
+
+	// Only alert at or below this price, in whole units of the currency
+	MaxPrice *int   `json:"max_price,omitempty"`
+	Currency string `gorm:"not null;default:USD" json:"currency"`
+	// Price of the listing when it was watched, drops are measured from it
+	Price *int `json:"price,omitempty"`
+}
+
+// Whether a listing at the price is cheap enough to alert of
+func (w *Watch) Accepts(price int, currency string) bool {
+	if w.MaxPrice == nil {
+		return true
+	}
+	return currency == w.Currency && price <= *w.MaxPrice
+}
+
+// SavedSearch stores the filters of a listing list request, alerting of
+// listings published or repriced into its results
+type SavedSearch struct {
+	persist.Base
+	UserID  string  `gorm:"not null;index" json:"user_id"`
+	Name    string  `gorm:"not null" json:"name"`
+	Filters Filters `gorm:"serializer:json" json:"filters"`
+}
+
+type Source string
+
+const (
+	SourceWatch  Source = "watch"
+	SourceSearch Source = "search"
+)
+
+// Alert tells a user a listing matched one of their watches or saved
+// searches. A listing is alerted of again only when its price drops below the
+// last alerted price.
+type Alert struct {
+	persist.Base
+	UserID    string `gorm:"not null;index" json:"user_id"`
+	Source    Source `gorm:"not null" json:"source"`
+	SourceID  string `gorm:"type:uuid;not null;uniqueIndex:idx_alert_match" json:"source_id"`
+	ListingID string `gorm:"type:uuid;not null;uniqueIndex:idx_alert_match" json:"listing_id"`
+	Name      string `gorm:"not null" json:"name"`
+	Price     int    `gorm:"not null;uniqueIndex:idx_alert_match" json:"price"`
+	Currency  string `gorm:"not null" json:"currency"`
+	// Price alerted of before, or the price when a listing was watched
+	Previous *int `json:"previous,omitempty"`
+	// Alerts past the user's rate limit are kept but not pushed
+	Notified bool `gorm:"not null;default:false;index" json:"notified"`
+}
+
+func (a *Alert) Event() events.Event {
+	return events.New(EventAlert, alertResource, a.ID, a, a.UserID)
+}
+
+// Cursor over listing updates, the position the matcher got to
+type Cursor struct {
+	UpdatedAt time.Time
+	ID        string
+}
+
+// Cursor before every listing updated after the time
+func CursorAt(t time.Time) Cursor {
+	return Cursor{UpdatedAt: t, ID: uuid.Nil.String()}
+}
+
+// RateLimit caps how many alerts a user is notified of per window
+type RateLimit struct {
+	Max    int
+	Window time.Duration
+}
+
+type WatchInput struct {
+	FragranceID string `json:"fragrance_id,omitempty" doc:"Fragrance to alert of new listings of"`
+	ListingID   string `json:"listing_id,omitempty" doc:"Listing to alert of price drops of"`
+	MaxPrice    int    `json:"max_price,omitempty" validate:"min=0,max=100000" doc:"Only alert at or below this price"`
+	Currency    string `json:"currency,omitempty" validate:"oneof=USD EUR GBP"`
+}
+
+func (in WatchInput) currency() string {
+	if in.Currency == "" {
+		return "USD"
+	}
+	return in.Currency
+}
+
+func (in WatchInput) maxPrice() *int {
+	if in.MaxPrice == 0 {
+		return nil
+	}
+	return &in.MaxPrice
+}
+
+// Watch of the user described by the input. Listing watches need the
+// listing, their currency and starting price are taken from it.
+func NewWatch(userID string, in WatchInput, l *listings.Listing) (*Watch, error) {
+	if (in.FragranceID == "") == (in.ListingID == "") {
+		return nil, ErrInvalidWatch
+	}
+
+	w := &Watch{UserID: userID, MaxPrice: in.maxPrice(), Currency: in.currency()}
+	if in.FragranceID != "" {
+		w.FragranceID = &in.FragranceID
+		return w, nil
+	}
+
+	if l == nil || l.ID != in.ListingID {
+		return nil, ErrInvalidWatch
+	}
+	if l.Status != listings.StatusActive {
+		return nil, ErrListingNotActive
+	}
+	w.ListingID, w.Currency, w.Price = &l.ID, l.Currency, &l.Price
+	return w, nil
+}
+
+type SavedSearchInput struct {
+	Name    string  `json:"name" validate:"required,max=100"`
+	Filters Filters `json:"filters" doc:"Filters of the listing list, e.g. {\"brand\": \"Creed\", \"price\": \"250-\"}"`
+}